@@ -0,0 +1,229 @@
+package cli
+
+import "math/big"
+
+// Result types describe the data object of each command's structured output.
+// Their json tags are the emitted keys; the schema command derives its JSON
+// Schema documents from them.
+
+// InfoResult is the output of info for a network. Host is the address as
+// given, with its prefix length, under --keep-host.
+type InfoResult struct {
+	Network         string `json:"network" yaml:"network"`
+	Host            string `json:"host,omitempty" yaml:"host,omitempty"`
+	PrefixLength    int    `json:"prefix_length" yaml:"prefix_length"`
+	Netmask         string `json:"netmask" yaml:"netmask"`
+	Hostmask        string `json:"hostmask" yaml:"hostmask"`
+	FirstHost       string `json:"first_host" yaml:"first_host"`
+	LastHost        string `json:"last_host" yaml:"last_host"`
+	HostCount       string `json:"host_count" yaml:"host_count"`
+	HostCountPower  string `json:"host_count_power" yaml:"host_count_power"`
+	HostCountApprox string `json:"host_count_approx" yaml:"host_count_approx"`
+	UsableCount     string `json:"usable_count" yaml:"usable_count"`
+}
+
+// AddressInfoResult is the output of info for a single address.
+type AddressInfoResult struct {
+	Address    string `json:"address" yaml:"address"`
+	Expanded   string `json:"expanded" yaml:"expanded"`
+	Reverse    string `json:"reverse" yaml:"reverse"`
+	IPv4Mapped string `json:"ipv4_mapped,omitempty" yaml:"ipv4_mapped,omitempty"`
+	// EmbeddedIPv4 is set when the input ended in a dotted quad.
+	EmbeddedIPv4 string `json:"embedded_ipv4,omitempty" yaml:"embedded_ipv4,omitempty"`
+}
+
+// MaskResult is the output of mask.
+type MaskResult struct {
+	PrefixLength int    `json:"prefix_length" yaml:"prefix_length"`
+	Netmask      string `json:"netmask" yaml:"netmask"`
+	Hostmask     string `json:"hostmask" yaml:"hostmask"`
+}
+
+// PrefixRow is one line of the table command.
+type PrefixRow struct {
+	PrefixLength    int    `json:"prefix_length" yaml:"prefix_length"`
+	Netmask         string `json:"netmask" yaml:"netmask"`
+	HostCount       string `json:"host_count" yaml:"host_count"`
+	HostCountPower  string `json:"host_count_power" yaml:"host_count_power"`
+	HostCountApprox string `json:"host_count_approx" yaml:"host_count_approx"`
+}
+
+// PositionResult is the output of position. Percentile is Position as a
+// percentage of the network's size.
+type PositionResult struct {
+	Position         string  `json:"position" yaml:"position"`
+	Percentile       float64 `json:"percentile" yaml:"percentile"`
+	IsNetworkAddress bool    `json:"is_network_address" yaml:"is_network_address"`
+	IsLastAddress    bool    `json:"is_last_address" yaml:"is_last_address"`
+}
+
+// SubnetCountResult is the output of count, formatted like host counts.
+type SubnetCountResult struct {
+	PrefixLength int    `json:"prefix_length" yaml:"prefix_length"`
+	Count        string `json:"count" yaml:"count"`
+	CountPower   string `json:"count_power" yaml:"count_power"`
+	CountApprox  string `json:"count_approx" yaml:"count_approx"`
+}
+
+// HashResult is the output of hash.
+type HashResult struct {
+	Hash    string `json:"hash" yaml:"hash"`
+	HashHex string `json:"hash_hex" yaml:"hash_hex"`
+}
+
+// ExplainStep is one step of an explain walkthrough.
+type ExplainStep struct {
+	Step   int    `json:"step" yaml:"step"`
+	Title  string `json:"title" yaml:"title"`
+	Value  string `json:"value" yaml:"value"`
+	Detail string `json:"detail" yaml:"detail"`
+}
+
+// ExplainResult is the output of explain.
+type ExplainResult struct {
+	CIDR  string        `json:"cidr" yaml:"cidr"`
+	Steps []ExplainStep `json:"steps" yaml:"steps"`
+}
+
+// MulticastResult is the output of multicast.
+type MulticastResult struct {
+	Flags       uint8  `json:"flags" yaml:"flags"`
+	Transient   bool   `json:"transient" yaml:"transient"`
+	PrefixBased bool   `json:"prefix_based" yaml:"prefix_based"`
+	Rendezvous  bool   `json:"rendezvous" yaml:"rendezvous"`
+	Scope       uint8  `json:"scope" yaml:"scope"`
+	ScopeName   string `json:"scope_name" yaml:"scope_name"`
+}
+
+// IndexedValue is one element of a list under --indexed; Index is 1-based
+// like the --table index column.
+type IndexedValue struct {
+	Index int    `json:"index" yaml:"index"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// CountResult is the output of --count-only.
+type CountResult struct {
+	Count *big.Int `json:"count" yaml:"count"`
+}
+
+// SplitValidateResult is the output of split --validate.
+type SplitValidateResult struct {
+	Valid         bool   `json:"valid" yaml:"valid"`
+	Subnets       uint64 `json:"subnets" yaml:"subnets"`
+	ForceRequired bool   `json:"force_required" yaml:"force_required"`
+}
+
+// GroupResult is one network of the group command and its member addresses.
+type GroupResult struct {
+	Network string   `json:"network" yaml:"network"`
+	Members []string `json:"members" yaml:"members"`
+}
+
+// TreeNode is one network of the tree command; Children holds its two
+// halves unless it is at the requested depth.
+type TreeNode struct {
+	Network  string     `json:"network" yaml:"network"`
+	Children []TreeNode `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// FitResult is the output of fit.
+type FitResult struct {
+	PrefixLength     int    `json:"prefix_length" yaml:"prefix_length"`
+	FirstSubnet      string `json:"first_subnet" yaml:"first_subnet"`
+	HostCount        string `json:"host_count" yaml:"host_count"`
+	SubnetsAvailable string `json:"subnets_available" yaml:"subnets_available"`
+}
+
+// PlanRow is one allocation of the plan command.
+type PlanRow struct {
+	Hosts    string `json:"hosts" yaml:"hosts"`
+	CIDR     string `json:"cidr" yaml:"cidr"`
+	Capacity string `json:"capacity" yaml:"capacity"`
+}
+
+// SummarizeStatsResult is the output of summarize --stats.
+type SummarizeStatsResult struct {
+	InputCount     int    `json:"input_count" yaml:"input_count"`
+	OutputCount    int    `json:"output_count" yaml:"output_count"`
+	TotalAddresses string `json:"total_addresses" yaml:"total_addresses"`
+}
+
+// RangeResult is the output of range --with-meta. Count is the number of
+// addresses from Start to End inclusive, which the CIDRs cover exactly unless
+// --max-cidrs merged them; OverCoverage then counts the addresses they cover
+// outside the range.
+type RangeResult struct {
+	Start        string   `json:"start" yaml:"start"`
+	End          string   `json:"end" yaml:"end"`
+	Count        *big.Int `json:"count" yaml:"count"`
+	CIDRs        []string `json:"cidrs" yaml:"cidrs"`
+	OverCoverage *big.Int `json:"over_coverage,omitempty" yaml:"over_coverage,omitempty"`
+}
+
+// DiffGap is an unallocated range between two diff inputs. Its JSON keys are
+// capitalised for compatibility with earlier releases.
+type DiffGap struct {
+	Start string `json:"Start" yaml:"start"`
+	End   string `json:"End" yaml:"end"`
+}
+
+// DiffResult is the output of diff.
+type DiffResult struct {
+	Overlaps []string  `json:"overlaps" yaml:"overlaps"`
+	Gaps     []DiffGap `json:"gaps" yaml:"gaps"`
+}
+
+// GapRow is one free block reported by gaps.
+type GapRow struct {
+	CIDR  string `json:"cidr" yaml:"cidr"`
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+}
+
+// ClassifyRangeResult is the output of classify-range. Uniform reports that
+// every address of the network has the same classification.
+type ClassifyRangeResult struct {
+	CIDR    string   `json:"cidr" yaml:"cidr"`
+	Types   []string `json:"types" yaml:"types"`
+	Uniform bool     `json:"uniform" yaml:"uniform"`
+}
+
+// SpecialResult is the output of special. The registry fields are empty when
+// the address is in no special-purpose block.
+type SpecialResult struct {
+	Address string `json:"address" yaml:"address"`
+	Special bool   `json:"special" yaml:"special"`
+	Network string `json:"network,omitempty" yaml:"network,omitempty"`
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	RFC     string `json:"rfc,omitempty" yaml:"rfc,omitempty"`
+}
+
+// CommonPrefixResult is the output of common-prefix.
+type CommonPrefixResult struct {
+	PrefixLength int    `json:"prefix_length" yaml:"prefix_length"`
+	Prefix       string `json:"prefix" yaml:"prefix"`
+	Network      string `json:"network" yaml:"network"`
+}
+
+// ULAInfoResult is the output of ula info.
+type ULAInfoResult struct {
+	Prefix   string `json:"prefix" yaml:"prefix"`
+	GlobalID string `json:"global_id" yaml:"global_id"`
+	SubnetID string `json:"subnet_id" yaml:"subnet_id"`
+	Local    bool   `json:"local" yaml:"local"`
+}
+
+// VersionResult is the output of version.
+type VersionResult struct {
+	Version   string `json:"version" yaml:"version"`
+	Commit    string `json:"commit" yaml:"commit"`
+	BuildDate string `json:"build_date" yaml:"build_date"`
+}
+
+// ErrorResult is the error object emitted in place of data when a command
+// fails under json or yaml output. Code is the process exit status.
+type ErrorResult struct {
+	Code    int    `json:"code" yaml:"code"`
+	Message string `json:"message" yaml:"message"`
+}
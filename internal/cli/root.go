@@ -6,10 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/big"
 	"math/rand"
+	"net"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,6 +22,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/cobra/doc"
+	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
 
 	"github.com/zlobste/ip6calc/ipv6"
@@ -28,12 +34,13 @@ const (
 	outHuman outputFormat = "human"
 	outJSON  outputFormat = "json"
 	outYAML  outputFormat = "yaml"
+	outTSV   outputFormat = "tsv"
 )
 
 // Set implements pflag.Value for validation.
 func (o *outputFormat) Set(v string) error {
 	switch v {
-	case string(outHuman), string(outJSON), string(outYAML):
+	case string(outHuman), string(outJSON), string(outYAML), string(outTSV):
 		*o = outputFormat(v)
 		return nil
 	default:
@@ -43,6 +50,32 @@ func (o *outputFormat) Set(v string) error {
 func (o *outputFormat) String() string { return string(*o) }
 func (o *outputFormat) Type() string   { return "outputFormat" }
 
+type colorMode string
+
+const (
+	colorAuto   colorMode = "auto"
+	colorAlways colorMode = "always"
+	colorNever  colorMode = "never"
+)
+
+// Set implements pflag.Value. The former boolean values are still accepted:
+// true means always and false means never.
+func (c *colorMode) Set(v string) error {
+	switch v {
+	case string(colorAuto), string(colorAlways), string(colorNever):
+		*c = colorMode(v)
+	case "true":
+		*c = colorAlways
+	case "false":
+		*c = colorNever
+	default:
+		return fmt.Errorf("invalid color mode: %s (want auto|always|never)", v)
+	}
+	return nil
+}
+func (c *colorMode) String() string { return string(*c) }
+func (c *colorMode) Type() string   { return "colorMode" }
+
 // Version gets overridden via -ldflags at build time (e.g. -X github.com/zlobste/ip6calc/internal/cli.Version=v1.2.3)
 var Version = "dev"
 
@@ -55,11 +88,127 @@ var (
 // Custom error for oversized split operations requiring --force.
 var ErrSplitTooLarge = errors.New("split: too many subnets without --force")
 
+// maxTreeDepth bounds tree --depth; a full tree has 2^(depth+1)-1 lines.
+const maxTreeDepth = 10
+
+// typeColors are the ANSI SGR codes of colored address categories.
+var typeColors = map[ipv6.AddressType]string{
+	ipv6.TypeGlobalUnicast: "32", // green
+	ipv6.TypeLinkLocal:     "33", // yellow
+	ipv6.TypeUniqueLocal:   "34", // blue
+	ipv6.TypeMulticast:     "35", // magenta
+	ipv6.TypeDocumentation: "36", // cyan
+	ipv6.TypeLoopback:      "31", // red
+	ipv6.TypeUnspecified:   "90", // grey
+}
+
+// ErrNotExactCover is returned by verify-cover when the parts leave a gap in
+// the parent, overlap, or reach outside it.
+var ErrNotExactCover = errors.New("verify-cover: parts do not exactly cover the parent")
+
 // OverlapError indicates CIDR overlap when --fail-on-overlap is requested.
 type OverlapError struct{ A, B ipv6.CIDR }
 
 func (e OverlapError) Error() string { return fmt.Sprintf("overlap detected: %s %s", e.A, e.B) }
 
+// field is one named value of a command result.
+type field struct {
+	Key   string
+	Value any
+}
+
+// fields is an ordered result object. It marshals to a JSON object and a YAML
+// mapping in insertion order, so every format presents the same field order.
+type fields []field
+
+// MarshalJSON implements json.Marshaler.
+func (f fields) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, fl := range f {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(fl.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(fl.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (f fields) MarshalYAML() (any, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	for _, fl := range f {
+		v := &yaml.Node{}
+		if err := v.Encode(fl.Value); err != nil {
+			return nil, err
+		}
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: fl.Key}, v)
+	}
+	return n, nil
+}
+
+// pick returns the named fields in the requested order. Unknown names are an
+// error listing the valid ones.
+func (f fields) pick(keys []string) (fields, error) {
+	res := make(fields, 0, len(keys))
+	for _, k := range keys {
+		i := slices.IndexFunc(f, func(fl field) bool { return fl.Key == k })
+		if i < 0 {
+			valid := make([]string, len(f))
+			for j, fl := range f {
+				valid[j] = fl.Key
+			}
+			return nil, fmt.Errorf("unknown field %q (valid: %s)", k, strings.Join(valid, ", "))
+		}
+		res = append(res, f[i])
+	}
+	return res, nil
+}
+
+// structFields returns the exported fields of struct v keyed by their json
+// names in declaration order, dropping zero omitempty fields the way
+// encoding/json does. ok is false if v is not a struct.
+func structFields(v any) (f fields, ok bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Struct {
+		return nil, false
+	}
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" || !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(opts, "omitempty") && rv.Field(i).IsZero() {
+			continue
+		}
+		f = append(f, field{name, rv.Field(i).Interface()})
+	}
+	return f, true
+}
+
+// PartialFailureError reports that some inputs failed under
+// --continue-on-error while the rest were processed.
+type PartialFailureError struct{ Failed, Total int }
+
+func (e PartialFailureError) Error() string {
+	return fmt.Sprintf("%d of %d inputs failed", e.Failed, e.Total)
+}
+
 // Exit codes for different error classes.
 const (
 	exitCodeInvalidInput = 2
@@ -86,26 +235,118 @@ func getThreshold(env string, fallback int) int {
 // NewRootCmd constructs a new *cobra.Command tree with isolated state.
 func NewRootCmd(out io.Writer) *cobra.Command {
 	var format = outHuman
-	var flagColor, flagTable, flagQuiet, flagNoHeader bool
-	var flagUpper bool
+	var color = colorAuto
+	var flagColor, flagTable, flagQuiet, flagNoHeader bool // flagColor is color resolved for this run
+	var flagUpper, flagStrict, flagPrint0, flagContinue bool
+	var flagProgress, flagNoProgress, flagRaw, flagCompact, flagIndexed bool
+	var wrapSchema = true
+	var flagWidth int
 
 	rootCmd := &cobra.Command{Use: "ip6calc", Short: "IPv6 subnet calculator and utility tool", Long: "ip6calc provides IPv6 address and network calculations (expand, split, summarize, arithmetic, etc)."}
 	// Auto-detect format from env var if flag not supplied.
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
-		if !cmd.Flags().Changed("output") {
-			if envFmt := os.Getenv("IP6CALC_FORMAT"); envFmt != "" {
-				_ = format.Set(envFmt) // ignore invalid env value (explicit)
-			}
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		format = effectiveFormat(cmd)
+		wrapSchema = effectiveSchema(cmd)
+		flagColor = useColor(color, rootCmd.OutOrStdout())
+		silenceStructured(cmd)
+		if flagWidth < 0 {
+			return fmt.Errorf("invalid --width: %d", flagWidth)
+		}
+		if flagProgress && flagNoProgress {
+			return errors.New("--progress and --no-progress are mutually exclusive")
+		}
+		if flagPrint0 && flagTable {
+			return errors.New("--print0 and --table are mutually exclusive")
+		}
+		if flagPrint0 && format != outHuman {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: --print0 ignored for %s output\n", format)
 		}
 		return nil
 	}
 	rootCmd.SetOut(out)
-	rootCmd.PersistentFlags().VarP(&format, "output", "o", "output format: human|json|yaml")
-	rootCmd.PersistentFlags().BoolVar(&flagColor, "color", false, "colorize human output")
+	rootCmd.PersistentFlags().VarP(&format, "output", "o", "output format: human|json|yaml|tsv")
+	rootCmd.PersistentFlags().Var(&color, "color", "colorize human output: auto (when stdout is a terminal)|always|never; NO_COLOR disables it")
+	rootCmd.PersistentFlags().Lookup("color").NoOptDefVal = string(colorAlways) // bare --color
 	rootCmd.PersistentFlags().BoolVar(&flagTable, "table", false, "tabular human output where applicable")
 	rootCmd.PersistentFlags().BoolVar(&flagQuiet, "quiet", false, "suppress non-essential human output")
 	rootCmd.PersistentFlags().BoolVar(&flagNoHeader, "no-header", false, "omit headers in tabular output")
+	rootCmd.PersistentFlags().IntVar(&flagWidth, "width", 0, "pad the value column of --table output to this width (0: fit the data); longer values are not truncated")
 	rootCmd.PersistentFlags().BoolVar(&flagUpper, "upper", false, "use uppercase expanded form where relevant")
+	rootCmd.PersistentFlags().BoolVar(&flagStrict, "strict", false, "reject CIDRs with host bits set instead of masking them")
+	rootCmd.PersistentFlags().BoolVar(&flagContinue, "continue-on-error", false, "multi-input commands: skip bad inputs, report them on stderr and exit 2")
+	rootCmd.PersistentFlags().BoolVar(&flagProgress, "progress", false, "report progress of long-running commands on stderr")
+	rootCmd.PersistentFlags().BoolVar(&flagNoProgress, "no-progress", false, "never report progress, even where it is shown by default")
+	rootCmd.PersistentFlags().Bool("schema", true, "json/yaml: wrap results as {\"schema\":...,\"data\":...} (default from IP6CALC_SCHEMA)")
+	rootCmd.PersistentFlags().BoolVar(&flagCompact, "compact", false, "json: single-line output instead of indented")
+	rootCmd.PersistentFlags().BoolVar(&flagIndexed, "indexed", false, "json/yaml: emit lists as [{\"index\":1,\"value\":...}] matching the --table index column")
+	rootCmd.PersistentFlags().BoolVar(&flagRaw, "raw", false, "json/yaml: emit the bare result without the schema/data envelope")
+	rootCmd.PersistentFlags().BoolVar(&flagPrint0, "print0", false, "terminate human list items and single results with NUL instead of newline (for xargs -0; not with --table)")
+
+	// parseCIDR honours --strict for every CIDR argument and input line.
+	parseCIDR := func(s string) (ipv6.CIDR, error) {
+		if flagStrict {
+			return ipv6.ParseCIDRStrict(s)
+		}
+		return ipv6.ParseCIDR(s)
+	}
+
+	// failInput handles a bad input at 1-based position n. It aborts unless
+	// --continue-on-error is set, in which case the failure is recorded.
+	failInput := func(failed *[]string, n int, input string, err error) error {
+		err = fmt.Errorf("line %d (%s): %w", n, input, err)
+		if !flagContinue {
+			return err
+		}
+		*failed = append(*failed, err.Error())
+		return nil
+	}
+
+	// finishInputs writes recorded failures to stderr once the good results
+	// are out, returning a PartialFailureError if there were any.
+	finishInputs := func(failed []string, total int) error {
+		if len(failed) == 0 {
+			return nil
+		}
+		for _, msg := range failed {
+			_, _ = fmt.Fprintln(rootCmd.ErrOrStderr(), "error: "+msg)
+		}
+		rootCmd.SilenceUsage = true // the invocation itself was fine
+		return PartialFailureError{Failed: len(failed), Total: total}
+	}
+
+	// writeItem writes one human list item terminated by newline or, with
+	// --print0, by a single NUL byte.
+	writeItem := func(w io.Writer, item string) error {
+		term := "\n"
+		if flagPrint0 {
+			term = "\x00"
+		}
+		_, err := io.WriteString(w, item+term)
+		return err
+	}
+
+	// progressReporter returns a callback reporting done of total on stderr at
+	// 10% steps and on completion, keeping stdout clean for piping. Progress is
+	// on when auto is set (the command's default) or with --progress, and never
+	// with --no-progress or --quiet.
+	progressReporter := func(total uint64, auto bool) func(done uint64) {
+		if flagQuiet || flagNoProgress || !(auto || flagProgress) || total <= 1 {
+			return func(uint64) {}
+		}
+		every := total / 10
+		if every == 0 {
+			every = 1
+		}
+		errOut := rootCmd.ErrOrStderr()
+		return func(done uint64) {
+			if done%every == 0 || done == total {
+				_, _ = fmt.Fprintf(errOut, "progress: %d/%d (%.0f%%)\n", done, total, float64(done)*100/float64(total))
+			}
+		}
+	}
 
 	// helper for colored text
 	colorize := func(s string) string {
@@ -115,40 +356,73 @@ func NewRootCmd(out io.Writer) *cobra.Command {
 		return "\x1b[36m" + s + "\x1b[0m"
 	}
 
-	// host count formatting
-	formatHostCount := func(n *big.Int) (raw string, power string, approx string) {
-		raw = n.String()
-		// power-of-two detection: n>0 and n&(n-1)==0
-		if n.Sign() > 0 {
-			m := new(big.Int).Sub(n, big.NewInt(1))
-			if new(big.Int).And(m, n).Sign() == 0 { // exact power of two
-				power = fmt.Sprintf("2^%d", n.BitLen()-1)
+	// colorizeType colors s by address category, like colorize.
+	colorizeType := func(t ipv6.AddressType, s string) string {
+		code, ok := typeColors[t]
+		if !ok || !flagColor || format != outHuman {
+			return s
+		}
+		return "\x1b[" + code + "m" + s + "\x1b[0m"
+	}
+
+	// renderTable writes aligned columns for human table output.
+	renderTable := func(headers []string, rows [][]string) error {
+		if flagQuiet {
+			return nil
+		}
+		w := rootCmd.OutOrStdout()
+		widths := make([]int, len(headers))
+		for i, h := range headers {
+			widths[i] = len(h)
+		}
+		for _, row := range rows {
+			for i, cell := range row {
+				if len(cell) > widths[i] {
+					widths[i] = len(cell)
+				}
 			}
 		}
-		// approximate decimal (scientific)
-		if n.Sign() == 0 {
-			approx = "0"
-		} else {
-			ln10 := new(big.Float).SetFloat64(10)
-			bf := new(big.Float).SetInt(n)
-			exp := 0
-			for bf.Cmp(ln10) >= 0 {
-				bf.Quo(bf, ln10)
-				exp++
+		if flagWidth > 0 { // fixed value column so separate runs line up
+			widths[len(widths)-1] = flagWidth
+		}
+		writeRow := func(cells []string) error {
+			parts := make([]string, len(cells))
+			for i, cell := range cells {
+				parts[i] = fmt.Sprintf("%-*s", widths[i], cell)
 			}
-			f, _ := bf.Float64()
-			approx = fmt.Sprintf("%.2fe%d", f, exp)
+			_, err := fmt.Fprintln(w, strings.Join(parts, "  "))
+			return err
 		}
-		return
+		if !flagNoHeader && len(rows) > 0 {
+			if err := writeRow(headers); err != nil {
+				return err
+			}
+		}
+		for _, row := range rows {
+			if err := writeRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
+	// enveloped reports whether json and yaml results get the schema/data wrapper.
+	enveloped := func() bool { return wrapSchema && !flagRaw }
+
 	// Rendering helper closure bound to this command's writer & format.
 	render := func(v any) error {
 		w := rootCmd.OutOrStdout()
 		schemaWrap := func(obj any) any {
-			if format == outJSON || format == outYAML {
+			if list, ok := obj.([]string); ok && flagIndexed {
+				rows := make([]IndexedValue, len(list))
+				for i, s := range list {
+					rows[i] = IndexedValue{i + 1, s}
+				}
+				obj = rows
+			}
+			if (format == outJSON || format == outYAML) && enveloped() {
 				// Always wrap consistently to avoid key collision and provide predictable shape.
-				return map[string]any{"schema": "ip6calc/v1", "data": obj}
+				return fields{{"schema", SchemaVersion}, {"data", obj}}
 			}
 			return obj
 		}
@@ -161,8 +435,8 @@ func NewRootCmd(out io.Writer) *cobra.Command {
 			rv := reflect.ValueOf(v)
 			if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.String {
 				if flagTable {
-					width := 0
-					for i := 0; i < rv.Len(); i++ {
+					width := flagWidth
+					for i := 0; i < rv.Len() && flagWidth == 0; i++ {
 						if l := len(rv.Index(i).String()); l > width {
 							width = l
 						}
@@ -180,30 +454,46 @@ func NewRootCmd(out io.Writer) *cobra.Command {
 					return nil
 				}
 				for i := 0; i < rv.Len(); i++ {
-					if _, err := fmt.Fprintln(w, rv.Index(i).Interface()); err != nil {
+					if err := writeItem(w, rv.Index(i).String()); err != nil {
 						return err
 					}
 				}
 				return nil
 			}
 			if m, ok := v.(map[string]any); ok {
-				// stable key order
-				keys := make([]string, 0, len(m))
-				for k := range m {
-					keys = append(keys, k)
-				}
-				sort.Strings(keys)
-				for _, k := range keys {
-					if _, err := fmt.Fprintf(w, "%s: %v\n", k, m[k]); err != nil {
+				keys := sortedKeys(m)
+				f := make(fields, len(keys))
+				for i, k := range keys {
+					f[i] = field{k, m[k]}
+				}
+				v = f
+			}
+			if f, ok := structFields(v); ok {
+				v = f
+			}
+			if f, ok := v.(fields); ok {
+				if flagTable {
+					rows := make([][]string, len(f))
+					for i, fl := range f {
+						rows[i] = []string{fl.Key, fmt.Sprint(fl.Value)}
+					}
+					return renderTable([]string{"Field", "Value"}, rows)
+				}
+				for _, fl := range f {
+					if _, err := fmt.Fprintf(w, "%s: %v\n", fl.Key, fl.Value); err != nil {
 						return err
 					}
 				}
 				return nil
 			}
-			_, _ = fmt.Fprintln(w, v)
+			return writeItem(w, fmt.Sprint(v))
+		case outTSV:
+			return writeTSV(w, v, !flagNoHeader)
 		case outJSON:
 			enc := json.NewEncoder(w)
-			enc.SetIndent("", "  ")
+			if !flagCompact {
+				enc.SetIndent("", "  ")
+			}
 			return enc.Encode(schemaWrap(v))
 		case outYAML:
 			enc := yaml.NewEncoder(w)
@@ -220,414 +510,2062 @@ func NewRootCmd(out io.Writer) *cobra.Command {
 		return nil
 	}
 
-	readStdinLines := func() ([]string, error) {
-		info, err := os.Stdin.Stat()
-		if err != nil {
-			return nil, err
+	// renderCount prints n as a bare integer in human mode and as {"count": n}
+	// otherwise (used by --count-only).
+	renderCount := func(n *big.Int) error {
+		if format == outHuman {
+			return render(n)
 		}
-		if (info.Mode() & os.ModeCharDevice) != 0 {
-			return nil, nil
+		return render(CountResult{n})
+	}
+
+	// scanStdinLines calls fn for each trimmed, non-empty line of stdin without
+	// buffering the whole input. An interactive terminal yields no lines.
+	scanStdinLines := func(fn func(line string) error) error {
+		in := rootCmd.InOrStdin()
+		if f, ok := in.(*os.File); ok {
+			info, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			if (info.Mode() & os.ModeCharDevice) != 0 {
+				return nil
+			}
 		}
-		scanner := bufio.NewScanner(os.Stdin)
-		var lines []string
+		scanner := bufio.NewScanner(in)
 		for scanner.Scan() {
 			line := strings.TrimSpace(scanner.Text())
-			if line != "" {
-				lines = append(lines, line)
+			if line == "" {
+				continue
+			}
+			if err := fn(line); err != nil {
+				return err
 			}
 		}
-		return lines, scanner.Err()
+		return scanner.Err()
 	}
 
-	// ---- Commands ----
+	readStdinLines := func() ([]string, error) {
+		var lines []string
+		err := scanStdinLines(func(line string) error {
+			lines = append(lines, line)
+			return nil
+		})
+		return lines, err
+	}
 
-	infoCmd := &cobra.Command{Use: "info <IPv6 CIDR or address>", Short: "Show information about an IPv6 address or network", Args: cobra.MaximumNArgs(1), Example: "  ip6calc info 2001:db8::/64\n  ip6calc info 2001:db8::1", RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) == 0 { // try stdin
-			lines, err := readStdinLines()
+	// streamJSONList writes the strings produced by next as a JSON list one
+	// element at a time, byte-for-byte what render would emit for the whole
+	// slice, so large outputs need not be held in memory.
+	streamJSONList := func(next func() (string, bool)) error {
+		bw := bufio.NewWriter(rootCmd.OutOrStdout())
+		indent, nl, sp := "  ", "\n", " "
+		if flagCompact {
+			indent, nl, sp = "", "", ""
+		}
+		// bufio.Writer errors are sticky: a failed write resurfaces on the
+		// next one or on Flush
+		itemIndent, closeIndent, closing := indent, "", "]"
+		if enveloped() {
+			schema, _ := json.Marshal(SchemaVersion)
+			_, _ = fmt.Fprintf(bw, "{%s%s\"schema\":%s%s,%s%s\"data\":%s", nl, indent, sp, schema, nl, indent, sp)
+			itemIndent, closeIndent, closing = indent+indent, indent, "]"+nl+"}"
+		}
+		_ = bw.WriteByte('[')
+		n := 0
+		for item, ok := next(); ok; item, ok = next() {
+			var v any = item
+			if flagIndexed {
+				v = IndexedValue{n + 1, item}
+			}
+			b, err := json.MarshalIndent(v, itemIndent, indent)
+			if flagCompact {
+				b, err = json.Marshal(v)
+			}
 			if err != nil {
 				return err
 			}
-			if len(lines) == 0 {
-				return errors.New("no input")
+			if n > 0 {
+				_ = bw.WriteByte(',')
 			}
-			args = []string{lines[0]}
-		}
-		arg := args[0]
-		if strings.Contains(arg, "/") {
-			c, err := ipv6.ParseCIDR(arg)
-			if err != nil {
+			if _, err := bw.WriteString(nl + itemIndent); err != nil {
 				return err
 			}
-			raw, power, approx := formatHostCount(c.HostCount())
-			out := map[string]any{"network": c.Network().String(), "prefix_length": c.PrefixLength(), "first_host": c.FirstHost().String(), "last_host": c.LastHost().String(), "host_count": raw, "host_count_power": power, "host_count_approx": approx}
-			return render(out)
+			if _, err := bw.Write(b); err != nil {
+				return err
+			}
+			n++
 		}
-		addr, err := ipv6.Parse(arg)
-		if err != nil {
+		if n > 0 {
+			closing = nl + closeIndent + closing
+		}
+		if _, err := bw.WriteString(closing + "\n"); err != nil {
 			return err
 		}
-		exp := addr.Expanded()
-		if flagUpper {
-			exp = addr.ExpandedUpper()
+		return bw.Flush()
+	}
+
+	// streamList renders the strings next yields as a list. Human and json
+	// output are written item by item, as render would write the whole slice;
+	// formats that need the complete list (yaml, tsv, --table) collect it first.
+	streamList := func(next func() (string, bool)) error {
+		switch {
+		case format == outJSON:
+			return streamJSONList(next)
+		case format == outHuman && !flagTable && !flagQuiet:
+			w := rootCmd.OutOrStdout()
+			for item, ok := next(); ok; item, ok = next() {
+				if err := writeItem(w, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		var list []string
+		for item, ok := next(); ok; item, ok = next() {
+			list = append(list, item)
 		}
-		out := map[string]any{"address": addr.String(), "expanded": exp, "reverse": addr.ReverseDNS()}
-		return render(out)
-	}}
+		return render(list)
+	}
 
-	expandCmd := &cobra.Command{Use: "expand [IPv6 address ...]", Short: "Expand compressed IPv6 address(es)", Args: cobra.ArbitraryArgs, Example: "  ip6calc expand 2001:db8::1 2001:db8::2\n  echo 2001:db8::1 | ip6calc expand", RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) == 0 {
+	// cidrItems adapts a CIDR iterator to streamList.
+	cidrItems := func(it ipv6.CIDRIterator) func() (string, bool) {
+		return func() (string, bool) {
+			c, ok := it.Next()
+			if !ok {
+				return "", false
+			}
+			return c.String(), true
+		}
+	}
+
+	// counted passes the running number of items next has yielded to report.
+	counted := func(next func() (string, bool), report func(done uint64)) func() (string, bool) {
+		var n uint64
+		return func() (string, bool) {
+			item, ok := next()
+			if ok {
+				n++
+				report(n)
+			}
+			return item, ok
+		}
+	}
+
+	// readRoutes parses one CIDR per line of the named file, or of stdin when
+	// path is empty, recording bad lines in failed. It also returns the number
+	// of lines read, for finishInputs.
+	readRoutes := func(path string, failed *[]string) ([]ipv6.CIDR, int, error) {
+		var lines []string
+		var err error
+		if path != "" {
+			lines, err = readLinesFile(path)
+		} else {
+			lines, err = readStdinLines()
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		routes := make([]ipv6.CIDR, 0, len(lines))
+		for i, l := range lines {
+			c, err := parseCIDR(l)
+			if err != nil {
+				if err := failInput(failed, i+1, l, err); err != nil {
+					return nil, 0, err
+				}
+				continue
+			}
+			routes = append(routes, c)
+		}
+		return routes, len(lines), nil
+	}
+
+	// ---- Commands ----
+
+	infoCmd := &cobra.Command{Use: "info <IPv6 CIDR or address>", Short: "Show information about an IPv6 address or network", Args: func(cmd *cobra.Command, args []string) error {
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			return nil
+		}
+		return cobra.MaximumNArgs(1)(cmd, args)
+	}, Example: "  ip6calc info 2001:db8::/64\n  ip6calc info 2001:db8::1\n  ip6calc info --keep-host 2001:db8::1/64\n  cat inputs.txt | ip6calc info --all --continue-on-error", RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if len(args) == 0 { // try stdin
 			lines, err := readStdinLines()
 			if err != nil {
 				return err
 			}
+			if len(lines) == 0 {
+				return errors.New("no input")
+			}
 			args = lines
+			if !all {
+				args = lines[:1]
+			}
 		}
-		var list []string
-		for _, a := range args {
-			if a == "" {
-				continue
+		selected, _ := cmd.Flags().GetStringSlice("fields")
+		allowMapped, _ := cmd.Flags().GetBool("allow-v4mapped")
+		keepHost, _ := cmd.Flags().GetBool("keep-host")
+		infoResult := func(arg string) (any, error) {
+			if strings.Contains(arg, "/") {
+				c, err := parseCIDR(arg)
+				if err != nil {
+					return nil, err
+				}
+				raw, power, approx := ipv6.FormatCount(c.HostCount())
+				network := c.Network().String()
+				// color a network only when one category holds all of it
+				if t := c.Base().Type(); t != ipv6.TypeUnclassified {
+					if n, _ := t.Network(); n.ContainsCIDR(c) {
+						network = colorizeType(t, network)
+					}
+				}
+				host := ""
+				if keepHost {
+					h, err := ipv6.ParseHostCIDR(arg)
+					if err != nil {
+						return nil, err
+					}
+					host = h.String()
+				}
+				return InfoResult{
+					Network:         network,
+					Host:            host,
+					PrefixLength:    c.PrefixLength(),
+					Netmask:         c.Netmask().String(),
+					Hostmask:        c.HostMask().String(),
+					FirstHost:       c.FirstHost().String(),
+					LastHost:        c.LastHost().String(),
+					HostCount:       raw,
+					HostCountPower:  power,
+					HostCountApprox: approx,
+					UsableCount:     c.UsableCount(true).String(),
+				}, nil
+			}
+			var addr ipv6.Address
+			var mapped bool
+			var err error
+			if allowMapped {
+				addr, mapped, err = ipv6.ParseAllowV4Mapped(arg)
+			} else {
+				addr, err = ipv6.Parse(arg)
 			}
-			addr, err := ipv6.Parse(a)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			res := AddressInfoResult{Address: colorizeType(addr.Type(), addr.String()), Expanded: addr.Expanded(), Reverse: addr.ReverseDNS()}
+			if flagUpper {
+				res.Expanded = addr.ExpandedUpper()
+			}
+			if mapped {
+				v4, _ := addr.IPv4Mapped()
+				res.IPv4Mapped = v4.String()
+			}
+			if strings.Contains(arg, ".") {
+				res.EmbeddedIPv4 = addr.EmbeddedIPv4().String()
 			}
-			list = append(list, addr.Expanded())
+			return res, nil
 		}
-		return render(list)
-	}}
-
-	compressCmd := &cobra.Command{Use: "compress [IPv6 address ...]", Short: "Compress IPv6 address(es)", Args: cobra.ArbitraryArgs, Example: "  ip6calc compress 2001:0db8:0000:0000:0000:0000:0000:0001", RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) == 0 {
-			lines, err := readStdinLines()
+		// infoFields flattens a result for --fields selection and --all lists,
+		// whose entries mix network and address results.
+		infoFields := func(arg string) (fields, error) {
+			res, err := infoResult(arg)
+			if err != nil {
+				return nil, err
+			}
+			out, _ := structFields(res)
+			if len(selected) > 0 {
+				return out.pick(selected)
+			}
+			return out, nil
+		}
+		if !all {
+			if len(selected) == 0 {
+				res, err := infoResult(args[0])
+				if err != nil {
+					return err
+				}
+				return render(res)
+			}
+			out, err := infoFields(args[0])
 			if err != nil {
 				return err
 			}
-			args = lines
+			return render(out)
 		}
-		var list []string
-		for _, a := range args {
-			if a == "" {
+		var results []fields
+		var failed []string
+		for i, arg := range args {
+			out, err := infoFields(arg)
+			if err != nil {
+				if err := failInput(&failed, i+1, arg, err); err != nil {
+					return err
+				}
 				continue
 			}
-			addr, err := ipv6.Parse(a)
+			results = append(results, out)
+		}
+		if format == outHuman {
+			for i, out := range results {
+				if i > 0 && !flagQuiet {
+					if _, err := fmt.Fprintln(rootCmd.OutOrStdout()); err != nil {
+						return err
+					}
+				}
+				if err := render(out); err != nil {
+					return err
+				}
+			}
+		} else if err := render(results); err != nil {
+			return err
+		}
+		return finishInputs(failed, len(args))
+	}}
+
+	infoCmd.Flags().Bool("allow-v4mapped", false, "accept IPv4-mapped addresses (::ffff:a.b.c.d)")
+	infoCmd.Flags().StringSlice("fields", nil, "only output these fields, in this order (e.g. network,host_count)")
+	infoCmd.Flags().Bool("all", false, "process every argument or stdin line, emitting one result each")
+	infoCmd.Flags().Bool("keep-host", false, "for a network, also show the address as given with its prefix (e.g. 2001:db8::1/64)")
+
+	maskCmd := &cobra.Command{Use: "mask <IPv6 CIDR>", Short: "Show netmask and hostmask (wildcard) for a prefix", Args: cobra.ExactArgs(1), Example: "  ip6calc mask 2001:db8::/64", RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		return render(MaskResult{c.PrefixLength(), c.Netmask().String(), c.HostMask().String()})
+	}}
+
+	tableCmd := &cobra.Command{Use: "table", Aliases: []string{"prefixes"}, Short: "Reference table of prefix lengths, host counts and netmasks", Args: cobra.NoArgs, Example: "  ip6calc table\n  ip6calc prefixes -o json", RunE: func(cmd *cobra.Command, args []string) error {
+		zero, err := ipv6.Parse("::")
+		if err != nil {
+			return err
+		}
+		rows := make([]PrefixRow, 0, 129)
+		for plen := 0; plen <= 128; plen++ {
+			c, err := ipv6.NewCIDR(zero, plen)
 			if err != nil {
 				return err
 			}
-			list = append(list, addr.String())
+			raw, power, approx := ipv6.FormatCount(c.HostCount())
+			rows = append(rows, PrefixRow{plen, c.Netmask().String(), raw, power, approx})
 		}
-		return render(list)
+		if format == outHuman {
+			cells := make([][]string, len(rows))
+			for i, r := range rows {
+				cells[i] = []string{"/" + strconv.Itoa(r.PrefixLength), r.HostCountPower, r.HostCountApprox, r.Netmask}
+			}
+			return renderTable([]string{"Prefix", "Hosts", "Approx", "Netmask"}, cells)
+		}
+		return render(rows)
 	}}
 
-	// Split command adjusted to allow equal new-prefix and handle ErrSplitExcessive.
-	splitCmd := &cobra.Command{Use: "split <IPv6 CIDR>", Short: "Split a network into smaller subnets", Args: cobra.ExactArgs(1), Example: "  # Split /48 into /52\n  ip6calc split 2001:db8::/48 --new-prefix 52", RunE: func(cmd *cobra.Command, args []string) error {
-		newPrefix, _ := cmd.Flags().GetInt("new-prefix")
-		force, _ := cmd.Flags().GetBool("force")
-		c, err := ipv6.ParseCIDR(args[0])
+	positionCmd := &cobra.Command{Use: "position <IPv6 CIDR> <IPv6 address>", Short: "Zero-based index of an address within a network", Args: cobra.ExactArgs(2), Example: "  ip6calc position 2001:db8::/64 2001:db8::ff", RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := parseCIDR(args[0])
 		if err != nil {
 			return err
 		}
-		if newPrefix < c.PrefixLength() || newPrefix > 128 {
-			return fmt.Errorf("invalid --new-prefix: must be >= original (%d) and <=128", c.PrefixLength())
+		addr, err := ipv6.Parse(args[1])
+		if err != nil {
+			return err
 		}
-		// delegate capacity / sanity checks to library after computing diff
-		diff := newPrefix - c.PrefixLength()
-		if diff >= 63 { // matches library guard preventing overflow & unrealistic splits
-			return ipv6.ErrSplitExcessive
+		pos, err := c.Position(addr)
+		if err != nil {
+			return err
 		}
-		// compute parts using uint64 for safety
-		parts := uint64(1)
-		if diff > 0 {
-			parts = uint64(1) << uint(diff)
+		pct, err := c.PercentileOf(addr)
+		if err != nil {
+			return err
 		}
-		warnThreshold := getThreshold("IP6CALC_SPLIT_WARN_THRESHOLD", defaultSplitWarnThreshold)
-		forceThreshold := getThreshold("IP6CALC_SPLIT_FORCE_THRESHOLD", defaultSplitForceThreshold)
-		if parts > uint64(forceThreshold) && !force {
-			return ErrSplitTooLarge
+		return render(PositionResult{pos.String(), pct, c.IsNetworkAddress(addr), c.IsLastAddress(addr)})
+	}}
+
+	atCmd := &cobra.Command{Use: "at <IPv6 CIDR>", Short: "Address at a percentile of a network", Args: cobra.ExactArgs(1), Example: "  ip6calc at 2001:db8::/64 --percent 50", RunE: func(cmd *cobra.Command, args []string) error {
+		percent, _ := cmd.Flags().GetFloat64("percent")
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
 		}
-		if parts > uint64(warnThreshold) && format == outHuman && !force && diff > 0 {
-			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: generating %d subnets (use --force to suppress)\n", parts)
+		a, err := c.AddressAtPercentile(percent)
+		if err != nil {
+			return err
 		}
-		// For very large outputs, stream instead of buffering entire slice for human output.
-		streamThreshold := uint64(forceThreshold) / 2
-		if parts > streamThreshold && format == outHuman && !force && !flagTable && diff > 0 {
-			it, err := c.SubnetIterator(newPrefix)
+		return render(a.String())
+	}}
+	atCmd.Flags().Float64("percent", 0, "percentile into the network, 0-100")
+
+	countCmd := &cobra.Command{Use: "count <IPv6 CIDR>", Short: "Number of subnets of a prefix length in a network", Args: cobra.ExactArgs(1), Example: "  ip6calc count 2001:db8::/48 --prefix 64", RunE: func(cmd *cobra.Command, args []string) error {
+		prefix, _ := cmd.Flags().GetInt("prefix")
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		n, err := c.CountSubnets(prefix)
+		if err != nil {
+			return err
+		}
+		raw, power, approx := ipv6.FormatCount(n)
+		return render(SubnetCountResult{prefix, raw, power, approx})
+	}}
+	countCmd.Flags().Int("prefix", 64, "prefix length of the subnets to count")
+
+	expandCmd := &cobra.Command{Use: "expand [IPv6 address ...]", Short: "Expand compressed IPv6 address(es)", Args: cobra.ArbitraryArgs, Example: "  ip6calc expand 2001:db8::1 2001:db8::2\n  echo 2001:db8::1 | ip6calc expand\n  ip6calc expand --nibble 2001:db8::1\n  ip6calc expand --sep - 2001:db8::1", RunE: func(cmd *cobra.Command, args []string) error {
+		nibble, _ := cmd.Flags().GetBool("nibble")
+		sep, _ := cmd.Flags().GetString("sep")
+		if len(args) == 0 {
+			lines, err := readStdinLines()
 			if err != nil {
 				return err
 			}
-			w := rootCmd.OutOrStdout()
-			progressEvery := int(parts / 10)
-			if progressEvery == 0 {
-				progressEvery = 1
-			}
-			count := 0
-			for {
-				sub, ok := it.Next()
-				if !ok {
-					break
-				}
-				count++
-				if _, err := fmt.Fprintln(w, sub.String()); err != nil {
+			args = lines
+		}
+		var list, failed []string
+		for i, a := range args {
+			if a == "" {
+				continue
+			}
+			addr, err := ipv6.Parse(a)
+			if err != nil {
+				if err := failInput(&failed, i+1, a, err); err != nil {
 					return err
 				}
-				if count%progressEvery == 0 && parts > 1 {
-					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "progress: %d/%d (%.0f%%)\n", count, parts, float64(count)*100/float64(parts))
-				}
+				continue
 			}
-			return nil
+			if nibble {
+				list = append(list, addr.DottedNibble())
+				continue
+			}
+			list = append(list, addr.ExpandedSep(sep))
 		}
-		// Use library Split (handles equality case now)
-		subs, err := c.Split(newPrefix)
-		if err != nil {
+		if err := render(list); err != nil {
 			return err
 		}
-		var list []string
-		for _, s := range subs {
-			list = append(list, s.String())
+		return finishInputs(failed, len(args))
+	}}
+	expandCmd.Flags().Bool("nibble", false, "emit the forward dotted-nibble form (32 nibbles, no suffix)")
+	expandCmd.Flags().String("sep", ":", "separator between the 16-bit blocks (e.g. - or empty)")
+	expandCmd.MarkFlagsMutuallyExclusive("nibble", "sep")
+
+	compressCmd := &cobra.Command{Use: "compress [IPv6 address ...]", Short: "Compress IPv6 address(es)", Args: cobra.ArbitraryArgs, Example: "  ip6calc compress 2001:0db8:0000:0000:0000:0000:0000:0001", RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			lines, err := readStdinLines()
+			if err != nil {
+				return err
+			}
+			args = lines
 		}
-		return render(list)
+		var list, failed []string
+		for i, a := range args {
+			if a == "" {
+				continue
+			}
+			addr, err := ipv6.Parse(a)
+			if err != nil {
+				if err := failInput(&failed, i+1, a, err); err != nil {
+					return err
+				}
+				continue
+			}
+			list = append(list, addr.String())
+		}
+		if err := render(list); err != nil {
+			return err
+		}
+		return finishInputs(failed, len(args))
 	}}
-	splitCmd.Flags().Int("new-prefix", 0, "new prefix length to split into (must be >= original prefix)")
-	splitCmd.Flags().Bool("force", false, "proceed even if subnet count exceeds large threshold")
 
-	summarizeCmd := &cobra.Command{Use: "summarize <CIDR...>", Short: "Summarize a list of CIDRs", Args: cobra.MinimumNArgs(1), Example: "  ip6calc summarize 2001:db8::/65 2001:db8:0:0:8000::/65", RunE: func(cmd *cobra.Command, args []string) error {
-		failOverlap, _ := cmd.Flags().GetBool("fail-on-overlap")
-		cidrs := make([]ipv6.CIDR, 0, len(args))
-		for _, a := range args {
-			c, err := ipv6.ParseCIDR(a)
+	dedupCmd := &cobra.Command{Use: "dedup [address or CIDR ...]", Short: "Normalize addresses and CIDRs and drop repeats", Args: cobra.ArbitraryArgs, Example: "  cat addrs.txt | ip6calc dedup\n  cat routes.txt | ip6calc dedup --sort | ip6calc summarize", RunE: func(cmd *cobra.Command, args []string) error {
+		sortOut, _ := cmd.Flags().GetBool("sort")
+		if len(args) == 0 {
+			lines, err := readStdinLines()
 			if err != nil {
 				return err
 			}
-			cidrs = append(cidrs, c)
+			args = lines
 		}
-		if failOverlap {
-			for i := 0; i < len(cidrs); i++ {
-				for j := i + 1; j < len(cidrs); j++ {
-					if cidrs[i].Overlaps(cidrs[j]) { // treat any overlap (including containment) as error
-						return OverlapError{cidrs[i], cidrs[j]}
+		// an address and the /128 holding it stay distinct: plen is -1 for addresses
+		type entry struct {
+			addr ipv6.Address
+			plen int
+		}
+		var parsed []entry
+		var addrs []ipv6.Address
+		var cidrs []ipv6.CIDR
+		var failed []string
+		for i, a := range args {
+			if strings.Contains(a, "/") {
+				c, err := parseCIDR(a)
+				if err != nil {
+					if err := failInput(&failed, i+1, a, err); err != nil {
+						return err
 					}
+					continue
+				}
+				parsed = append(parsed, entry{c.Base(), c.PrefixLength()})
+				cidrs = append(cidrs, c)
+				continue
+			}
+			addr, err := ipv6.Parse(a)
+			if err != nil {
+				if err := failInput(&failed, i+1, a, err); err != nil {
+					return err
 				}
+				continue
 			}
+			parsed = append(parsed, entry{addr, -1})
+			addrs = append(addrs, addr)
 		}
-		res := ipv6.Summarize(cidrs)
-		list := make([]string, len(res))
-		for i, s := range res {
-			list[i] = s.String()
+		// the library keeps first occurrences in order; walking the input
+		// against them restores the interleaving of addresses and CIDRs, as a
+		// repeat never equals the next first occurrence still to come
+		addrs, cidrs = ipv6.DedupAddresses(addrs), ipv6.DedupCIDRs(cidrs)
+		var entries []entry
+		for _, e := range parsed {
+			if e.plen < 0 && len(addrs) > 0 && addrs[0].Compare(e.addr) == 0 {
+				entries, addrs = append(entries, e), addrs[1:]
+			} else if e.plen >= 0 && len(cidrs) > 0 && cidrs[0].Base().Compare(e.addr) == 0 && cidrs[0].PrefixLength() == e.plen {
+				entries, cidrs = append(entries, e), cidrs[1:]
+			}
 		}
-		return render(list)
+		if sortOut {
+			sort.SliceStable(entries, func(i, j int) bool {
+				if c := entries[i].addr.Compare(entries[j].addr); c != 0 {
+					return c < 0
+				}
+				return entries[i].plen < entries[j].plen
+			})
+		}
+		list := make([]string, len(entries))
+		for i, e := range entries {
+			if e.plen < 0 {
+				list[i] = e.addr.String()
+			} else {
+				list[i] = e.addr.String() + "/" + strconv.Itoa(e.plen)
+			}
+		}
+		if err := render(list); err != nil {
+			return err
+		}
+		return finishInputs(failed, len(args))
 	}}
-	summarizeCmd.Flags().Bool("fail-on-overlap", false, "fail if any overlap (including containment) present")
+	dedupCmd.Flags().Bool("sort", false, "emit in address order instead of input order")
 
-	reverseCmd := &cobra.Command{Use: "reverse <IPv6 address>", Short: "Produce reverse DNS ip6.arpa name", Args: cobra.ExactArgs(1), Example: "  ip6calc reverse 2001:db8::1\n  ip6calc reverse --zone 2001:db8::1", RunE: func(cmd *cobra.Command, args []string) error {
-		zone, _ := cmd.Flags().GetBool("zone")
-		addr, err := ipv6.Parse(args[0])
+	histogramCmd := &cobra.Command{Use: "histogram [CIDR ...]", Short: "Count CIDRs per prefix length", Args: cobra.ArbitraryArgs, Example: "  ip6calc histogram --from allocations.txt\n  cat routes.txt | ip6calc -o json histogram", RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		if len(args) == 0 {
+			var err error
+			if from != "" {
+				args, err = readLinesFile(from)
+			} else {
+				args, err = readStdinLines()
+			}
+			if err != nil {
+				return err
+			}
+		} else if from != "" {
+			return errors.New("--from and CIDR arguments are mutually exclusive")
+		}
+		var cidrs []ipv6.CIDR
+		var failed []string
+		for i, a := range args {
+			c, err := parseCIDR(a)
+			if err != nil {
+				if err := failInput(&failed, i+1, a, err); err != nil {
+					return err
+				}
+				continue
+			}
+			cidrs = append(cidrs, c)
+		}
+		hist := ipv6.PrefixHistogram(cidrs)
+		plens := make([]int, 0, len(hist))
+		for p := range hist {
+			plens = append(plens, p)
+		}
+		sort.Ints(plens)
+		res := fields{} // keyed by prefix length, ascending
+		for _, p := range plens {
+			res = append(res, field{strconv.Itoa(p), hist[p]})
+		}
+		if err := render(res); err != nil {
+			return err
+		}
+		return finishInputs(failed, len(args))
+	}}
+	histogramCmd.Flags().String("from", "", "read CIDRs from this file, one per line (default: arguments or stdin)")
+	groupCmd := &cobra.Command{Use: "group [address ...]", Short: "Group addresses by their containing network", Args: cobra.ArbitraryArgs, Example: "  cut -d' ' -f1 access.log | ip6calc group --prefix 48\n  ip6calc group --prefix 64 2001:db8::1 2001:db8:0:1::1", RunE: func(cmd *cobra.Command, args []string) error {
+		prefix, _ := cmd.Flags().GetInt("prefix")
+		if len(args) == 0 {
+			lines, err := readStdinLines()
+			if err != nil {
+				return err
+			}
+			args = lines
+		}
+		var addrs []ipv6.Address
+		var failed []string
+		for i, a := range args {
+			addr, err := ipv6.Parse(a)
+			if err != nil {
+				if err := failInput(&failed, i+1, a, err); err != nil {
+					return err
+				}
+				continue
+			}
+			addrs = append(addrs, addr)
+		}
+		groups, err := ipv6.GroupByPrefix(addrs, prefix)
 		if err != nil {
 			return err
 		}
-		rev := addr.ReverseDNS()
-		if zone {
-			rev = strings.TrimSuffix(rev, ".")
+		res := make([]GroupResult, len(groups))
+		for i, g := range groups {
+			res[i] = GroupResult{Network: g.Network.String(), Members: make([]string, len(g.Members))}
+			for j, m := range g.Members {
+				res[i].Members[j] = m.String()
+			}
+		}
+		if format != outHuman {
+			if err := render(res); err != nil {
+				return err
+			}
+			return finishInputs(failed, len(args))
+		}
+		if !flagQuiet {
+			w := rootCmd.OutOrStdout()
+			for _, g := range res {
+				if err := writeItem(w, colorize(g.Network)); err != nil {
+					return err
+				}
+				for _, m := range g.Members {
+					if err := writeItem(w, "  "+m); err != nil {
+						return err
+					}
+				}
+			}
 		}
-		return render(rev)
+		return finishInputs(failed, len(args))
 	}}
-	reverseCmd.Flags().Bool("zone", false, "omit trailing dot for zonefile usage")
+	groupCmd.Flags().Int("prefix", 64, "prefix length of the grouping networks")
 
-	toIntCmd := &cobra.Command{Use: "to-int <IPv6 address>", Short: "Convert IPv6 address to integer", Args: cobra.ExactArgs(1), Example: "  ip6calc to-int 2001:db8::1", RunE: func(cmd *cobra.Command, args []string) error {
-		addr, err := ipv6.Parse(args[0])
+	treeCmd := &cobra.Command{Use: "tree <IPv6 CIDR>", Short: "Subnet tree, halving one bit per level", Args: cobra.ExactArgs(1), Example: "  ip6calc tree 2001:db8::/48 --depth 3\n  ip6calc tree 2001:db8::/48 --depth 2 -o json", RunE: func(cmd *cobra.Command, args []string) error {
+		depth, _ := cmd.Flags().GetInt("depth")
+		c, err := parseCIDR(args[0])
 		if err != nil {
 			return err
 		}
-		return render(addr.BigInt().String())
-	}}
-
-	fromIntCmd := &cobra.Command{Use: "from-int <integer>", Short: "Convert integer to IPv6 address", Args: cobra.ExactArgs(1), Example: "  ip6calc to-int 2001:db8::1 | ip6calc from-int", RunE: func(cmd *cobra.Command, args []string) error {
-		bi, ok := new(big.Int).SetString(args[0], 10)
-		if !ok {
-			return errors.New("invalid integer")
+		if depth > maxTreeDepth {
+			return fmt.Errorf("%w: --depth %d exceeds %d", ipv6.ErrSplitExcessive, depth, maxTreeDepth)
 		}
-		addr, err := ipv6.AddressFromBigInt(bi)
+		if format == outHuman { // print while walking; nothing is kept
+			w := rootCmd.OutOrStdout()
+			var writeErr error
+			err := ipv6.WalkSubnetTree(c, depth, func(n ipv6.CIDR, level int) bool {
+				if !flagQuiet && writeErr == nil {
+					_, writeErr = fmt.Fprintf(w, "%s%s\n", strings.Repeat("  ", level), colorize(n.String()))
+				}
+				return writeErr == nil
+			})
+			if err != nil {
+				return err
+			}
+			return writeErr
+		}
+		var path []*TreeNode // path[level] is the node being filled at that level
+		var root TreeNode
+		err = ipv6.WalkSubnetTree(c, depth, func(n ipv6.CIDR, level int) bool {
+			node := TreeNode{Network: n.String()}
+			if level == 0 {
+				root = node
+				path = append(path[:0], &root)
+				return true
+			}
+			parent := path[level-1]
+			parent.Children = append(parent.Children, node)
+			path = append(path[:level], &parent.Children[len(parent.Children)-1])
+			return true
+		})
 		if err != nil {
 			return err
 		}
-		return render(addr.String())
+		return render(root)
 	}}
+	treeCmd.Flags().Int("depth", 1, fmt.Sprintf("levels below the network to show (at most %d)", maxTreeDepth))
 
-	rangeCmd := &cobra.Command{Use: "range <start-end>", Short: "Cover address range with minimal CIDRs", Args: cobra.ExactArgs(1), Example: "  ip6calc range 2001:db8::1-2001:db8::ff", RunE: func(cmd *cobra.Command, args []string) error {
-		parts := strings.Split(args[0], "-")
-		if len(parts) != 2 {
-			return errors.New("invalid range format")
+	bitsCmd := &cobra.Command{Use: "bits <IPv6 address>", Short: "Show the 128-bit binary representation", Args: cobra.ExactArgs(1), Example: "  ip6calc bits 2001:db8::1\n  ip6calc bits 2001:db8::1 --group nibble", RunE: func(cmd *cobra.Command, args []string) error {
+		group, _ := cmd.Flags().GetString("group")
+		var size int
+		sep := " "
+		switch group {
+		case "nibble":
+			size = 4
+		case "byte":
+			size = 8
+		case "hextet":
+			size, sep = 16, ":" // same grouping as Expanded
+		default:
+			return fmt.Errorf("invalid --group: %s (want nibble|byte|hextet)", group)
 		}
-		start, err := ipv6.Parse(parts[0])
+		addr, err := ipv6.Parse(args[0])
 		if err != nil {
 			return err
 		}
-		end, err := ipv6.Parse(parts[1])
+		b := addr.Bits()
+		groups := make([]string, 0, len(b)/size)
+		for i := 0; i < len(b); i += size {
+			groups = append(groups, b[i:i+size])
+		}
+		return render(strings.Join(groups, sep))
+	}}
+	bitsCmd.Flags().String("group", "hextet", "bit grouping: nibble|byte|hextet")
+
+	setbitCmd := &cobra.Command{Use: "setbit <IPv6 address>", Short: "Set or clear a single bit (positions are MSB-first, 0..127)", Args: cobra.ExactArgs(1), Example: "  ip6calc setbit 2001:db8:: --pos 127 --val 1", RunE: func(cmd *cobra.Command, args []string) error {
+		pos, _ := cmd.Flags().GetInt("pos")
+		val, _ := cmd.Flags().GetUint("val")
+		addr, err := ipv6.Parse(args[0])
 		if err != nil {
 			return err
 		}
-		cover, err := ipv6.CoverRange(start, end)
+		res, err := addr.SetBit(pos, val)
 		if err != nil {
 			return err
 		}
-		list := make([]string, len(cover))
-		for i, c := range cover {
-			list[i] = c.String()
-		}
-		return render(list)
+		return render(res.String())
 	}}
+	setbitCmd.Flags().Int("pos", 0, "bit position, 0 = most significant bit")
+	setbitCmd.Flags().Uint("val", 1, "bit value: 0 or 1")
 
-	supernetCmd := &cobra.Command{Use: "supernet <CIDR...>", Short: "Smallest CIDR containing all", Args: cobra.MinimumNArgs(1), Example: "  ip6calc supernet 2001:db8::/65 2001:db8:0:0:8000::/65", RunE: func(cmd *cobra.Command, args []string) error {
-		var list []ipv6.CIDR
-		for _, a := range args {
-			c, err := ipv6.ParseCIDR(a)
+	bitwiseCmd := &cobra.Command{Use: "bitwise", Short: "Bitwise AND/OR/XOR of two addresses"}
+	for _, op := range []struct {
+		name  string
+		apply func(a, b ipv6.Address) ipv6.Address
+	}{
+		{"and", ipv6.Address.And},
+		{"or", ipv6.Address.Or},
+		{"xor", ipv6.Address.Xor},
+	} {
+		bitwiseCmd.AddCommand(&cobra.Command{Use: op.name + " <a> <b>", Short: "Bitwise " + strings.ToUpper(op.name) + " of two addresses", Args: cobra.ExactArgs(2), Example: "  ip6calc bitwise " + op.name + " 2001:db8::1 ::ffff:ffff", RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := ipv6.Parse(args[0])
 			if err != nil {
 				return err
 			}
-			list = append(list, c)
-		}
-		res, err := ipv6.Supernet(list)
+			b, err := ipv6.Parse(args[1])
+			if err != nil {
+				return err
+			}
+			return render(op.apply(a, b).String())
+		}})
+	}
+
+	hashCmd := &cobra.Command{Use: "hash <IPv6 address>", Short: "Stable 64-bit hash of an address (FNV-1a)", Args: cobra.ExactArgs(1), Example: "  ip6calc hash 2001:db8::1", RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := ipv6.Parse(args[0])
 		if err != nil {
 			return err
 		}
-		return render(res.String())
+		h := addr.Hash64()
+		return render(HashResult{strconv.FormatUint(h, 10), fmt.Sprintf("%016x", h)})
 	}}
 
-	enumerateCmd := &cobra.Command{Use: "enumerate <CIDR>", Short: "Enumerate sample addresses", Args: cobra.ExactArgs(1), Example: "  ip6calc enumerate 2001:db8::/64 --limit 5 --stride 16", RunE: func(cmd *cobra.Command, args []string) error {
-		limit, _ := cmd.Flags().GetInt("limit")
-		stride, _ := cmd.Flags().GetInt("stride")
-		if limit <= 0 {
-			return errors.New("limit must be >0")
+	explainCmd := &cobra.Command{Use: "explain <IPv6 CIDR>", Short: "Walk through the subnet calculation step by step", Args: cobra.ExactArgs(1), Example: "  ip6calc explain 2001:db8::/64", RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
 		}
-		if stride <= 0 {
-			return errors.New("stride must be >0")
+		plen, hostBits := c.PrefixLength(), 128-c.PrefixLength()
+		bitStr := c.Base().Bits()
+		next, prev := "none", "none"
+		nextDetail, prevDetail := "the network ends at the top of the address space", "the network starts at ::"
+		top, _ := ipv6.Parse("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+		if c.LastHost().Compare(top) != 0 {
+			next, nextDetail = c.Next().String(), fmt.Sprintf("base + 2^%d (one network size)", hostBits)
 		}
-		c, err := ipv6.ParseCIDR(args[0])
-		if err != nil {
+		if !c.Base().IsUnspecified() {
+			prev, prevDetail = c.Prev().String(), fmt.Sprintf("base - 2^%d (one network size)", hostBits)
+		}
+		steps := []ExplainStep{
+			{1, "Base address", c.Base().String(), "expanded: " + c.Base().Expanded()},
+			{2, "Binary of the base", bitStr[:plen] + "|" + bitStr[plen:], fmt.Sprintf("%d network bits before |, %d host bits after", plen, hostBits)},
+			{3, "Netmask", c.Netmask().String(), fmt.Sprintf("the first %d bits set; ANDing any member address with it yields the base", plen)},
+			{4, "Host count", c.HostCount().String(), fmt.Sprintf("2^%d addresses (2 to the number of host bits)", hostBits)},
+			{5, "First address", c.FirstHost().String(), "all host bits 0"},
+			{6, "Last address", c.LastHost().String(), "all host bits 1"},
+			{7, "Next network", next, nextDetail},
+			{8, "Previous network", prev, prevDetail},
+		}
+		if format != outHuman {
+			return render(ExplainResult{c.String(), steps})
+		}
+		if flagQuiet {
+			return nil
+		}
+		w := rootCmd.OutOrStdout()
+		if _, err := fmt.Fprintf(w, "Explaining %s\n", colorize(c.String())); err != nil {
 			return err
 		}
-		var list []string
-		for i := 0; i < limit; i++ {
-			delta := new(big.Int).Mul(big.NewInt(int64(stride)), big.NewInt(int64(i)))
-			addr := c.FirstHost().Add(delta)
-			if !c.ContainsAddress(addr) {
-				break
+		for _, st := range steps {
+			if _, err := fmt.Fprintf(w, "\n%d. %s\n   %s\n   (%s)\n", st.Step, st.Title, st.Value, st.Detail); err != nil {
+				return err
 			}
-			list = append(list, addr.String())
 		}
-		return render(list)
+		return nil
 	}}
-	enumerateCmd.Flags().Int("limit", 10, "maximum number of addresses to emit")
-	enumerateCmd.Flags().Int("stride", 1, "step between successive addresses")
 
-	randomCmd := &cobra.Command{Use: "random", Short: "Random address or subnet"}
-	// dynamic completion for random subcommands
-	randomCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		if len(args) == 0 {
-			return []string{"address", "subnet"}, cobra.ShellCompDirectiveNoFileComp
-		}
-		return nil, cobra.ShellCompDirectiveNoFileComp
-	}
-	randomAddrCmd := &cobra.Command{Use: "address <CIDR>", Short: "Random address(es) in CIDR", Args: cobra.ExactArgs(1), RunE: func(cmd *cobra.Command, args []string) error {
-		count, _ := cmd.Flags().GetInt("count")
-		if count <= 0 {
-			return errors.New("count must be >0")
+	multicastCmd := &cobra.Command{Use: "multicast <IPv6 address>", Short: "Decode multicast flags and scope", Args: cobra.ExactArgs(1), Example: "  ip6calc multicast ff02::1", RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := ipv6.Parse(args[0])
+		if err != nil {
+			return err
 		}
-		c, err := ipv6.ParseCIDR(args[0])
+		mi, err := addr.MulticastInfo()
 		if err != nil {
 			return err
 		}
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		var list []string
-		for i := 0; i < count; i++ {
-			list = append(list, ipv6.RandomAddressInCIDR(c, r).String())
+		return render(MulticastResult{mi.Flags, mi.Transient, mi.Prefix, mi.Rendezvous, mi.Scope, mi.ScopeName})
+	}}
+
+	solicitedNodeCmd := &cobra.Command{Use: "solicited-node <IPv6 address>", Short: "Solicited-node multicast address for a unicast address", Args: cobra.ExactArgs(1), Example: "  ip6calc solicited-node fe80::21b:21ff:fe3a:4b5c", RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := ipv6.Parse(args[0])
+		if err != nil {
+			return err
 		}
-		return render(list)
+		return render(ipv6.SolicitedNodeMulticast(addr).String())
 	}}
-	randomAddrCmd.Flags().Int("count", 1, "number of random addresses")
-	randomSubnetCmd := &cobra.Command{Use: "subnet <CIDR>", Short: "Random subnet in CIDR", Args: cobra.ExactArgs(1), RunE: func(cmd *cobra.Command, args []string) error {
-		count, _ := cmd.Flags().GetInt("count")
+
+	splitCmd := &cobra.Command{Use: "split <IPv6 CIDR>", Short: "Split a network into smaller subnets", Args: cobra.ExactArgs(1), Example: "  # Split /48 into /52\n  ip6calc split 2001:db8::/48 --new-prefix 52", RunE: func(cmd *cobra.Command, args []string) error {
 		newPrefix, _ := cmd.Flags().GetInt("new-prefix")
-		if count <= 0 {
-			return errors.New("count must be >0")
-		}
-		c, err := ipv6.ParseCIDR(args[0])
+		force, _ := cmd.Flags().GetBool("force")
+		c, err := parseCIDR(args[0])
 		if err != nil {
 			return err
 		}
-		if newPrefix == 0 {
+		if cmd.Flags().Changed("count") {
+			if cmd.Flags().Changed("new-prefix") {
+				return errors.New("--count and --new-prefix are mutually exclusive")
+			}
+			count, _ := cmd.Flags().GetInt("count")
+			if newPrefix, err = c.PrefixForCount(count); err != nil {
+				return err
+			}
+		}
+		if newPrefix < c.PrefixLength() || newPrefix > 128 {
+			return fmt.Errorf("invalid --new-prefix: must be >= original (%d) and <=128", c.PrefixLength())
+		}
+		// delegate capacity / sanity checks to library after computing diff
+		diff := newPrefix - c.PrefixLength()
+		if diff >= 63 { // matches library guard preventing overflow & unrealistic splits
+			return ipv6.ErrSplitExcessive
+		}
+		// compute parts using uint64 for safety
+		parts := uint64(1)
+		if diff > 0 {
+			parts = uint64(1) << uint(diff)
+		}
+		warnThreshold := getThreshold("IP6CALC_SPLIT_WARN_THRESHOLD", defaultSplitWarnThreshold)
+		forceThreshold := getThreshold("IP6CALC_SPLIT_FORCE_THRESHOLD", defaultSplitForceThreshold)
+		if validate, _ := cmd.Flags().GetBool("validate"); validate {
+			// dry run: report the threshold decision without honouring --force
+			if diff > 0 && parts > ipv6.MaxSplitParts {
+				return ipv6.ErrSplitExcessive
+			}
+			forceRequired := parts > uint64(forceThreshold)
+			if format == outHuman {
+				msg := fmt.Sprintf("ok, %d subnets", parts)
+				if forceRequired {
+					msg += " (requires --force)"
+				}
+				return render(msg)
+			}
+			return render(SplitValidateResult{true, parts, forceRequired})
+		}
+		if parts > uint64(forceThreshold) && !force {
+			return ErrSplitTooLarge
+		}
+		if parts > uint64(warnThreshold) && format == outHuman && !force && diff > 0 {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: generating %d subnets (use --force to suppress)\n", parts)
+		}
+		if countOnly, _ := cmd.Flags().GetBool("count-only"); countOnly {
+			return renderCount(new(big.Int).SetUint64(parts))
+		}
+		it, err := c.SubnetIterator(newPrefix)
+		if err != nil {
+			return err
+		}
+		// progress is shown unasked only for large human output
+		streamThreshold := uint64(forceThreshold) / 2
+		report := progressReporter(parts, parts > streamThreshold && format == outHuman && !force && !flagTable)
+		return streamList(counted(cidrItems(it), report))
+	}}
+	splitCmd.Flags().Int("new-prefix", 0, "new prefix length to split into (must be >= original prefix)")
+	splitCmd.Flags().Int("count", 0, "split into this many equal subnets (power of two) instead of --new-prefix")
+	splitCmd.Flags().Bool("force", false, "proceed even if subnet count exceeds large threshold")
+	splitCmd.Flags().Bool("count-only", false, "print the number of subnets without generating them")
+	splitCmd.Flags().Bool("validate", false, "check the split and report the subnet count and whether --force is required, without generating subnets")
+
+	fitCmd := &cobra.Command{Use: "fit <IPv6 CIDR>", Short: "Smallest subnet of a network that fits a number of hosts", Args: cobra.ExactArgs(1), Example: "  ip6calc fit 2001:db8::/48 --hosts 300", RunE: func(cmd *cobra.Command, args []string) error {
+		hostsStr, _ := cmd.Flags().GetString("hosts")
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		hosts, ok := new(big.Int).SetString(hostsStr, 10)
+		if !ok || hosts.Sign() <= 0 {
+			return fmt.Errorf("invalid --hosts: %q (want a positive integer)", hostsStr)
+		}
+		p, err := c.SubnetForHosts(hosts)
+		if err != nil {
+			return err
+		}
+		first, _ := ipv6.NewCIDR(c.Base(), p)
+		available := new(big.Int).Lsh(big.NewInt(1), uint(p-c.PrefixLength()))
+		return render(FitResult{p, first.String(), first.HostCount().String(), available.String()})
+	}}
+	fitCmd.Flags().String("hosts", "", "required number of addresses (decimal, may exceed 2^64)")
+	_ = fitCmd.MarkFlagRequired("hosts")
+
+	planCmd := &cobra.Command{Use: "plan <parent CIDR>", Short: "Allocate subnets for a list of host requirements (VLSM)", Args: cobra.ExactArgs(1), Example: "  ip6calc plan 2001:db8::/48 --hosts 1000,500,50\n  ip6calc plan 2001:db8::/48 --hosts 1000,500,50 --table", RunE: func(cmd *cobra.Command, args []string) error {
+		hostList, _ := cmd.Flags().GetStringSlice("hosts")
+		parent, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		if len(hostList) == 0 {
+			return errors.New("--hosts required")
+		}
+		reqs := make([]*big.Int, len(hostList))
+		for i, h := range hostList {
+			n, ok := new(big.Int).SetString(strings.TrimSpace(h), 10)
+			if !ok || n.Sign() <= 0 {
+				return fmt.Errorf("invalid --hosts entry %q (want a positive integer)", h)
+			}
+			reqs[i] = n
+		}
+		plan, err := ipv6.PlanVLSM(parent, reqs)
+		if err != nil {
+			return err
+		}
+		rows := make([]PlanRow, len(plan))
+		for i, c := range plan {
+			rows[i] = PlanRow{reqs[i].String(), c.String(), c.HostCount().String()}
+		}
+		if format == outHuman && flagTable {
+			cells := make([][]string, len(rows))
+			for i, r := range rows {
+				cells[i] = []string{r.Hosts, r.CIDR, r.Capacity}
+			}
+			return renderTable([]string{"Hosts", "CIDR", "Capacity"}, cells)
+		}
+		if format == outHuman {
+			list := make([]string, len(rows))
+			for i, r := range rows {
+				list[i] = r.CIDR
+			}
+			return render(list)
+		}
+		return render(rows)
+	}}
+	planCmd.Flags().StringSlice("hosts", nil, "comma-separated host requirements, one subnet each")
+
+	summarizeCmd := &cobra.Command{Use: "summarize [CIDR...]", Short: "Summarize a list of CIDRs", Args: cobra.ArbitraryArgs, Example: "  ip6calc summarize 2001:db8::/65 2001:db8:0:0:8000::/65\n  ip6calc dedup --sort < cidrs.txt | ip6calc summarize --sorted\n  ip6calc summarize --granularity 56 --allow-overcover 2001:db8::1/128 2001:db8:0:2::/64", RunE: func(cmd *cobra.Command, args []string) error {
+		failOverlap, _ := cmd.Flags().GetBool("fail-on-overlap")
+		sorted, _ := cmd.Flags().GetBool("sorted")
+		stats, _ := cmd.Flags().GetBool("stats")
+		overcover, _ := cmd.Flags().GetBool("allow-overcover")
+		if stats && (sorted || cmd.Flags().Changed("max-prefix")) {
+			return errors.New("--stats cannot be combined with --sorted or --max-prefix")
+		}
+		granular := cmd.Flags().Changed("granularity")
+		if granular {
+			if !overcover {
+				return errors.New("--granularity may cover addresses absent from the input; pass --allow-overcover to accept this")
+			}
+			if stats || sorted || cmd.Flags().Changed("max-prefix") {
+				return errors.New("--granularity cannot be combined with --stats, --sorted or --max-prefix")
+			}
+		}
+		if sorted {
+			if cmd.Flags().Changed("max-prefix") {
+				return errors.New("--sorted cannot be combined with --max-prefix")
+			}
+			// Streaming mode: results are final as soon as the Summarizer emits
+			// them, so human output is written immediately and memory stays
+			// bounded by prefix depth rather than input size.
+			w := rootCmd.OutOrStdout()
+			stream := format == outHuman && !flagTable && !flagQuiet
+			var list []string
+			var writeErr error
+			sum := ipv6.NewSummarizer(func(c ipv6.CIDR) {
+				if !stream {
+					list = append(list, c.String())
+					return
+				}
+				if err := writeItem(w, c.String()); err != nil && writeErr == nil {
+					writeErr = err
+				}
+			})
+			var widest ipv6.CIDR // input reaching furthest so far; any overlap must involve it
+			seen := false
+			push := func(s string) error {
+				c, err := parseCIDR(s)
+				if err != nil {
+					return err
+				}
+				if failOverlap && seen && widest.Overlaps(c) {
+					return OverlapError{widest, c}
+				}
+				if !seen || c.LastHost().Compare(widest.LastHost()) > 0 {
+					widest, seen = c, true
+				}
+				if err := sum.Push(c); err != nil {
+					return err
+				}
+				return writeErr
+			}
+			if len(args) > 0 {
+				for _, a := range args {
+					if err := push(a); err != nil {
+						return err
+					}
+				}
+			} else if err := scanStdinLines(push); err != nil {
+				return err
+			}
+			sum.Flush()
+			if stream {
+				return writeErr
+			}
+			return render(list)
+		}
+		if len(args) == 0 {
+			lines, err := readStdinLines()
+			if err != nil {
+				return err
+			}
+			if len(lines) == 0 {
+				return errors.New("no input")
+			}
+			args = lines
+		}
+		cidrs := make([]ipv6.CIDR, 0, len(args))
+		for _, a := range args {
+			c, err := parseCIDR(a)
+			if err != nil {
+				return err
+			}
+			cidrs = append(cidrs, c)
+		}
+		if failOverlap {
+			for i := 0; i < len(cidrs); i++ {
+				for j := i + 1; j < len(cidrs); j++ {
+					if cidrs[i].Overlaps(cidrs[j]) { // treat any overlap (including containment) as error
+						return OverlapError{cidrs[i], cidrs[j]}
+					}
+				}
+			}
+		}
+		if stats {
+			_, st := ipv6.SummarizeStats(cidrs)
+			return render(SummarizeStatsResult{st.InputCount, st.OutputCount, st.TotalAddresses.String()})
+		}
+		var res []ipv6.CIDR
+		switch {
+		case cmd.Flags().Changed("max-prefix"):
+			maxPrefix, _ := cmd.Flags().GetInt("max-prefix")
+			if maxPrefix < 0 || maxPrefix > 128 {
+				return errors.New("invalid --max-prefix: must be between 0 and 128")
+			}
+			res = ipv6.SummarizeMax(cidrs, maxPrefix)
+		case granular:
+			granularity, _ := cmd.Flags().GetInt("granularity")
+			if granularity < 0 || granularity > 128 {
+				return errors.New("invalid --granularity: must be between 0 and 128")
+			}
+			res = ipv6.SummarizeToGranularity(cidrs, granularity)
+		default:
+			res = ipv6.Summarize(cidrs)
+		}
+		list := make([]string, len(res))
+		for i, s := range res {
+			list[i] = s.String()
+		}
+		return render(list)
+	}}
+	summarizeCmd.Flags().Bool("fail-on-overlap", false, "fail if any overlap (including containment) present")
+	summarizeCmd.Flags().Int("max-prefix", 0, "never aggregate into a prefix shorter than this")
+	summarizeCmd.Flags().Bool("stats", false, "report input/output counts and covered address total instead of the list")
+	summarizeCmd.Flags().Int("granularity", 0, "widen inputs longer than this prefix before merging (lossy; requires --allow-overcover)")
+	summarizeCmd.Flags().Bool("allow-overcover", false, "accept output covering addresses not in the input")
+	summarizeCmd.Flags().Bool("sorted", false, "input is sorted by address (e.g. by dedup --sort); merge incrementally with bounded memory")
+
+	reverseCmd := &cobra.Command{Use: "reverse <IPv6 address | CIDR | ip6.arpa name>", Short: "Produce reverse DNS ip6.arpa names", Long: "Produce the ip6.arpa name of an address, or of every address of a CIDR.\nA CIDR with more addresses than IP6CALC_SPLIT_FORCE_THRESHOLD requires --force, as for split.", Args: cobra.ExactArgs(1), Example: "  ip6calc reverse 2001:db8::1\n  ip6calc reverse --zone 2001:db8::1\n  ip6calc reverse --origin 2001:db8::/48 2001:db8::1\n  ip6calc reverse --origin 2001:db8::/120 2001:db8::/120\n  ip6calc reverse --parse 1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", RunE: func(cmd *cobra.Command, args []string) error {
+		zone, _ := cmd.Flags().GetBool("zone")
+		dot, _ := cmd.Flags().GetBool("trailing-dot")
+		originStr, _ := cmd.Flags().GetString("origin")
+		if parse, _ := cmd.Flags().GetBool("parse"); parse {
+			addr, err := ipv6.AddressFromReverseDNS(args[0])
+			if err != nil {
+				return err
+			}
+			return render(addr.String())
+		}
+		var origin *ipv6.CIDR
+		if originStr != "" {
+			o, err := parseCIDR(originStr)
+			if err != nil {
+				return fmt.Errorf("--origin: %w", err)
+			}
+			origin = &o
+		}
+		name := func(addr ipv6.Address) (string, error) {
+			if origin != nil {
+				return ipv6.ReverseDNSRelative(addr, *origin)
+			}
+			rev := addr.ReverseDNS()
+			if zone || !dot {
+				rev = strings.TrimSuffix(rev, ".")
+			}
+			return rev, nil
+		}
+		if !strings.Contains(args[0], "/") {
+			addr, err := ipv6.Parse(args[0])
+			if err != nil {
+				return err
+			}
+			rev, err := name(addr)
+			if err != nil {
+				return err
+			}
+			return render(rev)
+		}
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		if origin != nil && !origin.ContainsCIDR(c) {
+			return fmt.Errorf("%w: %s not in %s", ipv6.ErrNotContained, c, *origin)
+		}
+		// reject a bad origin before streaming rather than on the first name
+		if _, err := name(c.Base()); err != nil {
+			return err
+		}
+		// the same safeguards as split, counting names instead of subnets
+		bits := 128 - c.PrefixLength()
+		if bits >= 63 {
+			return ipv6.ErrSplitExcessive
+		}
+		hosts := uint64(1) << uint(bits)
+		force, _ := cmd.Flags().GetBool("force")
+		if hosts > uint64(getThreshold("IP6CALC_SPLIT_FORCE_THRESHOLD", defaultSplitForceThreshold)) && !force {
+			return fmt.Errorf("%w: %s has %d addresses", ErrSplitTooLarge, c, hosts)
+		}
+		if hosts > uint64(getThreshold("IP6CALC_SPLIT_WARN_THRESHOLD", defaultSplitWarnThreshold)) && format == outHuman && !force {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: generating %d names (use --force to suppress)\n", hosts)
+		}
+		it := c.HostIterator(false)
+		var nameErr error
+		names := func() (string, bool) {
+			addr, ok := it.Next()
+			if !ok || nameErr != nil {
+				return "", false
+			}
+			rev, err := name(addr)
+			if err != nil {
+				nameErr = err
+				return "", false
+			}
+			return rev, true
+		}
+		if err := streamList(counted(names, progressReporter(hosts, false))); err != nil {
+			return err
+		}
+		return nameErr
+	}}
+	reverseCmd.Flags().Bool("zone", false, "omit trailing dot for zonefile usage (same as --trailing-dot=false)")
+	reverseCmd.Flags().Bool("trailing-dot", true, "end the name with the root dot")
+	reverseCmd.Flags().String("origin", "", "print the name relative to this nibble-aligned zone, without trailing dot")
+	reverseCmd.Flags().Bool("parse", false, "read an ip6.arpa PTR name and print the address it maps")
+	reverseCmd.Flags().Bool("force", false, "name every address of a CIDR even past the split threshold")
+	reverseCmd.MarkFlagsMutuallyExclusive("zone", "trailing-dot", "origin", "parse")
+
+	toIntCmd := &cobra.Command{Use: "to-int <IPv6 address>", Short: "Convert IPv6 address to integer", Args: cobra.ExactArgs(1), Example: "  ip6calc to-int 2001:db8::1\n  ip6calc to-int --hex 2001:db8::1", RunE: func(cmd *cobra.Command, args []string) error {
+		base, _ := cmd.Flags().GetInt("base")
+		hex, _ := cmd.Flags().GetBool("hex")
+		if hex && cmd.Flags().Changed("base") {
+			return errors.New("--hex and --base are mutually exclusive")
+		}
+		if base < 2 || base > 36 {
+			return fmt.Errorf("invalid --base: %d (want 2..36)", base)
+		}
+		addr, err := ipv6.Parse(args[0])
+		if err != nil {
+			return err
+		}
+		if hex {
+			return render("0x" + addr.BigInt().Text(16))
+		}
+		return render(addr.BigInt().Text(base))
+	}}
+	toIntCmd.Flags().Int("base", 10, "output base, 2..36")
+	toIntCmd.Flags().Bool("hex", false, "output 0x-prefixed hexadecimal (accepted back by from-int)")
+
+	fromIntCmd := &cobra.Command{Use: "from-int <integer>", Short: "Convert integer to IPv6 address", Args: cobra.ExactArgs(1), Example: "  ip6calc to-int 2001:db8::1 | ip6calc from-int\n  ip6calc from-int 0x20010db8000000000000000000000001\n  ip6calc from-int --base 16 20010db8000000000000000000000001", RunE: func(cmd *cobra.Command, args []string) error {
+		base, _ := cmd.Flags().GetInt("base")
+		bi, err := parseInteger(args[0], base)
+		if err != nil {
+			return err
+		}
+		addr, err := ipv6.AddressFromBigInt(bi)
+		if err != nil {
+			return err
+		}
+		return render(addr.String())
+	}}
+	fromIntCmd.Flags().Int("base", 0, "input base, 2..36 (default: decimal, or hex/binary with a 0x/0b prefix)")
+
+	toHexCmd := &cobra.Command{Use: "to-hex <IPv6 address>", Short: "Convert IPv6 address to 32 hex digits", Args: cobra.ExactArgs(1), Example: "  ip6calc to-hex 2001:db8::1", RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := ipv6.Parse(args[0])
+		if err != nil {
+			return err
+		}
+		return render(addr.Hex())
+	}}
+
+	fromHexCmd := &cobra.Command{Use: "from-hex <32 hex digits>", Short: "Convert 32 hex digits (colons and 0x allowed) to IPv6 address", Args: cobra.ExactArgs(1), Example: "  ip6calc from-hex 20010db8000000000000000000000001\n  ip6calc to-hex 2001:db8::1 | xargs ip6calc from-hex", RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := ipv6.AddressFromHex(args[0])
+		if err != nil {
+			return err
+		}
+		return render(addr.String())
+	}}
+
+	// step parses the address and optional delta and moves the address by
+	// sign*delta, wrapping modulo 2^128 unless --no-wrap is set, or modulo the
+	// size of the --within network.
+	step := func(sign int64) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			noWrap, _ := cmd.Flags().GetBool("no-wrap")
+			within, _ := cmd.Flags().GetString("within") // unset on next and prev
+			addr, err := ipv6.Parse(args[0])
+			if err != nil {
+				return err
+			}
+			delta := big.NewInt(1)
+			if len(args) > 1 {
+				if delta, err = parseInteger(args[1], 0); err != nil {
+					return err
+				}
+			}
+			delta.Mul(delta, big.NewInt(sign))
+			if within != "" {
+				c, err := parseCIDR(within)
+				if err != nil {
+					return fmt.Errorf("--within: %w", err)
+				}
+				res, err := c.AddWrap(addr, delta)
+				if err != nil {
+					return err
+				}
+				return render(res.String())
+			}
+			if !noWrap {
+				return render(addr.Add(delta).String())
+			}
+			res, err := ipv6.AddChecked(addr, delta)
+			if err != nil {
+				return err
+			}
+			return render(res.String())
+		}
+	}
+	nextCmd := &cobra.Command{Use: "next <IPv6 address>", Short: "Address after the given one", Long: "next adds 1 to the address. The all-ones address wraps around to :: unless --no-wrap is set.", Args: cobra.ExactArgs(1), Example: "  ip6calc next 2001:db8::ff", RunE: step(1)}
+	prevCmd := &cobra.Command{Use: "prev <IPv6 address>", Short: "Address before the given one", Long: "prev subtracts 1 from the address. :: wraps around to the all-ones address unless --no-wrap is set.", Args: cobra.ExactArgs(1), Example: "  ip6calc prev 2001:db8::100", RunE: step(-1)}
+	addCmd := &cobra.Command{Use: "add <IPv6 address> <delta>", Short: "Add an integer to an address", Long: "add adds a non-negative decimal or 0x-prefixed hex delta of any size to the address, wrapping modulo 2^128 past the all-ones address unless --no-wrap is set.\nWith --within the address must be in the network and wraps around inside it instead.", Args: cobra.ExactArgs(2), Example: "  ip6calc add 2001:db8::1 255\n  ip6calc add 2001:db8:: 0x10000000000000000\n  ip6calc add --no-wrap ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00 0x100\n  ip6calc add 2001:db8::3 2 --within 2001:db8::/126", RunE: step(1)}
+	subCmd := &cobra.Command{Use: "sub <IPv6 address> <delta>", Short: "Subtract an integer from an address", Long: "sub subtracts a non-negative decimal or 0x-prefixed hex delta of any size from the address, wrapping modulo 2^128 below :: unless --no-wrap is set.\nWith --within the address must be in the network and wraps around inside it instead.", Args: cobra.ExactArgs(2), Example: "  ip6calc sub 2001:db8::100 0xff", RunE: step(-1)}
+	for _, c := range []*cobra.Command{nextCmd, prevCmd, addCmd, subCmd} {
+		c.Flags().Bool("no-wrap", false, "fail instead of wrapping past :: or the all-ones address")
+	}
+	for _, c := range []*cobra.Command{addCmd, subCmd} {
+		c.Flags().String("within", "", "wrap modulo the size of this network, which must contain the address")
+		c.MarkFlagsMutuallyExclusive("no-wrap", "within")
+	}
+
+	rangeCmd := &cobra.Command{Use: "range <start-end>", Short: "Cover address range with minimal CIDRs", Args: cobra.ExactArgs(1), Example: "  ip6calc range 2001:db8::1-2001:db8::ff\n  ip6calc range 2001:db8::1-2001:db8::ff --as addresses\n  ip6calc -o json range 2001:db8::1-2001:db8::ff --with-meta", RunE: func(cmd *cobra.Command, args []string) error {
+		as, _ := cmd.Flags().GetString("as")
+		limit, _ := cmd.Flags().GetInt("limit")
+		withMeta, _ := cmd.Flags().GetBool("with-meta")
+		if as != "cidrs" && as != "addresses" {
+			return fmt.Errorf("invalid --as: %s (want addresses|cidrs)", as)
+		}
+		if withMeta && as != "cidrs" {
+			return errors.New("--with-meta requires --as cidrs")
+		}
+		start, end, err := parseRange(args[0])
+		if err != nil {
+			return err
+		}
+		if as == "addresses" {
+			if start.Compare(end) > 0 {
+				return fmt.Errorf("%w: %s after %s", ipv6.ErrInvalidRange, start, end)
+			}
+			if limit <= 0 {
+				return render([]string{start.String(), end.String()})
+			}
+			return render(collectAddresses(ipv6.RangeIterator(start, end), limit))
+		}
+		var cover []ipv6.CIDR
+		var extra *big.Int
+		if cmd.Flags().Changed("max-cidrs") {
+			maxCIDRs, _ := cmd.Flags().GetInt("max-cidrs")
+			if cover, extra, err = ipv6.CoverRangeMax(start, end, maxCIDRs); err != nil {
+				return err
+			}
+			if extra.Sign() > 0 {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: %d CIDRs cover %s addresses outside the range\n", len(cover), extra)
+			}
+		} else if !withMeta && start.Compare(end) <= 0 {
+			return streamList(cidrItems(ipv6.CoverRangeIterator(start, end)))
+		} else if cover, err = ipv6.CoverRange(start, end); err != nil {
+			return err
+		}
+		list := make([]string, len(cover))
+		for i, c := range cover {
+			list[i] = c.String()
+		}
+		if withMeta {
+			res := RangeResult{Start: start.String(), End: end.String(), Count: ipv6.CountRange(start, end), CIDRs: list, OverCoverage: extra}
+			if format == outHuman {
+				rows := fields{{"start", res.Start}, {"end", res.End}, {"count", res.Count}, {"cidrs", strings.Join(list, ", ")}}
+				if extra != nil {
+					rows = append(rows, field{"over_coverage", extra})
+				}
+				return render(rows)
+			}
+			return render(res)
+		}
+		return render(list)
+	}}
+
+	rangeCmd.Flags().String("as", "cidrs", "output form: cidrs (covering CIDRs) or addresses (start and end)")
+	rangeCmd.Flags().Int("limit", 0, "with --as addresses, emit every address up to this many instead of just the endpoints")
+	rangeCmd.Flags().Bool("with-meta", false, "emit the parsed start, end and address count alongside the CIDRs")
+	rangeCmd.Flags().Int("max-cidrs", 0, "merge the cover into at most this many CIDRs, covering extra addresses if needed")
+
+	walkCmd := &cobra.Command{Use: "walk <start-end>", Short: "Walk every address in a range", Args: cobra.ExactArgs(1), Example: "  ip6calc walk 2001:db8::ff-2001:db8::101\n  ip6calc walk ::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff --limit 3", RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+		if limit <= 0 {
+			return errors.New("limit must be >0")
+		}
+		start, end, err := parseRange(args[0])
+		if err != nil {
+			return err
+		}
+		if start.Compare(end) > 0 {
+			return fmt.Errorf("%w: %s after %s", ipv6.ErrInvalidRange, start, end)
+		}
+		return render(collectAddresses(ipv6.RangeIterator(start, end), limit))
+	}}
+	walkCmd.Flags().Int("limit", 10, "maximum number of addresses to emit")
+
+	supernetCmd := &cobra.Command{Use: "supernet <CIDR...>", Short: "Smallest CIDR containing all", Args: cobra.MinimumNArgs(1), Example: "  ip6calc supernet 2001:db8::/65 2001:db8:0:0:8000::/65", RunE: func(cmd *cobra.Command, args []string) error {
+		var list []ipv6.CIDR
+		for _, a := range args {
+			c, err := parseCIDR(a)
+			if err != nil {
+				return err
+			}
+			list = append(list, c)
+		}
+		res, err := ipv6.Supernet(list)
+		if err != nil {
+			return err
+		}
+		return render(res.String())
+	}}
+
+	supernetOfCmd := &cobra.Command{Use: "supernet-of <CIDR>", Short: "Containing network at a shorter prefix length", Args: cobra.ExactArgs(1), Example: "  ip6calc supernet-of 2001:db8:1:2::/64 --prefix 48\n  ip6calc supernet-of 2001:db8:1:2::/64", RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("prefix") {
+			return render(c.Parent().String())
+		}
+		prefix, _ := cmd.Flags().GetInt("prefix")
+		res, err := c.SupernetAt(prefix)
+		if err != nil {
+			return fmt.Errorf("invalid --prefix: must be between 0 and %d: %w", c.PrefixLength(), err)
+		}
+		return render(res.String())
+	}}
+	supernetOfCmd.Flags().Int("prefix", 0, "target prefix length (default: one level up)")
+
+	commonPrefixCmd := &cobra.Command{Use: "common-prefix <a> <b>", Short: "Longest prefix two addresses share", Args: cobra.ExactArgs(2), Example: "  ip6calc common-prefix 2001:db8:1::1 2001:db8:3::1", RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := ipv6.Parse(args[0])
+		if err != nil {
+			return err
+		}
+		b, err := ipv6.Parse(args[1])
+		if err != nil {
+			return err
+		}
+		n := ipv6.CommonPrefixLen(a, b)
+		c, err := ipv6.NewCIDR(a, n)
+		if err != nil {
+			return err
+		}
+		return render(CommonPrefixResult{n, "/" + strconv.Itoa(n), c.String()})
+	}}
+
+	siblingCmd := &cobra.Command{Use: "sibling <CIDR>", Short: "Other half of a network's parent", Args: cobra.ExactArgs(1), Example: "  ip6calc sibling 2001:db8::/65", RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		sib, ok := c.Sibling()
+		if !ok {
+			return errors.New("::/0 has no sibling")
+		}
+		return render(sib.String())
+	}}
+
+	enumerateCmd := &cobra.Command{Use: "enumerate <CIDR>", Short: "Enumerate sample addresses", Args: cobra.ExactArgs(1), Example: "  ip6calc enumerate 2001:db8::/64 --limit 5 --stride 16", RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+		stride, _ := cmd.Flags().GetInt("stride")
+		usable, _ := cmd.Flags().GetBool("usable")
+		if limit <= 0 {
+			return errors.New("limit must be >0")
+		}
+		if stride <= 0 {
+			return errors.New("stride must be >0")
+		}
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		start := c.FirstHost()
+		if usable && c.PrefixLength() < 127 { // skip subnet-router anycast
+			start = start.Offset(1)
+		}
+		if countOnly, _ := cmd.Flags().GetBool("count-only"); countOnly {
+			// addresses reachable from start in stride steps, capped by limit
+			n := ipv6.Distance(start, c.LastHost())
+			n.Div(n, big.NewInt(int64(stride)))
+			n.Add(n, big.NewInt(1))
+			if lim := big.NewInt(int64(limit)); n.Cmp(lim) > 0 {
+				n = lim
+			}
+			return renderCount(n)
+		}
+		i := 0
+		return streamList(func() (string, bool) {
+			if i == limit {
+				return "", false
+			}
+			delta := new(big.Int).Mul(big.NewInt(int64(stride)), big.NewInt(int64(i)))
+			addr := start.Add(delta)
+			if !c.ContainsAddress(addr) {
+				return "", false
+			}
+			i++
+			return addr.String(), true
+		})
+	}}
+	enumerateCmd.Flags().Int("limit", 10, "maximum number of addresses to emit")
+	enumerateCmd.Flags().Int("stride", 1, "step between successive addresses")
+	enumerateCmd.Flags().Bool("usable", false, "skip the subnet-router anycast address (no-op for /127 and /128)")
+	enumerateCmd.Flags().Bool("count-only", false, "print how many addresses would be emitted")
+
+	randomCmd := &cobra.Command{Use: "random", Short: "Random address or subnet"}
+	// dynamic completion for random subcommands
+	randomCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return []string{"address", "subnet"}, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	randomAddrCmd := &cobra.Command{Use: "address <CIDR>", Short: "Random address(es) in CIDR", Args: cobra.ExactArgs(1), Example: "  ip6calc random address 2001:db8::/64 --count 3\n  ip6calc random address 2001:db8::/48 --exclude reserved.txt", RunE: func(cmd *cobra.Command, args []string) error {
+		count, _ := cmd.Flags().GetInt("count")
+		exclude, _ := cmd.Flags().GetString("exclude")
+		if count <= 0 {
+			return errors.New("count must be >0")
+		}
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		var reserved []ipv6.CIDR
+		var failed []string
+		var total int
+		if exclude != "" {
+			if reserved, total, err = readRoutes(exclude, &failed); err != nil {
+				return err
+			}
+		}
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		var list []string
+		for i := 0; i < count; i++ {
+			if exclude == "" {
+				list = append(list, ipv6.RandomAddressInCIDR(c, r).String())
+				continue
+			}
+			a, err := ipv6.RandomAddressExcluding(c, reserved, r)
+			if err != nil {
+				return err
+			}
+			list = append(list, a.String())
+		}
+		if err := render(list); err != nil {
+			return err
+		}
+		return finishInputs(failed, total)
+	}}
+	randomAddrCmd.Flags().Int("count", 1, "number of random addresses")
+	randomAddrCmd.Flags().String("exclude", "", "file of reserved CIDRs, one per line, to avoid")
+	randomSubnetCmd := &cobra.Command{Use: "subnet <CIDR>", Short: "Random subnet in CIDR", Args: cobra.ExactArgs(1), Example: "  ip6calc random subnet 2001:db8::/48 --new-prefix 64 --count 3\n  ip6calc random subnet 2001:db8::/56 --new-prefix 64 --distribution front-loaded --seed 1", RunE: func(cmd *cobra.Command, args []string) error {
+		count, _ := cmd.Flags().GetInt("count")
+		newPrefix, _ := cmd.Flags().GetInt("new-prefix")
+		dist, _ := cmd.Flags().GetString("distribution")
+		seed, _ := cmd.Flags().GetInt64("seed")
+		if dist != "uniform" && dist != "front-loaded" {
+			return fmt.Errorf("invalid --distribution: %s (want uniform|front-loaded)", dist)
+		}
+		if count <= 0 {
+			return errors.New("count must be >0")
+		}
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		if newPrefix == 0 {
 			return errors.New("--new-prefix required")
 		}
-		if newPrefix < c.PrefixLength() || newPrefix > 128 {
-			return fmt.Errorf("invalid --new-prefix: must be >= %d and <=128", c.PrefixLength())
+		if newPrefix < c.PrefixLength() || newPrefix > 128 {
+			return fmt.Errorf("invalid --new-prefix: must be >= %d and <=128", c.PrefixLength())
+		}
+		if !cmd.Flags().Changed("seed") {
+			seed = time.Now().UnixNano()
+		}
+		r := rand.New(rand.NewSource(seed))
+		var subnets []ipv6.CIDR
+		if dist == "front-loaded" { // subnet idx weighted 1/(idx+1)
+			if subnets, err = ipv6.RandomSubnetsWeighted(c, newPrefix, count, func(idx uint64) float64 { return 1 / float64(idx+1) }, r); err != nil {
+				return err
+			}
+		} else {
+			for i := 0; i < count; i++ {
+				s, err := ipv6.RandomSubnetInCIDR(c, newPrefix, r)
+				if err != nil {
+					return err
+				}
+				subnets = append(subnets, s)
+			}
+		}
+		list := make([]string, len(subnets))
+		for i, s := range subnets {
+			list[i] = s.String()
+		}
+		return render(list)
+	}}
+	randomSubnetCmd.Flags().Int("count", 1, "number of random subnets")
+	randomSubnetCmd.Flags().Int("new-prefix", 0, "prefix length of random subnets")
+	randomSubnetCmd.Flags().String("distribution", "uniform", "subnet selection: uniform|front-loaded (favours subnets near the start)")
+	randomSubnetCmd.Flags().Int64("seed", 0, "random seed for reproducible output (default: time-based)")
+	randomCmd.AddCommand(randomAddrCmd, randomSubnetCmd)
+
+	diffCmd := &cobra.Command{Use: "diff <CIDR...>", Short: "Show overlaps and gaps between CIDRs", Args: cobra.MinimumNArgs(2), Example: "  ip6calc diff 2001:db8::/65 2001:db8::/64", RunE: func(cmd *cobra.Command, args []string) error {
+		var list []ipv6.CIDR
+		for _, a := range args {
+			c, err := parseCIDR(a)
+			if err != nil {
+				return err
+			}
+			list = append(list, c)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Base().Compare(list[j].Base()) == 0 {
+				return list[i].PrefixLength() < list[j].PrefixLength()
+			}
+			return list[i].Base().Compare(list[j].Base()) < 0
+		})
+		var overlaps []string
+		var gaps []DiffGap
+		one := big.NewInt(1)
+		isZero := func(a ipv6.Address) bool { return a.BigInt().Sign() == 0 }
+		max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+		isMax := func(a ipv6.Address) bool { return a.BigInt().Cmp(max) == 0 }
+		for i := 0; i < len(list)-1; i++ {
+			a := list[i]
+			b := list[i+1]
+			if a.Overlaps(b) {
+				overlaps = append(overlaps, fmt.Sprintf("%s %s", a, b))
+			} else {
+				lastA := a.LastHost()
+				firstB := b.FirstHost()
+				if lastA.Compare(firstB) < 0 && !isMax(lastA) && !isZero(firstB) { // only if real space between and no wrap risk
+					ga := lastA.Add(one)
+					gb := firstB.Sub(one)
+					if ga.Compare(gb) <= 0 { // still non-empty
+						gaps = append(gaps, DiffGap{ga.String(), gb.String()})
+					}
+				}
+			}
+		}
+		if format == outHuman {
+			var lines []string
+			for _, o := range overlaps {
+				lines = append(lines, colorize("overlap: ")+o)
+			}
+			for _, g := range gaps {
+				lines = append(lines, colorize("gap: ")+g.Start+"-"+g.End)
+			}
+			return render(lines)
+		}
+		return render(DiffResult{overlaps, gaps})
+	}}
+
+	ulaCmd := &cobra.Command{Use: "ula", Short: "Unique local address (RFC 4193) helpers"}
+	ulaGenerateCmd := &cobra.Command{Use: "generate", Short: "Generate a random ULA /48 prefix", Args: cobra.NoArgs, Example: "  ip6calc ula generate\n  ip6calc ula generate --mac 00:1b:21:3a:4b:5c", RunE: func(cmd *cobra.Command, args []string) error {
+		macStr, _ := cmd.Flags().GetString("mac")
+		var (
+			c   ipv6.CIDR
+			err error
+		)
+		if macStr != "" {
+			mac, perr := net.ParseMAC(macStr)
+			if perr != nil {
+				return perr
+			}
+			c, err = ipv6.GenerateULAFromMAC(mac, time.Now())
+		} else {
+			c, err = ipv6.GenerateULA(nil)
+		}
+		if err != nil {
+			return err
+		}
+		return render(c.String())
+	}}
+	ulaGenerateCmd.Flags().String("mac", "", "derive the global ID from this MAC and the current time (RFC 4193 3.2.2)")
+	ulaInfoCmd := &cobra.Command{Use: "info <IPv6 address>", Short: "Show the global and subnet IDs of a ULA", Args: cobra.ExactArgs(1), Example: "  ip6calc ula info fd12:3456:789a:1::1", RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := ipv6.Parse(args[0])
+		if err != nil {
+			return err
+		}
+		gid, sid, err := ipv6.ParseULA(addr)
+		if err != nil {
+			return err
+		}
+		prefix, _ := ipv6.NewCIDR(addr, 48)
+		b := addr.As16()
+		return render(ULAInfoResult{prefix.String(), fmt.Sprintf("%010x", gid), fmt.Sprintf("%04x", sid), b[0]&0x01 != 0})
+	}}
+	ulaCmd.AddCommand(ulaGenerateCmd, ulaInfoCmd)
+
+	privacyCmd := &cobra.Command{Use: "privacy <prefix>", Short: "Derive an RFC 7217 stable privacy address", Long: "privacy derives a stable, opaque interface ID for the prefix from the interface name, DAD counter and secret key (RFC 7217). The same inputs always yield the same address; a different secret yields an unrelated one.", Args: cobra.ExactArgs(1), Example: "  ip6calc privacy 2001:db8:1:2::/64 --secret \"$(cat /etc/ip6calc.key)\" --netiface eth0", RunE: func(cmd *cobra.Command, args []string) error {
+		secret, _ := cmd.Flags().GetString("secret")
+		netIface, _ := cmd.Flags().GetString("netiface")
+		counter, _ := cmd.Flags().GetInt("counter")
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		addr, err := ipv6.StablePrivacyIID(c, netIface, []byte(secret), counter)
+		if err != nil {
+			return err
+		}
+		return render(addr.String())
+	}}
+	privacyCmd.Flags().String("secret", "", "secret key mixed into the interface ID")
+	privacyCmd.Flags().String("netiface", "", "network interface name, e.g. eth0")
+	privacyCmd.Flags().Int("counter", 0, "DAD counter; increase it after a duplicate address is detected")
+	_ = privacyCmd.MarkFlagRequired("secret")
+	_ = privacyCmd.MarkFlagRequired("netiface")
+
+	anonymizeCmd := &cobra.Command{Use: "anonymize [IPv6 address ...]", Short: "Mask or hash the host bits of addresses", Long: "anonymize keeps the first --keep-prefix bits of each address and zeroes the rest (--mode zero) or replaces them with a keyed hash (--mode hash), which is deterministic for a given --key. Without arguments it reads text from stdin and rewrites every address it finds in place, IPv4-mapped ones included, leaving the rest of each line untouched, so logs can be shared without revealing hosts.", Args: cobra.ArbitraryArgs, Example: "  ip6calc anonymize --keep-prefix 48 2001:db8:1:2::1\n  ip6calc anonymize --mode hash --key \"$(cat /etc/ip6calc.key)\" < access.log > access.anon.log", RunE: func(cmd *cobra.Command, args []string) error {
+		keep, _ := cmd.Flags().GetInt("keep-prefix")
+		mode, _ := cmd.Flags().GetString("mode")
+		key, _ := cmd.Flags().GetString("key")
+		// validate once up front rather than on the first address found
+		if _, err := ipv6.AnonymizeAddress(ipv6.Address{}, keep, mode, []byte(key)); err != nil {
+			return err
+		}
+		anon := func(a ipv6.Address) ipv6.Address {
+			out, _ := ipv6.AnonymizeAddress(a, keep, mode, []byte(key))
+			return out
+		}
+		if len(args) > 0 {
+			list := make([]string, 0, len(args))
+			for _, s := range args {
+				a, _, err := ipv6.ParseAllowV4Mapped(s)
+				if err != nil {
+					return err
+				}
+				list = append(list, anon(a).String())
+			}
+			return render(list)
+		}
+		w := rootCmd.OutOrStdout()
+		stream := format == outHuman && !flagTable && !flagQuiet
+		var list []string
+		scanner := bufio.NewScanner(rootCmd.InOrStdin())
+		for scanner.Scan() {
+			line := rewriteAddresses(scanner.Text(), anon)
+			if !stream {
+				list = append(list, line)
+				continue
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		if stream {
+			return nil
+		}
+		return render(list)
+	}}
+	anonymizeCmd.Flags().Int("keep-prefix", 48, "number of leading bits to preserve")
+	anonymizeCmd.Flags().String("mode", "zero", "how to replace the remaining bits: zero|hash")
+	anonymizeCmd.Flags().String("key", "", "secret key for --mode hash")
+
+	rewriteCmd := &cobra.Command{Use: "rewrite [IPv6 address or CIDR ...]", Short: "Renumber addresses and CIDRs from one prefix to another", Long: "rewrite replaces the --from prefix bits of each input inside --from with the --to prefix, keeping all bits past --to's length. Inputs outside --from are passed through unchanged. --to must be at least as long as --from, and a CIDR is rewritten only when it is no shorter than --to.", Args: cobra.ArbitraryArgs, Example: "  ip6calc rewrite --from 2001:db8::/32 --to 2001:470::/32 2001:db8:1::1\n  ip6calc rewrite --from 2001:db8::/32 --to 2001:470::/32 < hosts.txt", RunE: func(cmd *cobra.Command, args []string) error {
+		fromStr, _ := cmd.Flags().GetString("from")
+		toStr, _ := cmd.Flags().GetString("to")
+		from, err := parseCIDR(fromStr)
+		if err != nil {
+			return fmt.Errorf("--from: %w", err)
+		}
+		to, err := parseCIDR(toStr)
+		if err != nil {
+			return fmt.Errorf("--to: %w", err)
+		}
+		if to.PrefixLength() < from.PrefixLength() {
+			return fmt.Errorf("%w: --to /%d is shorter than --from /%d", ipv6.ErrInvalidPrefix, to.PrefixLength(), from.PrefixLength())
+		}
+		if len(args) == 0 {
+			lines, err := readStdinLines()
+			if err != nil {
+				return err
+			}
+			args = lines
+		}
+		var list, failed []string
+		for i, s := range args {
+			if !strings.Contains(s, "/") {
+				a, err := ipv6.Parse(s)
+				if err != nil {
+					if err := failInput(&failed, i+1, s, err); err != nil {
+						return err
+					}
+					continue
+				}
+				if out, ok := ipv6.Rewrite(a, from, to); ok {
+					s = out.String()
+				}
+				list = append(list, s)
+				continue
+			}
+			c, err := parseCIDR(s)
+			if err == nil && from.Overlaps(c) && !from.ContainsCIDR(c) {
+				err = fmt.Errorf("%w: %s only partly inside --from %s", ipv6.ErrNotContained, c, from)
+			}
+			if err == nil && from.ContainsCIDR(c) && c.PrefixLength() < to.PrefixLength() {
+				err = fmt.Errorf("%w: %s is shorter than --to /%d", ipv6.ErrInvalidPrefix, c, to.PrefixLength())
+			}
+			if err != nil {
+				if err := failInput(&failed, i+1, s, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if base, ok := ipv6.Rewrite(c.Base(), from, to); ok {
+				c, _ = ipv6.NewCIDR(base, c.PrefixLength())
+				s = c.String()
+			}
+			list = append(list, s)
+		}
+		if err := render(list); err != nil {
+			return err
+		}
+		return finishInputs(failed, len(args))
+	}}
+	rewriteCmd.Flags().String("from", "", "prefix to renumber from")
+	rewriteCmd.Flags().String("to", "", "prefix to renumber to")
+	_ = rewriteCmd.MarkFlagRequired("from")
+	_ = rewriteCmd.MarkFlagRequired("to")
+
+	renumberCmd := &cobra.Command{Use: "renumber", Short: "Move a list of networks from one block to another", Long: "renumber shifts every network in --list (or stdin) from the --from block to the --to block, which must have the same prefix length. Each network keeps its prefix length and its offset within the block; a network outside --from is an error.", Args: cobra.NoArgs, Example: "  ip6calc renumber --from 2001:db8:aa::/48 --to 2001:db8:bb::/48 --list subnets.txt", RunE: func(cmd *cobra.Command, args []string) error {
+		fromStr, _ := cmd.Flags().GetString("from")
+		toStr, _ := cmd.Flags().GetString("to")
+		listPath, _ := cmd.Flags().GetString("list")
+		from, err := parseCIDR(fromStr)
+		if err != nil {
+			return fmt.Errorf("--from: %w", err)
+		}
+		to, err := parseCIDR(toStr)
+		if err != nil {
+			return fmt.Errorf("--to: %w", err)
+		}
+		if _, err := from.RenumberTo(to); err != nil {
+			return err
+		}
+		var failed []string
+		cidrs, total, err := readRoutes(listPath, &failed)
+		if err != nil {
+			return err
+		}
+		res, err := ipv6.RenumberCIDRs(cidrs, from, to)
+		if err != nil {
+			return err
+		}
+		list := make([]string, len(res))
+		for i, c := range res {
+			list[i] = c.String()
+		}
+		if err := render(list); err != nil {
+			return err
+		}
+		return finishInputs(failed, total)
+	}}
+	renumberCmd.Flags().String("from", "", "block the networks are in")
+	renumberCmd.Flags().String("to", "", "block to move them to (same prefix length)")
+	renumberCmd.Flags().String("list", "", "file of networks, one per line (default: stdin)")
+	_ = renumberCmd.MarkFlagRequired("from")
+	_ = renumberCmd.MarkFlagRequired("to")
+
+	// filter flags in the order they are checked; any match passes a line
+	filterChecks := []struct {
+		flag, usage string
+		fn          func(ipv6.Address) bool
+	}{
+		{"global-unicast", "global unicast (2000::/3)", ipv6.Address.IsGlobalUnicast},
+		{"link-local", "link-local unicast (fe80::/10)", ipv6.Address.IsLinkLocal},
+		{"unique-local", "unique local (fc00::/7)", ipv6.Address.IsUniqueLocal},
+		{"multicast", "multicast (ff00::/8)", ipv6.Address.IsMulticast},
+		{"loopback", "loopback (::1)", ipv6.Address.IsLoopback},
+		{"unspecified", "unspecified (::)", ipv6.Address.IsUnspecified},
+		{"documentation", "documentation (2001:db8::/32)", ipv6.Address.IsDocumentation},
+	}
+	filterCmd := &cobra.Command{Use: "filter", Short: "Pass through stdin addresses matching a class", Args: cobra.NoArgs, Example: "  cat addrs.txt | ip6calc filter --link-local --unique-local", RunE: func(cmd *cobra.Command, args []string) error {
+		var checks []func(ipv6.Address) bool
+		for _, fc := range filterChecks {
+			if on, _ := cmd.Flags().GetBool(fc.flag); on {
+				checks = append(checks, fc.fn)
+			}
+		}
+		if len(checks) == 0 {
+			return errors.New("no filter selected")
+		}
+		var list []string
+		err := scanStdinLines(func(line string) error {
+			a, err := ipv6.Parse(line)
+			if err != nil {
+				return err
+			}
+			for _, fn := range checks {
+				if fn(a) {
+					list = append(list, line)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return render(list)
+	}}
+	for _, fc := range filterChecks {
+		filterCmd.Flags().Bool(fc.flag, false, "keep "+fc.usage+" addresses")
+	}
+
+	classifyRangeCmd := &cobra.Command{Use: "classify-range <CIDR>", Short: "Address categories a network falls in or spans", Args: cobra.ExactArgs(1), Example: "  ip6calc classify-range 2001:db8::/48\n  ip6calc classify-range fc00::/6", RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		types := ipv6.ClassifyCIDR(c)
+		res := ClassifyRangeResult{CIDR: c.String(), Types: make([]string, len(types)), Uniform: true}
+		for i, t := range types {
+			res.Types[i] = string(t)
+			// a category only partly overlapping c splits it
+			if n, ok := t.Network(); ok && !n.ContainsCIDR(c) {
+				res.Uniform = false
+			}
+			if t == ipv6.TypeUnclassified && len(types) > 1 {
+				res.Uniform = false
+			}
+		}
+		if format == outHuman {
+			names := make([]string, len(types))
+			for i, t := range types {
+				names[i] = colorizeType(t, string(t))
+			}
+			return render(fields{{"cidr", res.CIDR}, {"types", strings.Join(names, ", ")}, {"uniform", res.Uniform}})
+		}
+		return render(res)
+	}}
+
+	coversCmd := &cobra.Command{Use: "covers <target CIDR>", Short: "List every route that contains a prefix", Long: "covers prints each route containing all of the target, most specific first. A route equal to the target covers it.", Args: cobra.ExactArgs(1), Example: "  ip6calc covers 2001:db8:1:2::/64 --routes routes.txt\n  ip6calc covers 2001:db8::1/128 < routes.txt", RunE: func(cmd *cobra.Command, args []string) error {
+		routesFile, _ := cmd.Flags().GetString("routes")
+		target, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		var failed []string
+		routes, total, err := readRoutes(routesFile, &failed)
+		if err != nil {
+			return err
 		}
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		var list []string
-		for i := 0; i < count; i++ {
-			s, err := ipv6.RandomSubnetInCIDR(c, newPrefix, r)
-			if err != nil {
-				return err
+		covers := ipv6.CoveringCIDRs(routes, target)
+		list := make([]string, len(covers))
+		for i, c := range covers {
+			list[i] = c.String()
+		}
+		if err := render(list); err != nil {
+			return err
+		}
+		return finishInputs(failed, total)
+	}}
+	coversCmd.Flags().String("routes", "", "file of routes, one CIDR per line (default: stdin)")
+
+	subnetsOfCmd := &cobra.Command{Use: "subnets-of <parent CIDR>", Short: "List every route inside a prefix", Long: "subnets-of prints each route lying entirely inside the parent, ordered by address. A route equal to the parent is included.", Args: cobra.ExactArgs(1), Example: "  ip6calc subnets-of 2001:db8::/32 --routes routes.txt\n  ip6calc subnets-of 2001:db8:1::/48 < routes.txt", RunE: func(cmd *cobra.Command, args []string) error {
+		routesFile, _ := cmd.Flags().GetString("routes")
+		parent, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		var failed []string
+		routes, total, err := readRoutes(routesFile, &failed)
+		if err != nil {
+			return err
+		}
+		subs := ipv6.SubnetsOf(routes, parent)
+		list := make([]string, len(subs))
+		for i, c := range subs {
+			list[i] = c.String()
+		}
+		if err := render(list); err != nil {
+			return err
+		}
+		return finishInputs(failed, total)
+	}}
+	subnetsOfCmd.Flags().String("routes", "", "file of routes, one CIDR per line (default: stdin)")
+
+	verifyCoverCmd := &cobra.Command{Use: "verify-cover <parent CIDR>", Short: "Check that parts tile a parent exactly", Long: "verify-cover prints ok and exits 0 when the parts cover every address of the parent exactly once and nothing outside it; otherwise it fails with exit status 1.", Args: cobra.ExactArgs(1), Example: "  ip6calc split 2001:db8::/48 --new-prefix 52 | ip6calc verify-cover 2001:db8::/48\n  ip6calc verify-cover 2001:db8::/48 --parts allocations.txt", RunE: func(cmd *cobra.Command, args []string) error {
+		partsFile, _ := cmd.Flags().GetString("parts")
+		parent, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		var failed []string
+		parts, total, err := readRoutes(partsFile, &failed)
+		if err != nil {
+			return err
+		}
+		if !ipv6.IsExactCover(parent, parts) {
+			rootCmd.SilenceUsage = true // the invocation itself was fine
+			if gaps := ipv6.Gaps(parent, parts); len(gaps) > 0 {
+				return fmt.Errorf("%w: %s is not covered", ErrNotExactCover, gaps[0])
 			}
-			list = append(list, s.String())
+			return ErrNotExactCover
 		}
-		return render(list)
+		if err := render("ok"); err != nil {
+			return err
+		}
+		return finishInputs(failed, total)
 	}}
-	randomSubnetCmd.Flags().Int("count", 1, "number of random subnets")
-	randomSubnetCmd.Flags().Int("new-prefix", 0, "prefix length of random subnets")
-	randomCmd.AddCommand(randomAddrCmd, randomSubnetCmd)
+	verifyCoverCmd.Flags().String("parts", "", "file of parts, one CIDR per line (default: stdin)")
 
-	diffCmd := &cobra.Command{Use: "diff <CIDR...>", Short: "Show overlaps and gaps between CIDRs", Args: cobra.MinimumNArgs(2), Example: "  ip6calc diff 2001:db8::/65 2001:db8::/64", RunE: func(cmd *cobra.Command, args []string) error {
-		var list []ipv6.CIDR
-		for _, a := range args {
-			c, err := ipv6.ParseCIDR(a)
+	specialCmd := &cobra.Command{Use: "special <IPv6 address>", Short: "Look up the IANA special-purpose registry entry of an address", Args: cobra.ExactArgs(1), Example: "  ip6calc special ::1\n  ip6calc special 64:ff9b::192.0.2.1", RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _, err := ipv6.ParseAllowV4Mapped(args[0])
+		if err != nil {
+			return err
+		}
+		res := SpecialResult{Address: addr.String()}
+		if e, ok := ipv6.LookupSpecialPurpose(addr); ok {
+			res = SpecialResult{res.Address, true, e.Network.String(), e.Name, e.RFC}
+		}
+		return render(res)
+	}}
+
+	gapsCmd := &cobra.Command{Use: "gaps <parent CIDR>", Short: "List unallocated space inside a parent block", Args: cobra.ExactArgs(1), Example: "  ip6calc gaps 2001:db8::/48 --used allocations.txt\n  cat allocations.txt | ip6calc gaps 2001:db8::/48 --table", RunE: func(cmd *cobra.Command, args []string) error {
+		usedFile, _ := cmd.Flags().GetString("used")
+		parent, err := parseCIDR(args[0])
+		if err != nil {
+			return err
+		}
+		var lines []string
+		if usedFile != "" {
+			lines, err = readLinesFile(usedFile)
+		} else {
+			lines, err = readStdinLines()
+		}
+		if err != nil {
+			return err
+		}
+		used := make([]ipv6.CIDR, 0, len(lines))
+		for _, l := range lines {
+			c, err := parseCIDR(l)
 			if err != nil {
 				return err
 			}
-			list = append(list, c)
+			used = append(used, c)
 		}
-		sort.Slice(list, func(i, j int) bool {
-			if list[i].Base().Compare(list[j].Base()) == 0 {
-				return list[i].PrefixLength() < list[j].PrefixLength()
-			}
-			return list[i].Base().Compare(list[j].Base()) < 0
-		})
-		type gap struct{ Start, End string }
-		var overlaps []string
-		var gaps []gap
-		one := big.NewInt(1)
-		isZero := func(a ipv6.Address) bool { return a.BigInt().Sign() == 0 }
-		max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
-		isMax := func(a ipv6.Address) bool { return a.BigInt().Cmp(max) == 0 }
-		for i := 0; i < len(list)-1; i++ {
-			a := list[i]
-			b := list[i+1]
-			if a.Overlaps(b) {
-				overlaps = append(overlaps, fmt.Sprintf("%s %s", a, b))
-			} else {
-				lastA := a.LastHost()
-				firstB := b.FirstHost()
-				if lastA.Compare(firstB) < 0 && !isMax(lastA) && !isZero(firstB) { // only if real space between and no wrap risk
-					ga := lastA.Add(one)
-					gb := firstB.Sub(one)
-					if ga.Compare(gb) <= 0 { // still non-empty
-						gaps = append(gaps, gap{ga.String(), gb.String()})
-					}
-				}
+		gaps := ipv6.Gaps(parent, used)
+		rows := make([]GapRow, len(gaps))
+		for i, g := range gaps {
+			rows[i] = GapRow{g.String(), g.FirstHost().String(), g.LastHost().String()}
+		}
+		if format == outHuman && flagTable {
+			cells := make([][]string, len(rows))
+			for i, r := range rows {
+				cells[i] = []string{r.CIDR, r.Start, r.End}
 			}
+			return renderTable([]string{"CIDR", "Start", "End"}, cells)
 		}
 		if format == outHuman {
-			var lines []string
-			for _, o := range overlaps {
-				lines = append(lines, colorize("overlap: ")+o)
-			}
-			for _, g := range gaps {
-				lines = append(lines, colorize("gap: ")+g.Start+"-"+g.End)
+			list := make([]string, len(rows))
+			for i, r := range rows {
+				list[i] = r.CIDR
 			}
-			return render(lines)
+			return render(list)
 		}
-		return render(map[string]any{"overlaps": overlaps, "gaps": gaps})
+		return render(rows)
 	}}
+	gapsCmd.Flags().String("used", "", "file listing allocated CIDRs, one per line (default: stdin)")
 
 	versionCmd := &cobra.Command{Use: "version", Short: "Print version information", RunE: func(cmd *cobra.Command, args []string) error {
-		return render(map[string]string{"version": Version, "commit": Commit, "build_date": BuildDate})
+		return render(VersionResult{Version, Commit, BuildDate})
+	}}
+
+	schemaCmd := &cobra.Command{Use: "schema [command]", Short: "Print the JSON Schema of a command's structured output", Long: "schema prints the JSON Schema (draft 2020-12) of the JSON/YAML document a command emits, or an object of all of them keyed by command path. The schema const matches the emitted \"schema\" field. Outputs narrowed by info --fields are not covered.", Args: cobra.ArbitraryArgs, Example: "  ip6calc schema info\n  ip6calc schema random address\n  ip6calc schema > ip6calc-schemas.json", RunE: func(cmd *cobra.Command, args []string) error {
+		schemas := outputSchemas()
+		var doc any
+		if len(args) == 0 {
+			all := make(map[string]jsonSchema, len(schemas))
+			for name, data := range schemas {
+				all[name] = outputDocument(name, data)
+			}
+			doc = all
+		} else {
+			name := strings.Join(args, " ")
+			data, ok := schemas[name]
+			if !ok {
+				return fmt.Errorf("no output schema for %q (known: %s)", name, strings.Join(sortedKeys(schemas), ", "))
+			}
+			doc = outputDocument(name, data)
+		}
+		w := rootCmd.OutOrStdout()
+		if format == outYAML {
+			enc := yaml.NewEncoder(w)
+			if err := enc.Encode(doc); err != nil {
+				_ = enc.Close()
+				return err
+			}
+			return enc.Close()
+		}
+		// a schema document is itself JSON; it is never wrapped
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
 	}}
 
 	completionCmd := &cobra.Command{Use: "completion [bash|zsh|fish|powershell]", Short: "Generate shell completion script", Args: cobra.ExactArgs(1), RunE: func(cmd *cobra.Command, args []string) error {
@@ -667,24 +2605,479 @@ func NewRootCmd(out io.Writer) *cobra.Command {
 		return doc.GenManTree(root, header, dir)
 	}}
 
-	rootCmd.AddCommand(infoCmd, expandCmd, compressCmd, splitCmd, summarizeCmd, reverseCmd, toIntCmd, fromIntCmd, rangeCmd, supernetCmd, enumerateCmd, randomCmd, diffCmd, versionCmd, completionCmd, docsCmd, manCmd)
+	replCmd := &cobra.Command{Use: "repl", Short: "Run commands interactively, one per line", Args: cobra.NoArgs, Example: "  ip6calc repl\n  printf 'expand 2001:db8::1\\nformat json\\ninfo 2001:db8::/48\\n' | ip6calc repl", RunE: func(cmd *cobra.Command, args []string) error {
+		in := rootCmd.InOrStdin()
+		interactive := false
+		if f, ok := in.(*os.File); ok {
+			if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+				interactive = true
+			}
+		}
+		session := format // "format <fmt>" changes it for following lines
+		// global flags given when starting the REPL apply to every line; the
+		// line's own flags come later and so take precedence
+		var inherited []string
+		rootCmd.PersistentFlags().VisitAll(func(fl *pflag.Flag) {
+			if fl.Changed && fl.Name != "output" {
+				inherited = append(inherited, "--"+fl.Name+"="+fl.Value.String())
+			}
+		})
+		errOut := cmd.ErrOrStderr()
+		scanner := bufio.NewScanner(in)
+		for {
+			if interactive {
+				_, _ = fmt.Fprint(errOut, "ip6calc> ")
+			}
+			if !scanner.Scan() {
+				return scanner.Err()
+			}
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			switch fields[0] {
+			case "exit", "quit":
+				return nil
+			case "format":
+				if len(fields) != 2 {
+					_, _ = fmt.Fprintln(errOut, "error: usage: format human|json|yaml")
+				} else if err := session.Set(fields[1]); err != nil {
+					_, _ = fmt.Fprintf(errOut, "error: %v\n", err)
+				}
+				continue
+			}
+			// fresh tree per line so flag state never leaks between commands
+			sub := NewRootCmd(rootCmd.OutOrStdout())
+			sub.SetErr(errOut)
+			sub.SetIn(strings.NewReader(""))
+			sub.SilenceErrors, sub.SilenceUsage = true, true
+			sub.SetArgs(append(append([]string{"-o", string(session)}, inherited...), fields...))
+			if err := sub.Execute(); err != nil {
+				_, _ = fmt.Fprintf(errOut, "error: %v\n", err)
+			}
+		}
+	}}
+
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		silenceStructured(cmd)
+		return err
+	})
+	// Commands taking addresses or CIDRs complete arguments from the entries
+	// of $IP6CALC_COMPLETION_FILE, one per line; without it they suggest
+	// nothing rather than file names.
+	completePrefixes := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		path := os.Getenv("IP6CALC_COMPLETION_FILE")
+		if path == "" {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		lines, err := readLinesFile(path)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var matches []string
+		for _, l := range lines {
+			if strings.HasPrefix(l, toComplete) {
+				matches = append(matches, l)
+			}
+		}
+		return matches, cobra.ShellCompDirectiveNoFileComp
+	}
+	for _, c := range []*cobra.Command{infoCmd, maskCmd, positionCmd, atCmd, countCmd, expandCmd, compressCmd, dedupCmd, histogramCmd, groupCmd, treeCmd, bitsCmd, setbitCmd, hashCmd, explainCmd, multicastCmd, solicitedNodeCmd, splitCmd, fitCmd, planCmd, summarizeCmd, reverseCmd, toIntCmd, toHexCmd, nextCmd, prevCmd, addCmd, subCmd, supernetCmd, supernetOfCmd, commonPrefixCmd, siblingCmd, enumerateCmd, randomAddrCmd, randomSubnetCmd, diffCmd, gapsCmd, classifyRangeCmd, specialCmd, coversCmd, subnetsOfCmd, verifyCoverCmd, ulaInfoCmd, privacyCmd, anonymizeCmd, rewriteCmd} {
+		c.ValidArgsFunction = completePrefixes
+	}
+
+	rootCmd.AddCommand(infoCmd, maskCmd, tableCmd, positionCmd, atCmd, countCmd, expandCmd, compressCmd, dedupCmd, histogramCmd, groupCmd, treeCmd, bitsCmd, setbitCmd, bitwiseCmd, hashCmd, explainCmd, multicastCmd, solicitedNodeCmd, splitCmd, fitCmd, planCmd, summarizeCmd, reverseCmd, toIntCmd, fromIntCmd, toHexCmd, fromHexCmd, nextCmd, prevCmd, addCmd, subCmd, rangeCmd, walkCmd, supernetCmd, supernetOfCmd, commonPrefixCmd, siblingCmd, enumerateCmd, randomCmd, diffCmd, gapsCmd, filterCmd, classifyRangeCmd, specialCmd, coversCmd, subnetsOfCmd, verifyCoverCmd, ulaCmd, privacyCmd, anonymizeCmd, rewriteCmd, renumberCmd, replCmd, versionCmd, schemaCmd, completionCmd, docsCmd, manCmd)
+	wrapArgs(rootCmd)
 	return rootCmd
 }
 
-// Execute builds and runs the CLI using os.Stdout.
-func Execute() {
-	cmd := NewRootCmd(os.Stdout)
-	if err := cmd.Execute(); err != nil {
-		code := 1
-		switch {
-		case errors.Is(err, ipv6.ErrInvalidAddress), errors.Is(err, ipv6.ErrInvalidCIDR), errors.Is(err, ipv6.ErrInvalidPrefix), errors.Is(err, ipv6.ErrInvalidSplitPrefix):
-			code = exitCodeInvalidInput
-		case errors.Is(err, ErrSplitTooLarge), errors.Is(err, ipv6.ErrSplitExcessive):
-			code = exitCodeSplitTooBig
-		case errors.As(err, new(OverlapError)):
-			code = exitCodeOverlap
+// sortedKeys returns the keys of m in sorted order for stable output.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tsvCell flattens a value into a single TSV field.
+func tsvCell(v any) string {
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(fmt.Sprint(v))
+}
+
+// writeTSV renders v as tab-separated values: scalars on a single line,
+// string slices one per line, fields and maps as key/value pairs (maps sorted)
+// and struct slices as rows under a header of their json field names.
+func writeTSV(w io.Writer, v any, header bool) error {
+	writeRow := func(cells ...string) error {
+		_, err := fmt.Fprintln(w, strings.Join(cells, "\t"))
+		return err
+	}
+	if f, ok := structFields(v); ok {
+		v = f
+	}
+	if f, ok := v.(fields); ok {
+		for _, fl := range f {
+			if err := writeRow(fl.Key, tsvCell(fl.Value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if list, ok := v.([]fields); ok { // one row per result under a header of keys
+		for i, f := range list {
+			if i == 0 && header {
+				keys := make([]string, len(f))
+				for j, fl := range f {
+					keys[j] = fl.Key
+				}
+				if err := writeRow(keys...); err != nil {
+					return err
+				}
+			}
+			cells := make([]string, len(f))
+			for j, fl := range f {
+				cells[j] = tsvCell(fl.Value)
+			}
+			if err := writeRow(cells...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, ok := v.(fmt.Stringer); ok { // addresses, CIDRs and *big.Int counts
+		return writeRow(tsvCell(v))
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.String, rv.Kind() == reflect.Bool, rv.CanInt(), rv.CanUint(), rv.CanFloat():
+		return writeRow(tsvCell(v))
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < rv.Len(); i++ {
+			if err := writeRow(tsvCell(rv.Index(i).String())); err != nil {
+				return err
+			}
+		}
+		return nil
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := writeRow(k, tsvCell(rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())).Interface())); err != nil {
+				return err
+			}
+		}
+		return nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Struct:
+		et := rv.Type().Elem()
+		if header {
+			names := make([]string, et.NumField())
+			for i := range names {
+				names[i] = strings.Split(et.Field(i).Tag.Get("json"), ",")[0]
+				if names[i] == "" {
+					names[i] = et.Field(i).Name
+				}
+			}
+			if err := writeRow(names...); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < rv.Len(); i++ {
+			cells := make([]string, et.NumField())
+			for j := range cells {
+				cells[j] = tsvCell(rv.Index(i).Field(j).Interface())
+			}
+			if err := writeRow(cells...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.New("tsv output not supported for this command")
+}
+
+// parseInteger parses a non-negative integer in base, or, when base is 0,
+// in decimal unless prefixed by 0x (hex) or 0b (binary). Unlike big.Int's own
+// base 0 a leading zero does not mean octal. A 0x/0b prefix matching base is
+// accepted, and leading zeros are allowed in any base.
+func parseInteger(s string, base int) (*big.Int, error) {
+	if base != 0 && (base < 2 || base > 36) {
+		return nil, fmt.Errorf("invalid --base: %d (want 2..36)", base)
+	}
+	digits := strings.TrimSpace(s)
+	if len(digits) > 2 && digits[0] == '0' {
+		switch p := digits[1] | 0x20; { // lower-case the prefix letter
+		case p == 'x' && (base == 0 || base == 16):
+			digits, base = digits[2:], 16
+		case p == 'b' && (base == 0 || base == 2):
+			digits, base = digits[2:], 2
+		}
+	}
+	if base == 0 {
+		base = 10
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid integer: %q (base %d)", s, base)
+	}
+	return n, nil
+}
+
+// parseRange parses a "<start>-<end>" address range argument.
+func parseRange(s string) (start, end ipv6.Address, err error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return start, end, fmt.Errorf("%w: want <start>-<end>, got %q", ipv6.ErrInvalidRange, s)
+	}
+	if start, err = ipv6.Parse(parts[0]); err != nil {
+		return start, end, err
+	}
+	end, err = ipv6.Parse(parts[1])
+	return start, end, err
+}
+
+// collectAddresses drains up to limit addresses from it as strings.
+func collectAddresses(it *ipv6.AddressIterator, limit int) []string {
+	var list []string
+	for len(list) < limit {
+		addr, ok := it.Next()
+		if !ok {
+			break
+		}
+		list = append(list, addr.String())
+	}
+	return list
+}
+
+// addressToken matches runs of text that may be an IPv6 address: hex digits,
+// colons and dots (for an embedded IPv4 suffix) containing a colon.
+var addressToken = regexp.MustCompile(`[0-9A-Fa-f.]*:[0-9A-Fa-f:.]*`)
+
+// rewriteAddresses replaces every IPv6 address in line, IPv4-mapped ones
+// (::ffff:a.b.c.d, common in dual-stack logs) included, with fn of it and
+// leaves all other text, including trailing punctuation, untouched.
+func rewriteAddresses(line string, fn func(ipv6.Address) ipv6.Address) string {
+	return addressToken.ReplaceAllStringFunc(line, func(tok string) string {
+		if !strings.ContainsAny(tok, "0123456789abcdefABCDEF") { // "::" alone is too ambiguous in prose
+			return tok
 		}
-		fmt.Fprintf(os.Stderr, "ip6calc: %v\n", err)
-		os.Exit(code)
+		// shed trailing punctuation such as "from 2001:db8::1." or "2001:db8::1: msg"
+		for n := len(tok); n > 0; n-- {
+			if a, _, err := ipv6.ParseAllowV4Mapped(tok[:n]); err == nil {
+				return fn(a).String() + tok[n:]
+			}
+			if c := tok[n-1]; c != '.' && c != ':' {
+				break
+			}
+		}
+		return tok
+	})
+}
+
+// readLinesFile returns the trimmed, non-empty lines of the named file.
+func readLinesFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// effectiveFormat resolves the output format of cmd: the --output flag when
+// given, else a valid IP6CALC_FORMAT, else the default.
+func effectiveFormat(cmd *cobra.Command) outputFormat {
+	fl := cmd.Flags().Lookup("output")
+	if fl == nil {
+		fl = cmd.Root().PersistentFlags().Lookup("output")
+	}
+	f := outputFormat(fl.Value.String())
+	if !fl.Changed {
+		if envFmt := os.Getenv("IP6CALC_FORMAT"); envFmt != "" {
+			_ = f.Set(envFmt) // ignore invalid env value (explicit)
+		}
+	}
+	return f
+}
+
+// effectiveSchema resolves whether json and yaml results are wrapped in the
+// schema envelope: the --schema flag when given, else a valid IP6CALC_SCHEMA
+// boolean, else the config file's schema setting, which defaults to true.
+func effectiveSchema(cmd *cobra.Command) bool {
+	fl := cmd.Root().PersistentFlags().Lookup("schema")
+	if fl.Changed {
+		on, _ := strconv.ParseBool(fl.Value.String())
+		return on
+	}
+	if on, err := strconv.ParseBool(os.Getenv("IP6CALC_SCHEMA")); err == nil {
+		return on
+	}
+	on, _ := strconv.ParseBool(fl.Value.String()) // the config file's, or true
+	return on
+}
+
+// applyConfig sets global flags from the config file: $IP6CALC_CONFIG, else
+// ip6calc/config.yaml in the user config directory (~/.config on Linux).
+// Keys are global flag names, such as output: json or no-header: true. Flags
+// given on the command line are left alone, and environment variables such as
+// IP6CALC_FORMAT still override the file, so the order is flag, environment,
+// config, built-in default. A missing default file is not an error.
+func applyConfig(cmd *cobra.Command) error {
+	path, explicit := os.Getenv("IP6CALC_CONFIG"), true
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil
+		}
+		path, explicit = filepath.Join(dir, "ip6calc", "config.yaml"), false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("config: %w", err)
+	}
+	var settings map[string]any
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	flags := cmd.Root().PersistentFlags()
+	for _, key := range sortedKeys(settings) {
+		fl := flags.Lookup(key)
+		if fl == nil {
+			return fmt.Errorf("config %s: unknown setting %q", path, key)
+		}
+		if fl.Changed {
+			continue
+		}
+		if err := fl.Value.Set(fmt.Sprint(settings[key])); err != nil {
+			return fmt.Errorf("config %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// useColor resolves mode for output w. A non-empty NO_COLOR always disables
+// color (https://no-color.org); auto colors only when w is a terminal, so
+// piped or redirected output stays plain.
+func useColor(mode colorMode, w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	switch mode {
+	case colorAlways:
+		return true
+	case colorAuto:
+		f, ok := w.(*os.File)
+		if !ok {
+			return false
+		}
+		info, err := f.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0
+	}
+	return false
+}
+
+// silenceStructured stops cobra printing usage after a failure under json or
+// yaml output: usage goes to the command's output writer, where it would
+// corrupt the error document.
+func silenceStructured(cmd *cobra.Command) {
+	if f := effectiveFormat(cmd); f == outJSON || f == outYAML {
+		cmd.SilenceUsage = true
+	}
+}
+
+// wrapArgs makes argument validation of every command under c silence usage
+// for structured output; validation runs before PersistentPreRunE.
+func wrapArgs(c *cobra.Command) {
+	for _, sub := range c.Commands() {
+		wrapArgs(sub)
+	}
+	if c.Args == nil {
+		return
+	}
+	validate := c.Args
+	c.Args = func(cmd *cobra.Command, args []string) error {
+		err := validate(cmd, args)
+		if err != nil {
+			silenceStructured(cmd)
+		}
+		return err
 	}
 }
+
+// exitCode maps err to the process exit status.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, ipv6.ErrInvalidAddress), errors.Is(err, ipv6.ErrInvalidCIDR), errors.Is(err, ipv6.ErrInvalidPrefix), errors.Is(err, ipv6.ErrInvalidSplitPrefix), errors.Is(err, ipv6.ErrInvalidBit), errors.Is(err, ipv6.ErrNotContained), errors.Is(err, ipv6.ErrHostBitsSet), errors.Is(err, ipv6.ErrNotMulticast), errors.Is(err, ipv6.ErrNotULA), errors.Is(err, ipv6.ErrInvalidCount), errors.Is(err, ipv6.ErrHostsExceedNetwork), errors.Is(err, ipv6.ErrNoSpace), errors.Is(err, ipv6.ErrOverflow), errors.Is(err, ipv6.ErrInvalidPercentile), errors.Is(err, ipv6.ErrInvalidLimit), errors.Is(err, ipv6.ErrInvalidRange):
+		return exitCodeInvalidInput
+	case errors.Is(err, ErrSplitTooLarge), errors.Is(err, ipv6.ErrSplitExcessive):
+		return exitCodeSplitTooBig
+	case errors.As(err, new(OverlapError)):
+		return exitCodeOverlap
+	case errors.As(err, new(PartialFailureError)):
+		return exitCodeInvalidInput
+	}
+	return 1
+}
+
+// writeError writes err as {"schema":...,"error":{"code":...,"message":...}}
+// in format f (json or yaml); the code is the exit status. compact selects
+// single-line json as for results, but the document always carries the schema
+// key, even under --schema=false or --raw, so consumers can tell it apart from
+// a result.
+func writeError(w io.Writer, f outputFormat, compact bool, err error) error {
+	doc := fields{{"schema", SchemaVersion}, {"error", ErrorResult{exitCode(err), err.Error()}}}
+	if f == outYAML {
+		enc := yaml.NewEncoder(w)
+		if err := enc.Encode(doc); err != nil {
+			_ = enc.Close()
+			return err
+		}
+		return enc.Close()
+	}
+	enc := json.NewEncoder(w)
+	if !compact {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(doc)
+}
+
+// Run executes the CLI with args (excluding the program name), writing
+// results to out and diagnostics to errOut, and returns the process exit
+// status instead of exiting. Input is read from os.Stdin.
+func Run(args []string, out, errOut io.Writer) int {
+	cmd := NewRootCmd(out)
+	cmd.SetErr(errOut)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	if err == nil {
+		return 0
+	}
+	// Structured consumers get the error on stdout too, except after a
+	// partial failure whose good results are already there.
+	if f := effectiveFormat(cmd); (f == outJSON || f == outYAML) && !errors.As(err, new(PartialFailureError)) {
+		compact, _ := cmd.PersistentFlags().GetBool("compact")
+		_ = writeError(out, f, compact, err)
+	}
+	_, _ = fmt.Fprintf(errOut, "ip6calc: %v\n", err)
+	return exitCode(err)
+}
+
+// Execute runs the CLI on the process arguments and exits with Run's status.
+func Execute() {
+	os.Exit(Run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -3,10 +3,20 @@ package cli
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/zlobste/ip6calc/ipv6"
 )
 
 // Focused tests keeping coverage high without redundancy.
@@ -277,7 +287,10 @@ func TestJSONHostCountFields(t *testing.T) {
 	if data, ok := m["data"].(map[string]any); ok {
 		m = data
 	}
-	for _, k := range []string{"host_count", "host_count_power", "host_count_approx"} {
+	if m["usable_count"] != "18446744073709551615" {
+		t.Fatalf("unexpected usable_count: %v", m["usable_count"])
+	}
+	for _, k := range []string{"host_count", "host_count_power", "host_count_approx", "usable_count"} {
 		if _, ok := m[k]; !ok {
 			t.Fatalf("missing field %s", k)
 		}
@@ -303,3 +316,2552 @@ func TestOverlapErrorType(t *testing.T) {
 		t.Fatal("OverlapError does not implement error")
 	}
 }
+
+func TestEnumerateUsable(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "enumerate", "2001:db8::/126", "--limit", "2", "--usable"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("enumerate --usable failed: %v", err)
+	}
+	if out := strings.TrimSpace(buf.String()); out != "2001:db8::1\n2001:db8::2" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "enumerate", "2001:db8::/127", "--usable"})
+	if err := cmd.Execute(); err != nil || !strings.HasPrefix(buf.String(), "2001:db8::\n") {
+		t.Fatalf("--usable should be a no-op for /127: %v %q", err, buf.String())
+	}
+}
+
+func TestWalk(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "walk", "2001:db8::ff-2001:db8::101"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("walk failed: %v", err)
+	}
+	if out := strings.TrimSpace(buf.String()); out != "2001:db8::ff\n2001:db8::100\n2001:db8::101" {
+		t.Fatalf("unexpected walk output: %q", out)
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "walk", "::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "--limit", "2"})
+	if err := cmd.Execute(); err != nil || strings.Count(buf.String(), "\n") != 2 {
+		t.Fatalf("walk --limit failed: %v %q", err, buf.String())
+	}
+}
+
+func TestSummarizeMaxPrefix(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "summarize", "--max-prefix", "65", "2001:db8::/66", "2001:db8:0:0:4000::/66", "2001:db8:0:0:8000::/65"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("summarize --max-prefix failed: %v", err)
+	}
+	if out := strings.TrimSpace(buf.String()); out != "2001:db8::/65\n2001:db8:0:0:8000::/65" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"summarize", "--max-prefix", "129", "2001:db8::/64"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected invalid --max-prefix error")
+	}
+}
+
+func TestSummarizeGranularity(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "summarize", "--granularity", "56", "--allow-overcover", "2001:db8::1/128", "2001:db8:0:2::/64", "2001:db8:1::/48"})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:db8::/56\n2001:db8:1::/48\n" {
+		t.Fatalf("summarize --granularity: %v %q", err, buf.String())
+	}
+	for _, args := range [][]string{
+		{"summarize", "--granularity", "56", "2001:db8::1/128"},
+		{"summarize", "--granularity", "56", "--allow-overcover", "--stats", "2001:db8::1/128"},
+		{"summarize", "--granularity", "129", "--allow-overcover", "2001:db8::1/128"},
+	} {
+		cmd = NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestGapsCommand(t *testing.T) {
+	used := filepath.Join(t.TempDir(), "used.txt")
+	if err := os.WriteFile(used, []byte("2001:db8::1/128\n\n2001:db8::3/128\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "gaps", "2001:db8::/126", "--used", used})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("gaps failed: %v", err)
+	}
+	if out := strings.TrimSpace(buf.String()); out != "2001:db8::/128\n2001:db8::2/128" {
+		t.Fatalf("unexpected gaps output: %q", out)
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "--table", "gaps", "2001:db8::/126", "--used", used})
+	if err := cmd.Execute(); err != nil || !strings.Contains(buf.String(), "Start") || !strings.Contains(buf.String(), "2001:db8::2") {
+		t.Fatalf("gaps table failed: %v %q", err, buf.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "gaps", "2001:db8::/126", "--used", used})
+	if err := cmd.Execute(); err != nil || !strings.Contains(buf.String(), `"end": "2001:db8::2"`) {
+		t.Fatalf("gaps json failed: %v %q", err, buf.String())
+	}
+}
+
+func TestRangeAs(t *testing.T) {
+	run := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human", "range", "2001:db8::1-2001:db8::3"}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("range %v failed: %v", args, err)
+		}
+		return strings.TrimSpace(buf.String())
+	}
+	if out := run(); out != "2001:db8::1/128\n2001:db8::2/127" {
+		t.Fatalf("default should stay cidrs: %q", out)
+	}
+	if out := run("--as", "addresses"); out != "2001:db8::1\n2001:db8::3" {
+		t.Fatalf("unexpected endpoints: %q", out)
+	}
+	if out := run("--as", "addresses", "--limit", "10"); out != "2001:db8::1\n2001:db8::2\n2001:db8::3" {
+		t.Fatalf("unexpected walk: %q", out)
+	}
+	cmd := NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"range", "2001:db8::1-2001:db8::3", "--as", "hosts"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected invalid --as error")
+	}
+}
+
+func TestInfoAllowV4Mapped(t *testing.T) {
+	cmd := NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"info", "::ffff:192.0.2.1"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected rejection without --allow-v4mapped")
+	}
+	buf := &bytes.Buffer{}
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "info", "--allow-v4mapped", "::ffff:192.0.2.1"})
+	if err := cmd.Execute(); err != nil || !strings.Contains(buf.String(), "ipv4_mapped: 192.0.2.1") {
+		t.Fatalf("info --allow-v4mapped failed: %v %q", err, buf.String())
+	}
+}
+
+func TestSupernetOf(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "supernet-of", "2001:db8:1:2::/64", "--prefix", "32"})
+	if err := cmd.Execute(); err != nil || strings.TrimSpace(buf.String()) != "2001:db8::/32" {
+		t.Fatalf("supernet-of failed: %v %q", err, buf.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "supernet-of", "2001:db8:1:3::/64"})
+	if err := cmd.Execute(); err != nil || strings.TrimSpace(buf.String()) != "2001:db8:1:2::/63" {
+		t.Fatalf("supernet-of default failed: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"supernet-of", "2001:db8::/64", "--prefix", "80"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error for longer --prefix")
+	}
+}
+
+func TestSiblingCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "sibling", "2001:db8:0:0:8000::/65"})
+	if err := cmd.Execute(); err != nil || strings.TrimSpace(buf.String()) != "2001:db8::/65" {
+		t.Fatalf("sibling failed: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"sibling", "::/0"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error for /0")
+	}
+}
+
+func TestBitsCommand(t *testing.T) {
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human", "bits", "2001:db8::1"}, args...))
+		err := cmd.Execute()
+		return strings.TrimSpace(buf.String()), err
+	}
+	out, err := run()
+	if err != nil || strings.Count(out, ":") != 7 || !strings.HasPrefix(out, "0010000000000001:") {
+		t.Fatalf("default hextet grouping failed: %v %q", err, out)
+	}
+	out, err = run("--group", "nibble")
+	if err != nil || len(strings.Fields(out)) != 32 || !strings.HasPrefix(out, "0010 0000 0000 0001") {
+		t.Fatalf("nibble grouping failed: %v %q", err, out)
+	}
+	if out, err = run("--group", "byte"); err != nil || len(strings.Fields(out)) != 16 {
+		t.Fatalf("byte grouping failed: %v %q", err, out)
+	}
+	if _, err = run("--group", "word"); err == nil {
+		t.Fatal("expected invalid --group error")
+	}
+}
+
+func TestSetbitCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "setbit", "2001:db8::", "--pos", "127", "--val", "1"})
+	if err := cmd.Execute(); err != nil || strings.TrimSpace(buf.String()) != "2001:db8::1" {
+		t.Fatalf("setbit failed: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"setbit", "2001:db8::", "--pos", "128"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid bit") {
+		t.Fatalf("expected invalid bit error: %v", err)
+	}
+}
+
+func TestBitwiseCommand(t *testing.T) {
+	for op, want := range map[string]string{"and": "::1", "or": "2001:db8::ffff", "xor": "2001:db8::fffe"} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", "human", "bitwise", op, "2001:db8::1", "::ffff"})
+		if err := cmd.Execute(); err != nil || strings.TrimSpace(buf.String()) != want {
+			t.Fatalf("bitwise %s: %v %q", op, err, buf.String())
+		}
+	}
+}
+
+func TestMaskCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "mask", "2001:db8::/64"})
+	if err := cmd.Execute(); err != nil || !strings.Contains(buf.String(), "netmask: ffff:ffff:ffff:ffff::") || !strings.Contains(buf.String(), "hostmask: ::ffff:ffff:ffff:ffff") {
+		t.Fatalf("mask failed: %v %q", err, buf.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "info", "2001:db8::/48"})
+	if err := cmd.Execute(); err != nil || !strings.Contains(buf.String(), "netmask: ffff:ffff:ffff::") {
+		t.Fatalf("info netmask missing: %v %q", err, buf.String())
+	}
+}
+
+func TestPositionCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "position", "2001:db8::/64", "2001:db8::ffff:ffff:ffff:ffff"})
+	if err := cmd.Execute(); err != nil || !strings.Contains(buf.String(), "position: 18446744073709551615") || !strings.Contains(buf.String(), "is_last_address: true") {
+		t.Fatalf("position failed: %v %q", err, buf.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "position", "2001:db8::/64", "2001:db8::4000:0:0:0"})
+	if err := cmd.Execute(); err != nil || !strings.Contains(buf.String(), "percentile: 25\n") {
+		t.Fatalf("position percentile: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"position", "2001:db8::/64", "2001:db9::1"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "not in network") {
+		t.Fatalf("expected containment error: %v", err)
+	}
+}
+
+func TestExpandNibble(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "expand", "--nibble", "2001:db8::1"})
+	if err := cmd.Execute(); err != nil || strings.TrimSpace(buf.String()) != "2.0.0.1.0.d.b.8.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.1" {
+		t.Fatalf("expand --nibble failed: %v %q", err, buf.String())
+	}
+}
+
+func TestHashCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "hash", "2001:db8::1"})
+	if err := cmd.Execute(); err != nil || !strings.Contains(buf.String(), "hash_hex: f97161b7a3be1c14") || !strings.Contains(buf.String(), "hash: 17974255029166414868") {
+		t.Fatalf("hash failed: %v %q", err, buf.String())
+	}
+}
+
+func TestSummarizeSortedStdin(t *testing.T) {
+	in := "2001:db8::/66\n2001:db8:0:0:4000::/66\n2001:db8:0:0:8000::/65\n2001:db8:1::/64\n"
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetIn(strings.NewReader(in))
+	cmd.SetArgs([]string{"-o", "human", "summarize", "--sorted"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("summarize --sorted failed: %v", err)
+	}
+	if out := strings.TrimSpace(buf.String()); out != "2001:db8::/64\n2001:db8:1::/64" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	// unsorted input is rejected rather than silently mis-merged
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetIn(strings.NewReader("2001:db8:1::/64\n2001:db8::/64\n"))
+	cmd.SetArgs([]string{"summarize", "--sorted"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "not sorted") {
+		t.Fatalf("expected unsorted error: %v", err)
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetIn(strings.NewReader("2001:db8::/48\n2001:db8::/64\n"))
+	cmd.SetArgs([]string{"summarize", "--sorted", "--fail-on-overlap"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "overlap detected") {
+		t.Fatalf("expected overlap error: %v", err)
+	}
+	// batch mode reads stdin too
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetIn(strings.NewReader("2001:db8:0:0:8000::/65\n2001:db8::/65\n"))
+	cmd.SetArgs([]string{"-o", "human", "summarize"})
+	if err := cmd.Execute(); err != nil || strings.TrimSpace(buf.String()) != "2001:db8::/64" {
+		t.Fatalf("summarize from stdin failed: %v %q", err, buf.String())
+	}
+}
+
+func TestCountOnly(t *testing.T) {
+	t.Setenv("IP6CALC_SPLIT_FORCE_THRESHOLD", "65536")
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-o", "human", "split", "2001:db8::/48", "--new-prefix", "52", "--count-only"}, "16"},
+		{[]string{"-o", "human", "split", "2001:db8::/32", "--new-prefix", "64", "--count-only", "--force"}, "4294967296"},
+		{[]string{"-o", "human", "enumerate", "2001:db8::/64", "--limit", "5", "--count-only"}, "5"},
+		{[]string{"-o", "human", "enumerate", "2001:db8::/126", "--limit", "10", "--count-only"}, "4"},
+		{[]string{"-o", "human", "enumerate", "2001:db8::/124", "--limit", "10", "--stride", "4", "--usable", "--count-only"}, "4"},
+	}
+	for _, tc := range cases {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(tc.args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != tc.want {
+			t.Fatalf("%v: got %q want %q", tc.args, got, tc.want)
+		}
+	}
+	// force threshold still applies
+	cmd := NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"split", "2001:db8::/32", "--new-prefix", "64", "--count-only"})
+	if err := cmd.Execute(); !errors.Is(err, ErrSplitTooLarge) {
+		t.Fatalf("expected ErrSplitTooLarge, got %v", err)
+	}
+	buf := &bytes.Buffer{}
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "split", "2001:db8::/48", "--new-prefix", "52", "--count-only"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Data struct {
+			Count int `json:"count"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &payload); err != nil || payload.Data.Count != 16 {
+		t.Fatalf("unexpected json %q: %v", buf.String(), err)
+	}
+}
+
+func TestSummarizeStatsFlag(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "summarize", "--stats", "2001:db8::/65", "2001:db8:0:0:8000::/65", "2001:db8::/64"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Data struct {
+			InputCount     int    `json:"input_count"`
+			OutputCount    int    `json:"output_count"`
+			TotalAddresses string `json:"total_addresses"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	if d := payload.Data; d.InputCount != 3 || d.OutputCount != 1 || d.TotalAddresses != "18446744073709551616" {
+		t.Fatalf("unexpected stats %+v", d)
+	}
+}
+
+func TestStrictCIDR(t *testing.T) {
+	cmd := NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--strict", "info", "2001:db8::1/64"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "canonical form is 2001:db8::/64") {
+		t.Fatalf("expected strict error, got %v", err)
+	}
+	buf := &bytes.Buffer{}
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "summarize", "2001:db8::1/64"})
+	if err := cmd.Execute(); err != nil || strings.TrimSpace(buf.String()) != "2001:db8::/64" {
+		t.Fatalf("non-strict parse should mask: %v %q", err, buf.String())
+	}
+}
+
+func TestFilterCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetIn(strings.NewReader("2001:db8::1\nfe80::1\nfd00::1\nff02::1\n"))
+	cmd.SetArgs([]string{"-o", "human", "filter", "--link-local", "--unique-local"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("filter failed: %v", err)
+	}
+	if out := strings.TrimSpace(buf.String()); out != "fe80::1\nfd00::1" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetIn(strings.NewReader("2001:db8::1\n"))
+	cmd.SetArgs([]string{"filter"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error without a filter flag")
+	}
+}
+
+func TestMulticastCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "multicast", "ff3e:30:2001:db8::1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("multicast failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"flags: 3", "prefix_based: true", "scope: 14", "scope_name: global", "transient: true"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %q", want, out)
+		}
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"multicast", "2001:db8::1"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "not a multicast") {
+		t.Fatalf("expected not-multicast error, got %v", err)
+	}
+}
+
+func TestSolicitedNodeCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "solicited-node", "fe80::21b:21ff:fe3a:4b5c"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("solicited-node failed: %v", err)
+	}
+	if out := strings.TrimSpace(buf.String()); out != "ff02::1:ff3a:4b5c" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestULAGenerate(t *testing.T) {
+	for _, args := range [][]string{{"-o", "human", "ula", "generate"}, {"-o", "human", "ula", "generate", "--mac", "00:1b:21:3a:4b:5c"}} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		out := strings.TrimSpace(buf.String())
+		if !strings.HasPrefix(out, "fd") || !strings.HasSuffix(out, "::/48") {
+			t.Fatalf("%v: unexpected prefix %q", args, out)
+		}
+	}
+}
+
+func TestULAInfo(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "ula", "info", "fd12:3456:789a:1::1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ula info failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"global_id: 123456789a", "subnet_id: 0001", "local: true", "prefix: fd12:3456:789a::/48"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %q", want, out)
+		}
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"ula", "info", "2001:db8::1"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error for non-ULA address")
+	}
+}
+
+func TestREPL(t *testing.T) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := NewRootCmd(out)
+	cmd.SetErr(errOut)
+	cmd.SetIn(strings.NewReader("expand 2001:db8::1\n\nexpand not-an-address\nformat json\ncompress 2001:0db8::2\nquit\nexpand 2001:db8::3\n"))
+	cmd.SetArgs([]string{"-o", "human", "repl"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("repl failed: %v", err)
+	}
+	got := out.String()
+	if !strings.HasPrefix(got, "2001:0db8:0000:0000:0000:0000:0000:0001\n") {
+		t.Fatalf("human line missing: %q", got)
+	}
+	if !strings.Contains(got, `"schema": "ip6calc/v1"`) || !strings.Contains(got, "2001:db8::2") {
+		t.Fatalf("format switch not applied: %q", got)
+	}
+	if strings.Contains(got, "2001:db8::3") || strings.Contains(got, "0003") {
+		t.Fatalf("lines after quit were executed: %q", got)
+	}
+	if !strings.Contains(errOut.String(), "error: ") {
+		t.Fatalf("error not reported: %q", errOut.String())
+	}
+}
+
+func TestREPLInheritsGlobalFlags(t *testing.T) {
+	out := &bytes.Buffer{}
+	cmd := NewRootCmd(out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetIn(strings.NewReader("info 2001:db8::1\ninfo 2001:db8::1/48\nformat json\ncompress 2001:db8::2\n"))
+	cmd.SetArgs([]string{"-o", "human", "--upper", "--strict", "--schema=false", "repl"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("repl failed: %v", err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "2001:0DB8:0000:0000:0000:0000:0000:0001") {
+		t.Fatalf("--upper not applied: %q", got)
+	}
+	if strings.Contains(got, "2001:db8::/48") {
+		t.Fatalf("--strict not applied: %q", got)
+	}
+	if strings.Contains(got, "schema") || !strings.Contains(got, `"2001:db8::2"`) {
+		t.Fatalf("--schema=false not applied: %q", got)
+	}
+}
+
+func TestPrint0(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "--print0", "expand", "2001:db8::1", "2001:db8::2"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	want := "2001:0db8:0000:0000:0000:0000:0000:0001\x002001:0db8:0000:0000:0000:0000:0000:0002\x00"
+	if buf.String() != want {
+		t.Fatalf("got %q want %q", buf.String(), want)
+	}
+	buf.Reset()
+	errOut := &bytes.Buffer{}
+	cmd = NewRootCmd(buf)
+	cmd.SetErr(errOut)
+	cmd.SetArgs([]string{"-o", "json", "--print0", "expand", "2001:db8::1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "\x00") || !strings.Contains(errOut.String(), "--print0 ignored") {
+		t.Fatalf("json output should ignore --print0 with a warning: %q / %q", buf.String(), errOut.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "--print0", "next", "2001:db8::1"})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:db8::2\x00" {
+		t.Fatalf("scalar --print0: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"-o", "human", "--print0", "--table", "expand", "2001:db8::1", "2001:db8::2"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("--print0 with --table accepted")
+	}
+}
+
+func TestTableMapAndTSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "--table", "info", "2001:db8::/64"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.HasPrefix(lines[0], "Field") || !strings.Contains(lines[0], "Value") {
+		t.Fatalf("missing header: %q", lines[0])
+	}
+	col := strings.Index(lines[0], "Value")
+	for i, l := range lines[1:] {
+		if l[col-1] != ' ' || l[col] == ' ' {
+			t.Fatalf("misaligned row %d: %q", i, l)
+		}
+	}
+	// rows follow the command's field order, which is stable across runs
+	if !strings.HasPrefix(lines[1], "network ") || !strings.HasPrefix(lines[2], "prefix_length ") {
+		t.Fatalf("unexpected field order: %q", lines[1:3])
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "tsv", "info", "2001:db8::/64"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "network\t2001:db8::\n") || !strings.Contains(buf.String(), "prefix_length\t64\n") {
+		t.Fatalf("unexpected tsv map: %q", buf.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "tsv", "split", "2001:db8::/126", "--new-prefix", "127"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "2001:db8::/127\n2001:db8::2/127\n" {
+		t.Fatalf("unexpected tsv list: %q", buf.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetIn(strings.NewReader("2001:db8::/127\n"))
+	cmd.SetArgs([]string{"-o", "tsv", "gaps", "2001:db8::/126"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "cidr\tstart\tend\n2001:db8::2/127\t2001:db8::2\t2001:db8::3\n" {
+		t.Fatalf("unexpected tsv rows: %q", buf.String())
+	}
+}
+
+func TestFieldOrderJSONYAML(t *testing.T) {
+	keysIn := func(out string, keys []string) []int {
+		idx := make([]int, len(keys))
+		for i, k := range keys {
+			idx[i] = strings.Index(out, k)
+		}
+		return idx
+	}
+	order := []string{"schema", "data", "network", "prefix_length", "netmask", "hostmask", "first_host", "last_host", "host_count", "usable_count"}
+	for _, f := range []string{"json", "yaml"} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", f, "info", "2001:db8::/64"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		idx := keysIn(buf.String(), order)
+		for i := 1; i < len(idx); i++ {
+			if idx[i-1] < 0 || idx[i] <= idx[i-1] {
+				t.Fatalf("%s: %q not before %q in %s", f, order[i-1], order[i], buf.String())
+			}
+		}
+	}
+}
+
+func TestInfoFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "info", "2001:db8::/64", "--fields", "host_count,network"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); out != "host_count: 18446744073709551616\nnetwork: 2001:db8::\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "info", "2001:db8::1", "--fields", "reverse,address"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "expanded") || strings.Index(out, "reverse") > strings.Index(out, "address") {
+		t.Fatalf("address-mode selection wrong: %s", out)
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"info", "2001:db8::/64", "--fields", "bogus"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), `unknown field "bogus"`) || !strings.Contains(err.Error(), "netmask") {
+		t.Fatalf("expected unknown field error listing valid fields, got %v", err)
+	}
+}
+
+func TestInfoAll(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetIn(strings.NewReader("2001:db8::/64\n2001:db8::1\n"))
+	cmd.SetArgs([]string{"-o", "json", "info", "--all"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Data []map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &payload); err != nil || len(payload.Data) != 2 {
+		t.Fatalf("expected 2 results: %v %s", err, buf.String())
+	}
+	if payload.Data[0]["network"] != "2001:db8::" || payload.Data[1]["address"] != "2001:db8::1" {
+		t.Fatalf("unexpected results %v", payload.Data)
+	}
+	// a bad line aborts by default, naming the line
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"info", "--all", "2001:db8::1", "bogus"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "line 2 (bogus)") {
+		t.Fatalf("expected per-line error, got %v", err)
+	}
+	buf.Reset()
+	errOut := &bytes.Buffer{}
+	cmd = NewRootCmd(buf)
+	cmd.SetErr(errOut)
+	cmd.SetArgs([]string{"-o", "human", "info", "--all", "--continue-on-error", "2001:db8::1", "bogus", "2001:db8::/48"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 inputs failed") {
+		t.Fatalf("expected summary error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "address: 2001:db8::1") || !strings.Contains(buf.String(), "\n\nnetwork: 2001:db8::") {
+		t.Fatalf("good results missing: %q", buf.String())
+	}
+	if !strings.Contains(errOut.String(), "line 2 (bogus)") {
+		t.Fatalf("error not reported on stderr: %q", errOut.String())
+	}
+}
+
+func TestContinueOnError(t *testing.T) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := NewRootCmd(out)
+	cmd.SetErr(errOut)
+	cmd.SetIn(strings.NewReader("2001:0db8::1\nnope\n2001:0db8::2\n"))
+	cmd.SetArgs([]string{"-o", "human", "--continue-on-error", "compress"})
+	err := cmd.Execute()
+	var pf PartialFailureError
+	if !errors.As(err, &pf) || pf.Failed != 1 || pf.Total != 3 {
+		t.Fatalf("expected PartialFailureError 1/3, got %v", err)
+	}
+	if out.String() != "2001:db8::1\n2001:db8::2\n" {
+		t.Fatalf("good output missing: %q", out.String())
+	}
+	if !strings.Contains(errOut.String(), "error: line 2 (nope)") {
+		t.Fatalf("missing line context on stderr: %q", errOut.String())
+	}
+	// without the flag the first bad input aborts
+	out.Reset()
+	cmd = NewRootCmd(out)
+	cmd.SetArgs([]string{"-o", "human", "expand", "2001:db8::1", "nope"})
+	if err := cmd.Execute(); err == nil || errors.As(err, &pf) || strings.Contains(out.String(), "2001:0db8") {
+		t.Fatalf("expected abort without output, got %v %q", err, out.String())
+	}
+}
+
+func TestExplain(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "explain", "2001:db8::/64"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Explaining 2001:db8::/64", "64 network bits before |, 64 host bits after", "2. Binary of the base\n   0010000000000001", "Next network\n   2001:db8:0:1::/64", "Previous network\n   2001:db7:ffff:ffff::/64"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("missing %q in:\n%s", want, buf.String())
+		}
+	}
+	for _, tc := range []struct{ cidr, next, prev, count string }{
+		{"::/0", "none", "none", "340282366920938463463374607431768211456"},
+		{"2001:db8::/127", "2001:db8::2/127", "2001:db7:ffff:ffff:ffff:ffff:ffff:fffe/127", "2"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128", "none", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/128", "1"},
+	} {
+		buf.Reset()
+		cmd = NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", "json", "explain", tc.cidr})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%s: %v", tc.cidr, err)
+		}
+		var payload struct {
+			Data struct {
+				Steps []struct {
+					Title, Value string
+				} `json:"steps"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &payload); err != nil || len(payload.Data.Steps) != 8 {
+			t.Fatalf("%s: bad json %v %s", tc.cidr, err, buf.String())
+		}
+		st := payload.Data.Steps
+		if st[3].Value != tc.count || st[6].Value != tc.next || st[7].Value != tc.prev {
+			t.Fatalf("%s: unexpected steps %+v", tc.cidr, st)
+		}
+	}
+}
+
+func TestSplitCount(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "split", "2001:db8::/48", "--count", "4"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if out := strings.TrimSpace(buf.String()); out != "2001:db8::/50\n2001:db8:0:4000::/50\n2001:db8:0:8000::/50\n2001:db8:0:c000::/50" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"split", "2001:db8::/48", "--count", "5"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "4 or 8") {
+		t.Fatalf("expected nearest-count error, got %v", err)
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"split", "2001:db8::/48", "--count", "4", "--new-prefix", "50"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected mutual exclusion error")
+	}
+}
+
+func TestFitCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "fit", "2001:db8::/48", "--hosts", "300"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"prefix_length: 119", "first_subnet: 2001:db8::/119", "host_count: 512", "subnets_available: 2361183241434822606848"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("missing %q in %q", want, buf.String())
+		}
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "fit", "2001:db8::/32", "--hosts", "36893488147419103232"}) // 2^65
+	if err := cmd.Execute(); err != nil || !strings.Contains(buf.String(), "prefix_length: 63") {
+		t.Fatalf("big host count: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"fit", "2001:db8::/120", "--hosts", "1000"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "exceed") {
+		t.Fatalf("expected does-not-fit error, got %v", err)
+	}
+}
+
+func TestPlanCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "plan", "2001:db8::/116", "--hosts", "50,1000,500"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if out := strings.TrimSpace(buf.String()); out != "2001:db8::600/122\n2001:db8::/118\n2001:db8::400/119" {
+		t.Fatalf("unexpected plan %q", out)
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"plan", "2001:db8::/120", "--hosts", "200,200"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "not enough space") {
+		t.Fatalf("expected no-space error, got %v", err)
+	}
+}
+
+func TestSplitValidate(t *testing.T) {
+	t.Setenv("IP6CALC_SPLIT_FORCE_THRESHOLD", "64")
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-o", "human", "split", "2001:db8::/48", "--new-prefix", "52", "--validate"}, "ok, 16 subnets"},
+		{[]string{"-o", "human", "split", "2001:db8::/48", "--new-prefix", "56", "--validate"}, "ok, 256 subnets (requires --force)"},
+		{[]string{"-o", "human", "split", "2001:db8::/48", "--new-prefix", "56", "--validate", "--force"}, "ok, 256 subnets (requires --force)"},
+		{[]string{"-o", "human", "split", "2001:db8::/48", "--count", "4", "--validate"}, "ok, 4 subnets"},
+		{[]string{"-o", "human", "--quiet", "split", "2001:db8::/48", "--new-prefix", "52", "--validate"}, ""},
+	}
+	for _, tc := range cases {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(tc.args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != tc.want {
+			t.Fatalf("%v: got %q want %q", tc.args, got, tc.want)
+		}
+	}
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "split", "2001:db8::/48", "--new-prefix", "56", "--validate"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Data struct {
+			Valid         bool   `json:"valid"`
+			Subnets       uint64 `json:"subnets"`
+			ForceRequired bool   `json:"force_required"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if !payload.Data.Valid || payload.Data.Subnets != 256 || !payload.Data.ForceRequired {
+		t.Fatalf("unexpected validate payload %+v", payload.Data)
+	}
+	for _, args := range [][]string{
+		{"split", "2001:db8::/48", "--new-prefix", "40", "--validate"},
+		{"split", "2001:db8::/32", "--new-prefix", "64", "--validate"},
+	} {
+		cmd := NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestTableMatchesInfo(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "table"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var table struct {
+		Data []map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &table); err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Data) != 129 {
+		t.Fatalf("expected 129 rows, got %d", len(table.Data))
+	}
+	for plen, cidr := range map[int]string{0: "::/0", 48: "2001:db8::/48", 64: "2001:db8::/64", 127: "2001:db8::/127", 128: "2001:db8::1/128"} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", "json", "info", cidr})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		var info struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+			t.Fatal(err)
+		}
+		row := table.Data[plen]
+		for _, k := range []string{"netmask", "host_count", "host_count_power", "host_count_approx"} {
+			if row[k] != info.Data[k] {
+				t.Fatalf("/%d %s: table %v info %v", plen, k, row[k], info.Data[k])
+			}
+		}
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "prefixes"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "/64     2^64   1.84e19  ffff:ffff:ffff:ffff::") {
+		t.Fatalf("unexpected table output:\n%s", buf.String())
+	}
+}
+
+// schemaAnchors holds the $anchor subschemas validateSchema has passed
+// through, so that nested $refs can be resolved.
+var schemaAnchors = map[string]map[string]any{}
+
+// validateSchema checks v against the subset of JSON Schema emitted by
+// schemaOf and outputDocument.
+func validateSchema(s map[string]any, v any) error {
+	if name, ok := s["$anchor"].(string); ok {
+		schemaAnchors[name] = s
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		if s, ok = schemaAnchors[strings.TrimPrefix(ref, "#")]; !ok {
+			return fmt.Errorf("unresolved $ref %q", ref)
+		}
+	}
+	if alts, ok := s["oneOf"].([]any); ok {
+		matched := 0
+		for _, alt := range alts {
+			if validateSchema(alt.(map[string]any), v) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%v matches %d oneOf alternatives", v, matched)
+		}
+		return nil
+	}
+	if c, ok := s["const"]; ok && c != v {
+		return fmt.Errorf("want const %v, got %v", c, v)
+	}
+	types := []any{s["type"]}
+	if list, ok := s["type"].([]any); ok {
+		types = list
+	}
+	kind := map[string]bool{}
+	switch x := v.(type) {
+	case nil:
+		kind["null"] = true
+	case string:
+		kind["string"] = true
+	case bool:
+		kind["boolean"] = true
+	case float64:
+		kind["number"], kind["integer"] = true, x == float64(int64(x))
+	case []any:
+		kind["array"] = true
+		if items, ok := s["items"].(map[string]any); ok {
+			for _, it := range x {
+				if err := validateSchema(items, it); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		kind["object"] = true
+		props, _ := s["properties"].(map[string]any)
+		for k, val := range x {
+			p, ok := props[k]
+			if !ok {
+				if p, ok = s["additionalProperties"].(map[string]any); !ok {
+					return fmt.Errorf("unexpected property %q", k)
+				}
+			}
+			if err := validateSchema(p.(map[string]any), val); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+		}
+		req, _ := s["required"].([]any)
+		for _, k := range req {
+			if _, ok := x[k.(string)]; !ok {
+				return fmt.Errorf("missing property %q", k)
+			}
+		}
+	}
+	for _, t := range types {
+		if t == nil || kind[t.(string)] {
+			return nil
+		}
+	}
+	return fmt.Errorf("%v is not of type %v", v, s["type"])
+}
+
+func TestSchemaMatchesOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"schema"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var docs map[string]map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	// every command producing output is described
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+		path := strings.TrimPrefix(c.CommandPath(), "ip6calc ")
+		switch path {
+		case "repl", "completion", "docs", "man", "schema", "help":
+			return
+		}
+		if _, ok := docs[path]; !ok && c.RunE != nil {
+			t.Errorf("no schema for %q", path)
+		}
+	}
+	walk(NewRootCmd(&bytes.Buffer{}))
+	for _, args := range [][]string{
+		{"info", "2001:db8::/64"},
+		{"info", "2001:db8::1/64", "--keep-host"},
+		{"info", "2001:db8::1"},
+		{"info", "--all", "--allow-v4mapped", "2001:db8::/64", "::ffff:192.0.2.1"},
+		{"mask", "2001:db8::/64"},
+		{"table"},
+		{"position", "2001:db8::/64", "2001:db8::ff"},
+		{"at", "2001:db8::/64", "--percent", "25"},
+		{"count", "2001:db8::/48"},
+		{"tree", "2001:db8::/48", "--depth", "2"},
+		{"expand", "2001:db8::1"},
+		{"expand", "2001:db8::1", "::1", "--indexed"},
+		{"bits", "::1"},
+		{"bitwise", "xor", "::1", "::3"},
+		{"hash", "2001:db8::1"},
+		{"explain", "2001:db8::/64"},
+		{"multicast", "ff02::1"},
+		{"split", "2001:db8::/64", "--new-prefix", "66"},
+		{"split", "2001:db8::/64", "--new-prefix", "66", "--count-only"},
+		{"split", "2001:db8::/64", "--new-prefix", "66", "--validate"},
+		{"reverse", "2001:db8::1"},
+		{"reverse", "2001:db8::/126"},
+		{"fit", "2001:db8::/48", "--hosts", "300"},
+		{"plan", "2001:db8::/48", "--hosts", "300,20"},
+		{"summarize", "--stats", "2001:db8::/65", "2001:db8:0:0:8000::/65"},
+		{"range", "2001:db8::1-2001:db8::ff", "--with-meta"},
+		{"range", "2001:db8::1-2001:db8::ff", "--with-meta", "--max-cidrs", "2"},
+		{"histogram", "2001:db8::/64", "2001:db8:1::/48"},
+		{"special", "::1"},
+		{"group", "--prefix", "48", "2001:db8::1", "2001:db8:1::1"},
+		{"enumerate", "2001:db8::/64", "--count-only"},
+		{"diff", "2001:db8::/65", "2001:db8::/64", "2001:db8:1::/64"},
+		{"gaps", "2001:db8::/48", "--used", "/dev/null"},
+		{"ula", "info", "fd12:3456:789a:1::1"},
+		{"version"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "json"}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		var got any
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		name := args[0]
+		if name == "bitwise" || name == "ula" {
+			name += " " + args[1]
+		}
+		if err := validateSchema(docs[name], got); err != nil {
+			t.Fatalf("%v: %v\n%s", args, err, buf.String())
+		}
+	}
+}
+
+func TestClassifyRange(t *testing.T) {
+	for _, tc := range []struct{ cidr, want string }{
+		{"2001:db8::/48", "cidr: 2001:db8::/48\ntypes: global-unicast, documentation\nuniform: true\n"},
+		{"fc00::/6", "cidr: fc00::/6\ntypes: link-local, unique-local, multicast, unclassified\nuniform: false\n"},
+		{"4000::/2", "cidr: 4000::/2\ntypes: unclassified\nuniform: true\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", "human", "classify-range", tc.cidr})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.want {
+			t.Fatalf("%s: got %q want %q", tc.cidr, buf.String(), tc.want)
+		}
+	}
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "classify-range", "2000::/3"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"types": [
+      "global-unicast",
+      "documentation"
+    ],
+    "uniform": false`) {
+		t.Fatalf("unexpected json: %s", buf.String())
+	}
+}
+
+func TestDedupCommand(t *testing.T) {
+	in := "2001:db8::2\n2001:DB8::1\n2001:0db8:0000:0000:0000:0000:0000:0001\n2001:db8::5/64\n2001:db8::/64\n2001:db8::1/128\n"
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "2001:db8::2\n2001:db8::1\n2001:db8::/64\n2001:db8::1/128\n"},
+		{[]string{"--sort"}, "2001:db8::/64\n2001:db8::1\n2001:db8::1/128\n2001:db8::2\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetIn(strings.NewReader(in))
+		cmd.SetArgs(append([]string{"-o", "human", "dedup"}, tc.args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.want {
+			t.Fatalf("%v: got %q want %q", tc.args, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestSplitProgress(t *testing.T) {
+	t.Setenv("IP6CALC_SPLIT_FORCE_THRESHOLD", "32") // stream (and report) above 16 subnets
+	run := func(args ...string) (string, string, error) {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		cmd := NewRootCmd(out)
+		cmd.SetErr(errOut)
+		cmd.SetArgs(append([]string{"split", "2001:db8::/64", "--new-prefix", "69"}, args...))
+		err := cmd.Execute()
+		return out.String(), errOut.String(), err
+	}
+	out, errOut, err := run("-o", "human")
+	if err != nil || !strings.Contains(errOut, "progress: 32/32 (100%)") || strings.Contains(out, "progress") {
+		t.Fatalf("default streaming progress: %v out=%q err=%q", err, out, errOut)
+	}
+	for _, flag := range []string{"--no-progress", "--quiet"} {
+		if _, errOut, err := run("-o", "human", flag); err != nil || strings.Contains(errOut, "progress") {
+			t.Fatalf("%s should suppress progress: %v %q", flag, err, errOut)
+		}
+	}
+	out, errOut, err = run("-o", "json", "--progress")
+	var payload struct {
+		Data []string `json:"data"`
+	}
+	if err != nil || json.Unmarshal([]byte(out), &payload) != nil || len(payload.Data) != 32 || !strings.Contains(errOut, "progress: 15/32 (47%)") {
+		t.Fatalf("--progress with json: %v out=%q err=%q", err, out, errOut)
+	}
+	if _, _, err := run("--progress", "--no-progress"); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
+
+func TestStructuredError(t *testing.T) {
+	t.Setenv("IP6CALC_SPLIT_FORCE_THRESHOLD", "")
+	for _, tc := range []struct {
+		env  string
+		args []string
+		code int
+	}{
+		{"", []string{"-o", "json", "info", "bogus"}, exitCodeInvalidInput},
+		{"", []string{"-o", "json", "mask"}, 1},                    // argument validation
+		{"", []string{"-o", "yaml", "mask", "--bogus", "::/0"}, 1}, // flag parsing
+		{"json", []string{"summarize", "--fail-on-overlap", "2001:db8::/64", "2001:db8::/65"}, exitCodeOverlap},
+		{"json", []string{"split", "2001:db8::/32", "--new-prefix", "60"}, exitCodeSplitTooBig},
+	} {
+		t.Setenv("IP6CALC_FORMAT", tc.env)
+		out := &bytes.Buffer{}
+		cmd := NewRootCmd(out)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(tc.args)
+		err := cmd.Execute()
+		if err == nil {
+			t.Fatalf("%v: expected error", tc.args)
+		}
+		if out.Len() != 0 {
+			t.Fatalf("%v: stdout must stay clean for the error document, got %q", tc.args, out.String())
+		}
+		f := effectiveFormat(cmd)
+		if f != outJSON && f != outYAML {
+			t.Fatalf("%v: resolved format %q", tc.args, f)
+		}
+		if err := writeError(out, f, false, err); err != nil {
+			t.Fatal(err)
+		}
+		var doc struct {
+			Schema string
+			Error  struct {
+				Code    int
+				Message string
+			}
+		}
+		if f == outYAML {
+			err = yaml.Unmarshal(out.Bytes(), &doc)
+		} else {
+			err = json.Unmarshal(out.Bytes(), &doc)
+		}
+		if err != nil || doc.Schema != SchemaVersion || doc.Error.Code != tc.code || doc.Error.Message == "" {
+			t.Fatalf("%v: bad error document %v %q", tc.args, err, out.String())
+		}
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	t.Setenv("IP6CALC_FORMAT", "")
+	t.Setenv("IP6CALC_SPLIT_FORCE_THRESHOLD", "")
+	for _, tc := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"expand", "::1"}, 0},
+		{[]string{"mask"}, 1},
+		{[]string{"info", "bogus"}, exitCodeInvalidInput},
+		{[]string{"--continue-on-error", "expand", "::1", "bogus"}, exitCodeInvalidInput},
+		{[]string{"summarize", "--fail-on-overlap", "2001:db8::/64", "2001:db8::/65"}, exitCodeOverlap},
+		{[]string{"split", "2001:db8::/32", "--new-prefix", "60"}, exitCodeSplitTooBig},
+		{[]string{"range", "2001:db8::ff-2001:db8::1"}, exitCodeInvalidInput},
+		{[]string{"range", "--as", "addresses", "2001:db8::ff-2001:db8::1"}, exitCodeInvalidInput},
+		{[]string{"walk", "2001:db8::ff-2001:db8::1"}, exitCodeInvalidInput},
+		{[]string{"range", "2001:db8::1"}, exitCodeInvalidInput},
+	} {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		if code := Run(tc.args, out, errOut); code != tc.code {
+			t.Fatalf("%v: exit %d want %d (stderr %q)", tc.args, code, tc.code, errOut.String())
+		}
+		if tc.code != 0 && !strings.Contains(errOut.String(), "ip6calc: ") {
+			t.Fatalf("%v: error not reported on stderr: %q", tc.args, errOut.String())
+		}
+	}
+	out := &bytes.Buffer{}
+	if code := Run([]string{"-o", "json", "info", "bogus"}, out, &bytes.Buffer{}); code != exitCodeInvalidInput || !strings.Contains(out.String(), `"code": 2`) {
+		t.Fatalf("expected json error document, got %d %q", code, out.String())
+	}
+	out.Reset()
+	Run([]string{"-o", "json", "--compact", "--raw", "info", "bogus"}, out, &bytes.Buffer{})
+	if !strings.HasPrefix(out.String(), `{"schema":"ip6calc/v1","error":{"code":2,`) || strings.Count(out.String(), "\n") != 1 {
+		t.Fatalf("expected compact, wrapped error document, got %q", out.String())
+	}
+}
+
+func TestIntBases(t *testing.T) {
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human"}, args...))
+		err := cmd.Execute()
+		return strings.TrimSpace(buf.String()), err
+	}
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"to-int", "--hex", "2001:db8::1"}, "0x20010db8000000000000000000000001"},
+		{[]string{"to-int", "--base", "2", "::5"}, "101"},
+		{[]string{"from-int", "0x20010db8000000000000000000000001"}, "2001:db8::1"},
+		{[]string{"from-int", "0X00000000000000000000000000000001"}, "::1"},
+		{[]string{"from-int", "0b101"}, "::5"},
+		{[]string{"from-int", "010"}, "::a"}, // leading zero stays decimal
+		{[]string{"from-int", "--base", "16", "00ff"}, "::ff"},
+		{[]string{"from-int", "--base", "16", "0xff"}, "::ff"},
+		{[]string{"from-int", "--base", "36", "zz"}, "::50f"},
+	} {
+		got, err := run(tc.args...)
+		if err != nil || got != tc.want {
+			t.Fatalf("%v: got %q (%v) want %q", tc.args, got, err, tc.want)
+		}
+	}
+	for _, args := range [][]string{
+		{"from-int", "0x1" + strings.Repeat("0", 32)}, // 2^128
+		{"from-int", "0b102"},
+		{"from-int", "-1"},
+		{"from-int", "--base", "1", "1"},
+		{"to-int", "--base", "40", "::1"},
+		{"to-int", "--hex", "--base", "16", "::1"},
+	} {
+		if _, err := run(args...); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestHexCommands(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "to-hex", "2001:db8::1"})
+	if err := cmd.Execute(); err != nil || buf.String() != "20010db8000000000000000000000001\n" {
+		t.Fatalf("to-hex: %v %q", err, buf.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "from-hex", "0x2001:0db8:0000:0000:0000:0000:0000:0001"})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:db8::1\n" {
+		t.Fatalf("from-hex: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"from-hex", "20010db8"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "has 8 hex digits, want 32") {
+		t.Fatalf("expected length error, got %v", err)
+	}
+}
+
+func TestEmbeddedIPv4(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "expand", "2001:db8::192.0.2.1", "::ffff:0:192.0.2.1"})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:0db8:0000:0000:0000:0000:c000:0201\n0000:0000:0000:0000:ffff:0000:c000:0201\n" {
+		t.Fatalf("expand dotted: %v %q", err, buf.String())
+	}
+	for arg, want := range map[string]string{"2001:db8::192.0.2.1": "192.0.2.1", "2001:db8::c000:201": ""} {
+		buf.Reset()
+		cmd = NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", "json", "info", arg})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		var payload struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &payload); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := payload.Data["embedded_ipv4"].(string)
+		if got != want || payload.Data["expanded"] != "2001:0db8:0000:0000:0000:0000:c000:0201" {
+			t.Fatalf("%s: embedded_ipv4 %q want %q (%v)", arg, got, want, payload.Data)
+		}
+	}
+}
+
+func TestCommonPrefixCommand(t *testing.T) {
+	for _, tc := range []struct{ a, b, want string }{
+		{"2001:db8:1::1", "2001:db8:3::1", "prefix_length: 46\nprefix: /46\nnetwork: 2001:db8::/46\n"},
+		{"2001:db8::1", "2001:db8::1", "prefix_length: 128\nprefix: /128\nnetwork: 2001:db8::1/128\n"},
+		{"::", "8000::", "prefix_length: 0\nprefix: /0\nnetwork: ::/0\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", "human", "common-prefix", tc.a, tc.b})
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want {
+			t.Fatalf("%s %s: %v %q", tc.a, tc.b, err, buf.String())
+		}
+	}
+}
+
+func TestSupernetWholeSpace(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"::/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128"}, "::/0\n"},
+		{[]string{"2001:db8::/48"}, "2001:db8::/48\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human", "supernet"}, tc.args...))
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want {
+			t.Fatalf("supernet %v: %v %q", tc.args, err, buf.String())
+		}
+	}
+}
+
+func TestStepCommands(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"next", "2001:db8::ff"}, "2001:db8::100"},
+		{[]string{"prev", "2001:db8::100"}, "2001:db8::ff"},
+		{[]string{"next", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}, "::"},
+		{[]string{"prev", "::"}, "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{[]string{"add", "2001:db8::1", "255"}, "2001:db8::100"},
+		{[]string{"add", "2001:db8::", "0x10000000000000000"}, "2001:db8:0:1::"},
+		{[]string{"add", "::1", "340282366920938463463374607431768211455"}, "::"},
+		{[]string{"sub", "2001:db8::100", "0xff"}, "2001:db8::1"},
+		{[]string{"sub", "::", "0x100000000000000000000000000000000"}, "::"},
+		{[]string{"sub", "::1", "2"}, "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human"}, tc.args...))
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want+"\n" {
+			t.Fatalf("%v: %v %q want %q", tc.args, err, buf.String(), tc.want)
+		}
+	}
+	for _, args := range [][]string{{"add", "2001:db8::", "-1"}, {"sub", "2001:db8::", "zz"}, {"next", "bogus"}} {
+		cmd := NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestNoWrap(t *testing.T) {
+	for _, args := range [][]string{
+		{"next", "--no-wrap", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"prev", "--no-wrap", "::"},
+		{"add", "--no-wrap", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00", "0x100"},
+		{"sub", "--no-wrap", "::1", "2"},
+	} {
+		cmd := NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); !errors.Is(err, ipv6.ErrOverflow) || exitCode(err) != exitCodeInvalidInput {
+			t.Fatalf("%v: expected overflow, got %v", args, err)
+		}
+	}
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "add", "--no-wrap", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00", "0xff"})
+	if err := cmd.Execute(); err != nil || buf.String() != "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff\n" {
+		t.Fatalf("in-range --no-wrap: %v %q", err, buf.String())
+	}
+}
+
+func TestRangeWithMeta(t *testing.T) {
+	for _, r := range []string{"2001:db8::1-2001:db8::ff", "::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db8::5-2001:db8::5", "::1-8000::"} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", "json", "range", r, "--with-meta"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%s: %v", r, err)
+		}
+		var doc struct {
+			Data struct {
+				Start, End string
+				Count      *big.Int
+				CIDRs      []string
+			}
+		}
+		if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("%s: %v %s", r, err, buf.String())
+		}
+		start, end, _ := strings.Cut(r, "-")
+		if doc.Data.Start != start || doc.Data.End != end {
+			t.Fatalf("%s: endpoints %s-%s", r, doc.Data.Start, doc.Data.End)
+		}
+		sum := new(big.Int)
+		for _, s := range doc.Data.CIDRs {
+			c, err := ipv6.ParseCIDR(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum.Add(sum, c.HostCount())
+		}
+		if doc.Data.Count == nil || sum.Cmp(doc.Data.Count) != 0 {
+			t.Fatalf("%s: count %v, CIDRs hold %v", r, doc.Data.Count, sum)
+		}
+	}
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "range", "2001:db8::-2001:db8::1ff", "--with-meta"})
+	if err := cmd.Execute(); err != nil || buf.String() != "start: 2001:db8::\nend: 2001:db8::1ff\ncount: 512\ncidrs: 2001:db8::/119\n" {
+		t.Fatalf("human: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"range", "2001:db8::-2001:db8::1", "--with-meta", "--as", "addresses"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected --with-meta/--as addresses conflict")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allocations.txt")
+	if err := os.WriteFile(path, []byte("2001:db8::/64\n2001:db8:1::/48\n\n2001:db8:0:1::/64\n2001:db8::/128\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		args []string
+		in   string
+		want string
+	}{
+		{[]string{"-o", "human", "histogram", "--from", path}, "", "48: 1\n64: 2\n128: 1\n"},
+		{[]string{"-o", "json", "histogram", "--from", path}, "", `{"schema":"ip6calc/v1","data":{"48":1,"64":2,"128":1}}` + "\n"},
+		{[]string{"-o", "human", "histogram", "2001:db8::/64", "2001:db8::/32"}, "", "32: 1\n64: 1\n"},
+		{[]string{"-o", "human", "histogram"}, "", ""},
+		{[]string{"-o", "json", "histogram"}, "", `{"schema":"ip6calc/v1","data":{}}` + "\n"},
+		{[]string{"-o", "human", "histogram"}, "2001:db8::/56\n", "56: 1\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetIn(strings.NewReader(tc.in))
+		cmd.SetArgs(tc.args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		got := buf.String()
+		if tc.args[1] == "json" {
+			compact := &bytes.Buffer{}
+			if err := json.Compact(compact, buf.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			got = compact.String() + "\n"
+		}
+		if got != tc.want {
+			t.Fatalf("%v: %q want %q", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestGroupCommand(t *testing.T) {
+	in := "2001:db8:2::1\n2001:db8:1::9\n2001:db8:2::\n2001:db8:1::1\n"
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetIn(strings.NewReader(in))
+	cmd.SetArgs([]string{"-o", "human", "group", "--prefix", "48"})
+	want := "2001:db8:1::/48\n  2001:db8:1::1\n  2001:db8:1::9\n2001:db8:2::/48\n  2001:db8:2::\n  2001:db8:2::1\n"
+	if err := cmd.Execute(); err != nil || buf.String() != want {
+		t.Fatalf("group: %v %q want %q", err, buf.String(), want)
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "group", "--prefix", "32", "2001:db8::2", "2001:db8::1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct{ Data []GroupResult }
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil || len(doc.Data) != 1 || doc.Data[0].Network != "2001:db8::/32" || strings.Join(doc.Data[0].Members, ",") != "2001:db8::1,2001:db8::2" {
+		t.Fatalf("json: %v %+v", err, doc)
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "--print0", "group", "--prefix", "48", "2001:db8:1::1"})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:db8:1::/48\x00  2001:db8:1::1\x00" {
+		t.Fatalf("group --print0: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"group", "--prefix", "129", "2001:db8::1"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error for --prefix 129")
+	}
+}
+
+func TestRawOutput(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-o", "json", "--raw", "supernet", "2001:db8::/65", "2001:db8:0:0:8000::/65"}, "\"2001:db8::/64\"\n"},
+		{[]string{"-o", "json", "--raw", "to-int", "::ff"}, "\"255\"\n"},
+		{[]string{"-o", "json", "--raw", "expand", "::1", "::2"}, "[\n  \"0000:0000:0000:0000:0000:0000:0000:0001\",\n  \"0000:0000:0000:0000:0000:0000:0000:0002\"\n]\n"},
+		{[]string{"-o", "yaml", "--raw", "reverse", "2001:db8::1"}, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.\n"},
+		{[]string{"-o", "json", "--raw", "mask", "::/64"}, "{\n  \"prefix_length\": 64,\n  \"netmask\": \"ffff:ffff:ffff:ffff::\",\n  \"hostmask\": \"::ffff:ffff:ffff:ffff\"\n}\n"},
+		{[]string{"-o", "human", "--raw", "to-int", "::ff"}, "255\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(tc.args)
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want {
+			t.Fatalf("%v: %v %q want %q", tc.args, err, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestSchemaToggle(t *testing.T) {
+	mask := "{\n  \"prefix_length\": 64,\n  \"netmask\": \"ffff:ffff:ffff:ffff::\",\n  \"hostmask\": \"::ffff:ffff:ffff:ffff\"\n}\n"
+	wrapped := "{\n  \"schema\": \"ip6calc/v1\",\n  \"data\": " + strings.ReplaceAll(strings.TrimSuffix(mask, "\n"), "\n", "\n  ") + "\n}\n"
+	for _, tc := range []struct {
+		env  string
+		args []string
+		want string
+	}{
+		{"", []string{"-o", "json", "mask", "::/64"}, wrapped},
+		{"", []string{"-o", "json", "--schema=false", "mask", "::/64"}, mask},
+		{"false", []string{"-o", "json", "mask", "::/64"}, mask},
+		{"false", []string{"-o", "json", "--schema=true", "mask", "::/64"}, wrapped},
+		{"bogus", []string{"-o", "json", "mask", "::/64"}, wrapped},
+		{"0", []string{"-o", "yaml", "supernet", "2001:db8::/65", "2001:db8:0:0:8000::/65"}, "2001:db8::/64\n"},
+	} {
+		t.Setenv("IP6CALC_SCHEMA", tc.env)
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(tc.args)
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want {
+			t.Fatalf("IP6CALC_SCHEMA=%q %v: %v %q want %q", tc.env, tc.args, err, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestCompactJSON(t *testing.T) {
+	run := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return buf.String()
+	}
+	got := run("-o", "json", "--compact", "split", "2001:db8::/64", "--new-prefix", "66")
+	want := `{"schema":"ip6calc/v1","data":["2001:db8::/66","2001:db8:0:0:4000::/66","2001:db8:0:0:8000::/66","2001:db8:0:0:c000::/66"]}` + "\n"
+	if got != want {
+		t.Fatalf("compact split = %q want %q", got, want)
+	}
+	var doc struct{ Data []string }
+	if err := json.Unmarshal([]byte(got), &doc); err != nil || len(doc.Data) != 4 {
+		t.Fatalf("compact output does not parse: %v %v", err, doc)
+	}
+	if got := run("-o", "json", "--compact", "--raw", "enumerate", "2001:db8::/126"); got != `["2001:db8::","2001:db8::1","2001:db8::2","2001:db8::3"]`+"\n" {
+		t.Fatalf("compact raw enumerate = %q", got)
+	}
+	if a, b := run("-o", "yaml", "--compact", "mask", "::/64"), run("-o", "yaml", "mask", "::/64"); a != b {
+		t.Fatalf("--compact changed yaml output:\n%s\n%s", a, b)
+	}
+}
+
+// limitWriter accepts n bytes and then fails every write.
+type limitWriter struct{ n int }
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		k := w.n
+		w.n = 0
+		return k, errors.New("disk full")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestSplitJSONStream(t *testing.T) {
+	t.Setenv("IP6CALC_SPLIT_FORCE_THRESHOLD", "")
+	type envelope struct {
+		Schema string   `json:"schema"`
+		Data   []string `json:"data"`
+	}
+	for _, tc := range []struct {
+		flags []string
+		raw   bool
+		enc   func(v any) ([]byte, error)
+	}{
+		{nil, false, func(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }},
+		{[]string{"--compact"}, false, json.Marshal},
+		{[]string{"--raw"}, true, func(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }},
+		{[]string{"--raw", "--compact"}, true, json.Marshal},
+	} {
+		for _, np := range []int{49, 60} {
+			buf := &bytes.Buffer{}
+			cmd := NewRootCmd(buf)
+			cmd.SetArgs(append([]string{"-o", "json", "split", "2001:db8::/48", "--new-prefix", strconv.Itoa(np)}, tc.flags...))
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			var list []string
+			var want any = &envelope{SchemaVersion, nil}
+			if tc.raw {
+				want = &list
+			}
+			if err := json.Unmarshal(buf.Bytes(), want); err != nil {
+				t.Fatalf("%v /%d: not valid JSON: %v", tc.flags, np, err)
+			}
+			if e, ok := want.(*envelope); ok {
+				list = e.Data
+			}
+			if len(list) != 1<<(np-48) || list[0] != "2001:db8::/"+strconv.Itoa(np) {
+				t.Fatalf("%v /%d: got %d subnets starting %q", tc.flags, np, len(list), list[0])
+			}
+			b, _ := tc.enc(want)
+			if buf.String() != string(b)+"\n" {
+				t.Fatalf("%v /%d: streamed output differs from encoder output:\n%s\n%s", tc.flags, np, buf.String(), b)
+			}
+		}
+	}
+	cmd := NewRootCmd(&limitWriter{n: 5000})
+	cmd.SetArgs([]string{"-o", "json", "split", "2001:db8::/48", "--new-prefix", "60"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected mid-stream write error, got %v", err)
+	}
+}
+
+func TestPrivacyCommand(t *testing.T) {
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human", "privacy"}, args...))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+	a, err := run("2001:db8:1:2::/64", "--secret", "k1", "--netiface", "eth0")
+	if err != nil || !strings.HasPrefix(a, "2001:db8:1:2:") {
+		t.Fatalf("privacy: %v %q", err, a)
+	}
+	if b, _ := run("2001:db8:1:2::/64", "--secret", "k1", "--netiface", "eth0"); a != b {
+		t.Fatalf("not deterministic: %q %q", a, b)
+	}
+	if b, _ := run("2001:db8:1:2::/64", "--secret", "k2", "--netiface", "eth0"); a == b {
+		t.Fatalf("secret ignored: %q", b)
+	}
+	if b, _ := run("2001:db8:1:2::/64", "--secret", "k1", "--netiface", "eth0", "--counter", "1"); a == b {
+		t.Fatalf("counter ignored: %q", b)
+	}
+	if _, err := run("2001:db8:1:2::/64", "--netiface", "eth0"); err == nil {
+		t.Fatal("expected error without --secret")
+	}
+}
+
+func TestSpecialCommand(t *testing.T) {
+	for _, tc := range []struct{ addr, want string }{
+		{"::1", "address: ::1\nspecial: true\nnetwork: ::1/128\nname: Loopback Address\nrfc: RFC 4291\n"},
+		{"64:ff9b::192.0.2.1", "address: 64:ff9b::c000:201\nspecial: true\nnetwork: 64:ff9b::/96\nname: IPv4-IPv6 Translation\nrfc: RFC 6052\n"},
+		{"2606:4700::1", "address: 2606:4700::1\nspecial: false\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", "human", "special", tc.addr})
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want {
+			t.Fatalf("%s: %v %q want %q", tc.addr, err, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestCoversCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.txt")
+	if err := os.WriteFile(path, []byte("::/0\n2001:db8::/32\n2001:db8:1:2::/64\n2001:db8:2::/48\n2001:db8:1::/48\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ target, want string }{
+		{"2001:db8:1:2::/64", "2001:db8:1:2::/64\n2001:db8:1::/48\n2001:db8::/32\n::/0\n"},
+		{"2001:db8:3::/48", "2001:db8::/32\n::/0\n"},
+		{"::/0", "::/0\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs([]string{"-o", "human", "covers", tc.target, "--routes", path})
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want {
+			t.Fatalf("%s: %v %q want %q", tc.target, err, buf.String(), tc.want)
+		}
+	}
+	buf, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetErr(errOut)
+	cmd.SetIn(strings.NewReader("2001:db8::/32\nbogus\n"))
+	cmd.SetArgs([]string{"-o", "human", "--continue-on-error", "covers", "2001:db8::/64"})
+	if err := cmd.Execute(); exitCode(err) != exitCodeInvalidInput || buf.String() != "2001:db8::/32\n" || !strings.Contains(errOut.String(), "line 2 (bogus)") {
+		t.Fatalf("stdin with a bad line: %v %q %q", err, buf.String(), errOut.String())
+	}
+}
+
+func TestSubnetsOfCommand(t *testing.T) {
+	in := "2001:db8:1:2::/64\n::/0\n2001:db8:1::/48\n2001:db8:2::/48\n2001:db8:1::/64\n"
+	for _, tc := range []struct{ parent, want string }{
+		{"2001:db8:1::/48", "2001:db8:1::/48\n2001:db8:1::/64\n2001:db8:1:2::/64\n"},
+		{"2001:db8::/32", "2001:db8:1::/48\n2001:db8:1::/64\n2001:db8:1:2::/64\n2001:db8:2::/48\n"},
+		{"2001:db9::/32", ""},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetIn(strings.NewReader(in))
+		cmd.SetArgs([]string{"-o", "human", "subnets-of", tc.parent})
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want {
+			t.Fatalf("%s: %v %q want %q", tc.parent, err, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestVerifyCover(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		code     int
+	}{
+		{"2001:db8:0:2::/63\n2001:db8::/63\n", "ok\n", 0},
+		{"2001:db8::/63\n2001:db8:0:3::/64\n", "", 1},
+		{"2001:db8::/63\n2001:db8::/64\n2001:db8:0:2::/63\n", "", 1},
+		{"2001:db8::/61\n", "", 1},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetIn(strings.NewReader(tc.in))
+		cmd.SetArgs([]string{"-o", "human", "verify-cover", "2001:db8::/62"})
+		err := cmd.Execute()
+		if buf.String() != tc.want || (err == nil) != (tc.code == 0) || (err != nil && (exitCode(err) != tc.code || !errors.Is(err, ErrNotExactCover))) {
+			t.Fatalf("%q: %v %q", tc.in, err, buf.String())
+		}
+	}
+	cmd := NewRootCmd(&bytes.Buffer{})
+	cmd.SetIn(strings.NewReader("2001:db8::/63\n2001:db8:0:3::/64\n"))
+	cmd.SetArgs([]string{"-o", "human", "verify-cover", "2001:db8::/62"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "2001:db8:0:2::/64 is not covered") {
+		t.Fatalf("gap not reported: %v", err)
+	}
+}
+
+func TestRandomAddressExclude(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reserved.txt")
+	if err := os.WriteFile(path, []byte("2001:db8::/121\n2001:db8::80/122\n2001:db8::c0/123\n2001:db8::e0/124\n2001:db8::f0/125\n2001:db8::f8/126\n2001:db8::fc/127\n2001:db8::fe/128\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "random", "address", "2001:db8::/120", "--exclude", path, "--count", "5"})
+	if err := cmd.Execute(); err != nil || buf.String() != strings.Repeat("2001:db8::ff\n", 5) {
+		t.Fatalf("--exclude: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"random", "address", "2001:db8::/121", "--exclude", path})
+	if err := cmd.Execute(); !errors.Is(err, ipv6.ErrNoSpace) {
+		t.Fatalf("fully reserved: %v", err)
+	}
+}
+
+func TestRandomSubnetDistribution(t *testing.T) {
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human", "random", "subnet", "2001:db8::/56", "--new-prefix", "64", "--count", "20"}, args...))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+	uniform, err := run("--seed", "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(7))
+	c, _ := ipv6.ParseCIDR("2001:db8::/56")
+	var want strings.Builder
+	for i := 0; i < 20; i++ {
+		s, _ := ipv6.RandomSubnetInCIDR(c, 64, r)
+		want.WriteString(s.String() + "\n")
+	}
+	if uniform != want.String() {
+		t.Fatalf("uniform output differs from RandomSubnetInCIDR:\n%s\nwant\n%s", uniform, want.String())
+	}
+	if again, _ := run("--seed", "7", "--distribution", "uniform"); again != uniform {
+		t.Fatal("explicit uniform differs from default")
+	}
+	front, err := run("--seed", "7", "--distribution", "front-loaded")
+	if err != nil || front == uniform || strings.Count(front, "\n") != 20 {
+		t.Fatalf("front-loaded: %v %q", err, front)
+	}
+	if _, err := run("--distribution", "normal"); err == nil {
+		t.Fatal("unknown distribution accepted")
+	}
+}
+
+func TestAtCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "at", "2001:db8::/64", "--percent", "50"})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:db8:0:0:8000::\n" {
+		t.Fatalf("at --percent 50: %v %q", err, buf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"at", "2001:db8::/64", "--percent", "101"})
+	if err := cmd.Execute(); exitCode(err) != exitCodeInvalidInput {
+		t.Fatalf("out-of-range percent: %v", err)
+	}
+}
+
+func TestCountCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "--raw", "--compact", "count", "2001:db8::/48", "--prefix", "64"})
+	if err := cmd.Execute(); err != nil || buf.String() != `{"prefix_length":64,"count":"65536","count_power":"2^16","count_approx":"6.55e4"}`+"\n" {
+		t.Fatalf("count: %v %q", err, buf.String())
+	}
+	for _, p := range []string{"32", "129"} {
+		cmd = NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs([]string{"count", "2001:db8::/48", "--prefix", p})
+		if err := cmd.Execute(); exitCode(err) != exitCodeInvalidInput {
+			t.Fatalf("--prefix %s: %v", p, err)
+		}
+	}
+}
+
+func TestTreeCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "tree", "2001:db8::/48", "--depth", "2"})
+	want := "2001:db8::/48\n  2001:db8::/49\n    2001:db8::/50\n    2001:db8:0:4000::/50\n  2001:db8:0:8000::/49\n    2001:db8:0:8000::/50\n    2001:db8:0:c000::/50\n"
+	if err := cmd.Execute(); err != nil || buf.String() != want {
+		t.Fatalf("tree human: %v %q", err, buf.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "--raw", "--compact", "tree", "2001:db8::/47", "--depth", "2"})
+	want = `{"network":"2001:db8::/47","children":[{"network":"2001:db8::/48","children":[{"network":"2001:db8::/49"},{"network":"2001:db8:0:8000::/49"}]},{"network":"2001:db8:1::/48","children":[{"network":"2001:db8:1::/49"},{"network":"2001:db8:1:8000::/49"}]}]}` + "\n"
+	if err := cmd.Execute(); err != nil || buf.String() != want {
+		t.Fatalf("tree json: %v %q", err, buf.String())
+	}
+	for _, depth := range []string{"11", "81"} {
+		cmd = NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs([]string{"tree", "2001:db8::/48", "--depth", depth})
+		if err := cmd.Execute(); err == nil {
+			t.Fatalf("--depth %s accepted", depth)
+		}
+	}
+}
+
+func TestAnonymizeCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "anonymize", "--keep-prefix", "48", "2001:db8:1:2::1", "2001:db8:5::ff"})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:db8:1::\n2001:db8:5::\n" {
+		t.Fatalf("anonymize zero: %v %q", err, buf.String())
+	}
+	log := "  GET / from 2001:db8:1:2::1 port 443\nno address here 12:30:45\n[2001:db8:1:2::1]:8080 and ::1.\n"
+	h, _ := ipv6.Parse("2001:db8:1:2::1")
+	l, _ := ipv6.Parse("::1")
+	hashed, _ := ipv6.AnonymizeAddress(h, 48, "hash", []byte("k"))
+	loop, _ := ipv6.AnonymizeAddress(l, 48, "hash", []byte("k"))
+	want := "  GET / from " + hashed.String() + " port 443\nno address here 12:30:45\n[" + hashed.String() + "]:8080 and " + loop.String() + ".\n"
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetIn(strings.NewReader(log))
+	cmd.SetArgs([]string{"-o", "human", "anonymize", "--mode", "hash", "--key", "k"})
+	if err := cmd.Execute(); err != nil || buf.String() != want {
+		t.Fatalf("anonymize stdin:\n%q\nwant\n%q (%v)", buf.String(), want, err)
+	}
+	for _, args := range [][]string{
+		{"anonymize", "--mode", "hash", "2001:db8::1"},
+		{"anonymize", "--mode", "scramble", "2001:db8::1"},
+		{"anonymize", "--keep-prefix", "129", "2001:db8::1"},
+		{"anonymize", "not-an-address"},
+	} {
+		cmd = NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestRewriteCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetIn(strings.NewReader("2001:db8:1::5\n2001:db9::1\n2001:db8:ab::/48\n2001:db9::/48\n"))
+	cmd.SetArgs([]string{"-o", "human", "rewrite", "--from", "2001:db8::/32", "--to", "2001:470::/32"})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:470:1::5\n2001:db9::1\n2001:470:ab::/48\n2001:db9::/48\n" {
+		t.Fatalf("rewrite: %v %q", err, buf.String())
+	}
+	for _, args := range [][]string{
+		{"rewrite", "--from", "2001:db8::/32", "--to", "2001:470::/24", "2001:db8::1"},
+		{"rewrite", "--from", "2001:db8::/32", "--to", "2001:470::/32", "2001:d00::/24"},
+		{"rewrite", "--from", "2001:db8::/32", "--to", "2001:470:ab00::/40", "2001:db8::/36"},
+	} {
+		cmd = NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); exitCode(err) != exitCodeInvalidInput {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+}
+
+func TestRenumberCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subnets.txt")
+	if err := os.WriteFile(path, []byte("2001:db8:aa:1::/64\n\n2001:db8:aa:ff00::/56\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "renumber", "--from", "2001:db8:aa::/48", "--to", "2001:db8:bb::/48", "--list", path})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:db8:bb:1::/64\n2001:db8:bb:ff00::/56\n" {
+		t.Fatalf("renumber: %v %q", err, buf.String())
+	}
+	for _, args := range [][]string{
+		{"renumber", "--from", "2001:db8:aa::/48", "--to", "2001:db8:bb::/56", "--list", path},
+		{"renumber", "--from", "2001:db8:cc::/48", "--to", "2001:db8:bb::/48", "--list", path},
+	} {
+		cmd = NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); exitCode(err) != exitCodeInvalidInput {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+}
+
+func TestColorMode(t *testing.T) {
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append(append([]string{"-o", "human"}, args...), "tree", "2001:db8::/48", "--depth", "0"))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+	const colored = "\x1b[36m2001:db8::/48\x1b[0m\n"
+	t.Setenv("NO_COLOR", "")
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "2001:db8::/48\n"}, // auto: a buffer is not a terminal
+		{[]string{"--color=auto"}, "2001:db8::/48\n"},
+		{[]string{"--color"}, colored},
+		{[]string{"--color=always"}, colored},
+		{[]string{"--color=true"}, colored},
+		{[]string{"--color=never"}, "2001:db8::/48\n"},
+		{[]string{"--color=false"}, "2001:db8::/48\n"},
+	} {
+		if out, err := run(tc.args...); err != nil || out != tc.want {
+			t.Fatalf("%v: %v %q, want %q", tc.args, err, out, tc.want)
+		}
+	}
+	if _, err := run("--color=sometimes"); err == nil {
+		t.Fatal("invalid color mode accepted")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if out, err := run("--color=always"); err != nil || out != "2001:db8::/48\n" {
+		t.Fatalf("NO_COLOR should win over --color=always: %v %q", err, out)
+	}
+	if useColor(colorAuto, os.Stdout) {
+		t.Fatal("auto colored despite NO_COLOR")
+	}
+}
+
+func TestTypeColors(t *testing.T) {
+	run := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return buf.String()
+	}
+	t.Setenv("NO_COLOR", "")
+	if out := run("-o", "human", "--color", "info", "fe80::1"); !strings.Contains(out, "address: \x1b[33mfe80::1\x1b[0m\n") {
+		t.Fatalf("link-local not yellow: %q", out)
+	}
+	if out := run("-o", "human", "--color", "info", "2600::/16"); !strings.Contains(out, "network: \x1b[32m2600::\x1b[0m\n") {
+		t.Fatalf("global unicast network not green: %q", out)
+	}
+	if out := run("-o", "human", "--color", "info", "::/0"); !strings.Contains(out, "network: ::\n") {
+		t.Fatalf("mixed network colored: %q", out)
+	}
+	if out := run("-o", "human", "--color", "classify-range", "fc00::/6"); !strings.Contains(out, "\x1b[34munique-local\x1b[0m") || !strings.Contains(out, ", unclassified\n") {
+		t.Fatalf("classify-range colors: %q", out)
+	}
+	for _, args := range [][]string{
+		{"-o", "json", "--color", "info", "fe80::1"},
+		{"-o", "human", "info", "fe80::1"},
+	} {
+		if out := run(args...); strings.Contains(out, "\x1b[") {
+			t.Fatalf("%v colored: %q", args, out)
+		}
+	}
+	t.Setenv("NO_COLOR", "1")
+	if out := run("-o", "human", "--color", "info", "fe80::1"); strings.Contains(out, "\x1b[") {
+		t.Fatalf("NO_COLOR ignored: %q", out)
+	}
+}
+
+func TestTableWidth(t *testing.T) {
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human", "--table"}, args...))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+	out, err := run("--width", "20", "expand", "::1")
+	want := fmt.Sprintf("%4s  %-20s\n%4d  %-20s\n", "Idx", "Value", 1, "0000:0000:0000:0000:0000:0000:0000:0001")
+	if err != nil || out != want {
+		t.Fatalf("list --width 20 (longer value):\n%q\nwant\n%q", out, want)
+	}
+	out, err = run("--width", "30", "compress", "::1", "2001:db8::1")
+	want = fmt.Sprintf("%4s  %-30s\n%4d  %-30s\n%4d  %-30s\n", "Idx", "Value", 1, "::1", 2, "2001:db8::1")
+	if err != nil || out != want {
+		t.Fatalf("list --width 30:\n%q\nwant\n%q", out, want)
+	}
+	out, err = run("--width", "16", "mask", "::/64")
+	if err != nil || !strings.HasPrefix(out, "Field          Value           \nprefix_length  64              \n") {
+		t.Fatalf("fields --width 16: %v %q", err, out)
+	}
+	if _, err := run("--width", "-1", "mask", "::/64"); err == nil {
+		t.Fatal("negative --width accepted")
+	}
+}
+
+func TestIndexedLists(t *testing.T) {
+	run := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return buf.String()
+	}
+	if out := run("-o", "json", "--raw", "--compact", "--indexed", "compress", "0::1", "2001:db8:0::1"); out != `[{"index":1,"value":"::1"},{"index":2,"value":"2001:db8::1"}]`+"\n" {
+		t.Fatalf("indexed json: %q", out)
+	}
+	if out := run("-o", "yaml", "--raw", "--indexed", "compress", "0::1"); out != "- index: 1\n  value: ::1\n" {
+		t.Fatalf("indexed yaml: %q", out)
+	}
+	if out := run("-o", "json", "--raw", "--compact", "compress", "0::1"); out != `["::1"]`+"\n" {
+		t.Fatalf("bare list changed: %q", out)
+	}
+	if out := run("-o", "json", "--raw", "--compact", "--indexed", "info", "2001:db8::1"); strings.Contains(out, "index") {
+		t.Fatalf("non-list output indexed: %q", out)
+	}
+	// the streamed split list is byte-for-byte what the encoder writes
+	var rows []IndexedValue
+	for i := 0; i < 4; i++ {
+		rows = append(rows, IndexedValue{i + 1, fmt.Sprintf("2001:db8:0:%x::/64", i)})
+	}
+	rows[0].Value = "2001:db8::/64"
+	indent := func(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }
+	for _, tc := range []struct {
+		flags []string
+		v     any
+		enc   func(v any) ([]byte, error)
+	}{
+		{nil, fields{{"schema", SchemaVersion}, {"data", rows}}, indent},
+		{[]string{"--compact"}, fields{{"schema", SchemaVersion}, {"data", rows}}, json.Marshal},
+		{[]string{"--raw"}, rows, indent},
+		{[]string{"--raw", "--compact"}, rows, json.Marshal},
+	} {
+		want, _ := tc.enc(tc.v)
+		if got := run(append(append([]string{"-o", "json", "--indexed"}, tc.flags...), "split", "2001:db8::/62", "--new-prefix", "64")...); got != string(want)+"\n" {
+			t.Fatalf("%v: streamed\n%s\nwant\n%s", tc.flags, got, want)
+		}
+	}
+}
+
+// TestMain keeps a developer's own config file out of the tests.
+func TestMain(m *testing.M) {
+	if err := os.Setenv("IP6CALC_CONFIG", os.DevNull); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("output: json\ncompact: true\nschema: false\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("IP6CALC_CONFIG", path)
+	t.Setenv("IP6CALC_FORMAT", "")
+	t.Setenv("IP6CALC_SCHEMA", "")
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+	// config beats built-in defaults
+	if out, err := run("compress", "0::1"); err != nil || out != `["::1"]`+"\n" {
+		t.Fatalf("config defaults: %v %q", err, out)
+	}
+	// explicit flags beat config
+	if out, err := run("-o", "human", "compress", "0::1"); err != nil || out != "::1\n" {
+		t.Fatalf("flag over config: %v %q", err, out)
+	}
+	if out, err := run("--schema=true", "compress", "0::1"); err != nil || out != `{"schema":"ip6calc/v1","data":["::1"]}`+"\n" {
+		t.Fatalf("--schema over config: %v %q", err, out)
+	}
+	// environment variables beat config
+	t.Setenv("IP6CALC_FORMAT", "yaml")
+	if out, err := run("compress", "0::1"); err != nil || out != "- ::1\n" {
+		t.Fatalf("env over config: %v %q", err, out)
+	}
+	t.Setenv("IP6CALC_FORMAT", "")
+	for _, bad := range []string{"colour: always\n", "output: xml\n", "output: [json\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := run("compress", "::1"); err == nil || !strings.Contains(err.Error(), "config") {
+			t.Fatalf("%q: expected config error, got %v", bad, err)
+		}
+	}
+	t.Setenv("IP6CALC_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := run("compress", "::1"); err == nil {
+		t.Fatal("missing IP6CALC_CONFIG file accepted")
+	}
+}
+
+func TestCompletionFile(t *testing.T) {
+	complete := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"__complete"}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return buf.String()
+	}
+	path := filepath.Join(t.TempDir(), "prefixes.txt")
+	if err := os.WriteFile(path, []byte("2001:db8::/32\n2001:db8:1::/48\n\nfd00::/8\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("IP6CALC_COMPLETION_FILE", path)
+	if out := complete("info", "2001:"); out != "2001:db8::/32\n2001:db8:1::/48\n:4\n" {
+		t.Fatalf("info completion: %q", out)
+	}
+	if out := complete("summarize", "2001:db8::/32", "fd"); !strings.HasPrefix(out, "fd00::/8\n:4\n") {
+		t.Fatalf("summarize completion: %q", out)
+	}
+	for _, name := range []string{"next", "prev", "add", "sub", "common-prefix", "bits", "to-int", "to-hex", "hash", "multicast", "solicited-node", "explain"} {
+		if out := complete(name, "fd"); !strings.HasPrefix(out, "fd00::/8\n:4\n") {
+			t.Fatalf("%s completion: %q", name, out)
+		}
+	}
+	t.Setenv("IP6CALC_COMPLETION_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	if out := complete("split", "2001:"); !strings.HasPrefix(out, ":4\n") {
+		t.Fatalf("missing file completion: %q", out)
+	}
+	t.Setenv("IP6CALC_COMPLETION_FILE", "")
+	if out := complete("info", ""); !strings.HasPrefix(out, ":4\n") {
+		t.Fatalf("unset completion: %q", out)
+	}
+}
+
+func TestReverseParse(t *testing.T) {
+	name := "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "reverse", "--parse", name})
+	if err := cmd.Execute(); err != nil || buf.String() != "2001:db8::1\n" {
+		t.Fatalf("reverse --parse: %v %q", err, buf.String())
+	}
+	for _, bad := range []string{name[2:], strings.TrimSuffix(name, "ip6.arpa.") + "ip4.arpa."} {
+		cmd = NewRootCmd(&bytes.Buffer{})
+		cmd.SetArgs([]string{"reverse", "--parse", bad})
+		if err := cmd.Execute(); exitCode(err) != exitCodeInvalidInput {
+			t.Fatalf("reverse --parse %q: %v", bad, err)
+		}
+	}
+}
+
+func TestReverseOrigin(t *testing.T) {
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human", "reverse"}, args...))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+	full := "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"2001:db8::1"}, full + ".\n"},
+		{[]string{"--trailing-dot=false", "2001:db8::1"}, full + "\n"},
+		{[]string{"--zone", "2001:db8::1"}, full + "\n"},
+		{[]string{"--origin", "2001:db8::/48", "2001:db8::1"}, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0\n"},
+	} {
+		if out, err := run(tc.args...); err != nil || out != tc.want {
+			t.Fatalf("%v: %v %q, want %q", tc.args, err, out, tc.want)
+		}
+	}
+	for _, args := range [][]string{
+		{"--origin", "2001:db9::/48", "2001:db8::1"},
+		{"--origin", "2001:db8::/47", "2001:db8::1"},
+		{"--origin", "2001:db8::/48", "--zone", "2001:db8::1"},
+	} {
+		if _, err := run(args...); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestReverseCIDR(t *testing.T) {
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(append([]string{"reverse"}, args...))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+	out, err := run("-o", "human", "2001:db8::/126", "--origin", "2001:db8::/124")
+	if err != nil || out != "0\n1\n2\n3\n" {
+		t.Fatalf("reverse /126: %v %q", err, out)
+	}
+	out, err = run("-o", "json", "--raw", "2001:db8::/127", "--trailing-dot=false")
+	want := `["0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"]`
+	var got, exp []string
+	_ = json.Unmarshal([]byte(want), &exp)
+	if err != nil || json.Unmarshal([]byte(out), &got) != nil || strings.Join(got, " ") != strings.Join(exp, " ") {
+		t.Fatalf("reverse /127 json: %v %q", err, out)
+	}
+	t.Setenv("IP6CALC_SPLIT_FORCE_THRESHOLD", "8")
+	if _, err := run("-o", "human", "2001:db8::/124"); !errors.Is(err, ErrSplitTooLarge) {
+		t.Fatalf("expected ErrSplitTooLarge, got %v", err)
+	}
+	if out, err := run("-o", "human", "2001:db8::/124", "--force"); err != nil || strings.Count(out, "\n") != 16 {
+		t.Fatalf("reverse --force: %v %q", err, out)
+	}
+	if _, err := run("-o", "human", "2001:db8::/64", "--force"); !errors.Is(err, ipv6.ErrSplitExcessive) {
+		t.Fatalf("expected ErrSplitExcessive, got %v", err)
+	}
+	if _, err := run("-o", "human", "2001:db8::/126", "--origin", "2001:db9::/32"); !errors.Is(err, ipv6.ErrNotContained) {
+		t.Fatalf("expected ErrNotContained, got %v", err)
+	}
+	out, err = run("-o", "human", "2001:db8::/126", "--origin", "2001:db8::/126")
+	if !errors.Is(err, ipv6.ErrInvalidPrefix) || exitCode(err) != exitCodeInvalidInput || strings.HasPrefix(out, "\n") {
+		t.Fatalf("non-nibble origin: %v %q", err, out)
+	}
+}
+
+func TestAddWithin(t *testing.T) {
+	run := func(args ...string) (string, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human"}, args...))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"add", "2001:db8::3", "2", "--within", "2001:db8::/126"}, "2001:db8::1\n"},
+		{[]string{"sub", "2001:db8::1", "2", "--within", "2001:db8::/126"}, "2001:db8::3\n"},
+		{[]string{"add", "2001:db8::1", "0x100", "--within", "2001:db8::/120"}, "2001:db8::1\n"},
+	} {
+		if out, err := run(tc.args...); err != nil || out != tc.want {
+			t.Fatalf("%v: %v %q, want %q", tc.args, err, out, tc.want)
+		}
+	}
+	if _, err := run("add", "2001:db8::4", "1", "--within", "2001:db8::/126"); !errors.Is(err, ipv6.ErrNotContained) {
+		t.Fatalf("expected ErrNotContained, got %v", err)
+	}
+	if _, err := run("add", "2001:db8::1", "1", "--within", "2001:db8::/126", "--no-wrap"); err == nil {
+		t.Fatal("expected --within and --no-wrap to conflict")
+	}
+}
+
+func TestRangeMaxCIDRs(t *testing.T) {
+	buf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetErr(errBuf)
+	cmd.SetArgs([]string{"-o", "json", "--raw", "range", "::1-::ff", "--max-cidrs", "1", "--with-meta"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var res RangeResult
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if len(res.CIDRs) != 1 || res.CIDRs[0] != "::/120" || res.OverCoverage == nil || res.OverCoverage.Int64() != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if !strings.Contains(errBuf.String(), "1 addresses outside the range") {
+		t.Fatalf("missing over-coverage warning: %q", errBuf.String())
+	}
+	// a cover already within the limit is exact and warns about nothing
+	buf.Reset()
+	errBuf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetErr(errBuf)
+	cmd.SetArgs([]string{"-o", "human", "range", "::1-::ff", "--max-cidrs", "8"})
+	if err := cmd.Execute(); err != nil || strings.Count(buf.String(), "\n") != 8 || errBuf.Len() != 0 {
+		t.Fatalf("exact cover: %v %q %q", err, buf.String(), errBuf.String())
+	}
+	cmd = NewRootCmd(&bytes.Buffer{})
+	cmd.SetArgs([]string{"-o", "human", "range", "::1-::ff", "--max-cidrs", "0"})
+	if err := cmd.Execute(); !errors.Is(err, ipv6.ErrInvalidLimit) || exitCode(err) != exitCodeInvalidInput {
+		t.Fatalf("--max-cidrs 0: %v", err)
+	}
+}
+
+func TestExpandSep(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"expand", "2001:db8::1"}, "2001:0db8:0000:0000:0000:0000:0000:0001\n"},
+		{[]string{"expand", "--sep", "-", "2001:db8::1"}, "2001-0db8-0000-0000-0000-0000-0000-0001\n"},
+		{[]string{"expand", "--sep=", "2001:db8::1"}, "20010db8000000000000000000000001\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "human"}, tc.args...))
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want {
+			t.Fatalf("%v: %v %q, want %q", tc.args, err, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestInfoKeepHost(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "--raw", "info", "2001:db8::1/64", "--keep-host"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var res InfoResult
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if res.Host != "2001:db8::1/64" || res.Network != "2001:db8::" || res.FirstHost != "2001:db8::" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "json", "--raw", "info", "2001:db8::1/64"})
+	if err := cmd.Execute(); err != nil || strings.Contains(buf.String(), `"host"`) {
+		t.Fatalf("host shown without --keep-host: %v %s", err, buf.String())
+	}
+}
+
+func TestAnonymizeV4Mapped(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetIn(strings.NewReader("client ::ffff:192.0.2.1 GET /\n"))
+	cmd.SetArgs([]string{"-o", "human", "anonymize", "--keep-prefix", "48"})
+	if err := cmd.Execute(); err != nil || buf.String() != "client :: GET /\n" {
+		t.Fatalf("anonymize mapped line: %v %q", err, buf.String())
+	}
+	buf.Reset()
+	cmd = NewRootCmd(buf)
+	cmd.SetArgs([]string{"-o", "human", "anonymize", "--keep-prefix", "120", "::ffff:192.0.2.1"})
+	if err := cmd.Execute(); err != nil || buf.String() != "::ffff:192.0.2.0\n" {
+		t.Fatalf("anonymize mapped argument: %v %q", err, buf.String())
+	}
+}
+
+func TestTSVOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCmd(buf)
+	cmd.SetArgs([]string{"schema"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var docs map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	routes := filepath.Join(t.TempDir(), "routes.txt")
+	if err := os.WriteFile(routes, []byte("2001:db8::/64\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	invocations := map[string][]string{
+		"add":            {"add", "2001:db8::1", "5"},
+		"anonymize":      {"anonymize", "2001:db8::1"},
+		"at":             {"at", "2001:db8::/64", "--percent", "25"},
+		"bits":           {"bits", "::1"},
+		"bitwise and":    {"bitwise", "and", "::1", "::3"},
+		"bitwise or":     {"bitwise", "or", "::1", "::3"},
+		"bitwise xor":    {"bitwise", "xor", "::1", "::3"},
+		"classify-range": {"classify-range", "2001:db8::/48"},
+		"common-prefix":  {"common-prefix", "2001:db8::1", "2001:db8::2"},
+		"compress":       {"compress", "2001:0db8::1"},
+		"count":          {"count", "2001:db8::/48"},
+		"covers":         {"covers", "2001:db8::/63", "--routes", routes},
+		"dedup":          {"dedup", "2001:db8::1", "2001:db8::1"},
+		"diff":           {"diff", "2001:db8::/65", "2001:db8::/64"},
+		"enumerate":      {"enumerate", "2001:db8::/126"},
+		"expand":         {"expand", "::1"},
+		"explain":        {"explain", "2001:db8::/64"},
+		"filter":         {"filter", "--global-unicast"},
+		"fit":            {"fit", "2001:db8::/48", "--hosts", "300"},
+		"from-hex":       {"from-hex", "20010db8000000000000000000000001"},
+		"from-int":       {"from-int", "1"},
+		"gaps":           {"gaps", "2001:db8::/48", "--used", routes},
+		"group":          {"group", "2001:db8::1"},
+		"hash":           {"hash", "2001:db8::1"},
+		"histogram":      {"histogram", "2001:db8::/64"},
+		"info":           {"info", "2001:db8::/64"},
+		"mask":           {"mask", "2001:db8::/64"},
+		"multicast":      {"multicast", "ff02::1"},
+		"next":           {"next", "::1"},
+		"plan":           {"plan", "2001:db8::/48", "--hosts", "300,20"},
+		"position":       {"position", "2001:db8::/64", "2001:db8::ff"},
+		"prev":           {"prev", "::2"},
+		"privacy":        {"privacy", "2001:db8::/64", "--secret", "s", "--netiface", "eth0"},
+		"random address": {"random", "address", "2001:db8::/64"},
+		"random subnet":  {"random", "subnet", "2001:db8::/48", "--new-prefix", "64"},
+		"range":          {"range", "::1-::ff", "--with-meta"},
+		"renumber":       {"renumber", "--from", "2001:db8::/48", "--to", "2001:db9::/48", "--list", routes},
+		"reverse":        {"reverse", "2001:db8::1"},
+		"rewrite":        {"rewrite", "--from", "2001:db8::/32", "--to", "2001:470::/32", "2001:db8::1"},
+		"setbit":         {"setbit", "::1", "--pos", "127", "--val", "0"},
+		"sibling":        {"sibling", "2001:db8::/64"},
+		"solicited-node": {"solicited-node", "2001:db8::1"},
+		"special":        {"special", "::1"},
+		"split":          {"split", "2001:db8::/64", "--new-prefix", "66", "--count-only"},
+		"sub":            {"sub", "2001:db8::5", "1"},
+		"subnets-of":     {"subnets-of", "2001:db8::/48", "--routes", routes},
+		"summarize":      {"summarize", "--stats", "2001:db8::/65", "2001:db8:0:0:8000::/65"},
+		"supernet":       {"supernet", "2001:db8::/64", "2001:db8:1::/64"},
+		"supernet-of":    {"supernet-of", "2001:db8::/64"},
+		"table":          {"table"},
+		"to-hex":         {"to-hex", "::1"},
+		"to-int":         {"to-int", "::1"},
+		"tree":           {"tree", "2001:db8::/48"},
+		"ula generate":   {"ula", "generate"},
+		"ula info":       {"ula", "info", "fd12:3456:789a:1::1"},
+		"verify-cover":   {"verify-cover", "2001:db8::/64", "--parts", routes},
+		"version":        {"version"},
+		"walk":           {"walk", "::1-::3"},
+	}
+	for name := range docs {
+		args, ok := invocations[name]
+		if !ok {
+			t.Errorf("no tsv invocation for %q", name)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetIn(strings.NewReader("2001:db8::1\n"))
+		cmd.SetArgs(append([]string{"-o", "tsv"}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Errorf("%v: %v", args, err)
+		}
+	}
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"reverse", "2001:db8::1"}, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.\n"},
+		{[]string{"supernet", "2001:db8::/64", "2001:db8:1::/64"}, "2001:db8::/47\n"},
+		{[]string{"to-int", "::1"}, "1\n"},
+		{[]string{"next", "::1"}, "::2\n"},
+		{[]string{"split", "2001:db8::/64", "--new-prefix", "66", "--count-only"}, "count\t4\n"},
+	} {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCmd(buf)
+		cmd.SetArgs(append([]string{"-o", "tsv"}, tc.args...))
+		if err := cmd.Execute(); err != nil || buf.String() != tc.want {
+			t.Fatalf("%v: %v %q want %q", tc.args, err, buf.String(), tc.want)
+		}
+	}
+}
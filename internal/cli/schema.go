@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// SchemaVersion is the "schema" field wrapping every JSON and YAML result.
+const SchemaVersion = "ip6calc/v1"
+
+// jsonSchema is a JSON Schema (draft 2020-12) document or subschema.
+type jsonSchema = map[string]any
+
+var bigIntType = reflect.TypeOf((*big.Int)(nil))
+
+// schemaOf returns the schema of t as encoding/json marshals it: struct
+// properties come from json tags, non-omitempty fields are required, and
+// slices may be null since a nil slice encodes as null. A struct that
+// contains itself gets an $anchor named after the type, which the nested
+// occurrences reference.
+func schemaOf(t reflect.Type) jsonSchema { return schemaWalk(t, map[reflect.Type]bool{}) }
+
+// schemaWalk is schemaOf; open holds the structs being expanded and records
+// whether each was referenced from inside itself.
+func schemaWalk(t reflect.Type, open map[reflect.Type]bool) jsonSchema {
+	schemaOf := func(t reflect.Type) jsonSchema { return schemaWalk(t, open) }
+	if t == bigIntType {
+		return jsonSchema{"type": "integer"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return jsonSchema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return jsonSchema{"type": []string{"array", "null"}, "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return jsonSchema{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		if _, ok := open[t]; ok {
+			open[t] = true
+			return jsonSchema{"$ref": "#" + t.Name()}
+		}
+		open[t] = false
+		defer delete(open, t)
+		props := jsonSchema{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = schemaOf(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		s := jsonSchema{"type": "object", "properties": props, "required": required, "additionalProperties": false}
+		if open[t] {
+			s["$anchor"] = t.Name()
+		}
+		return s
+	}
+	return jsonSchema{}
+}
+
+// schemaFor returns the schema of T.
+func schemaFor[T any]() jsonSchema { return schemaOf(reflect.TypeOf((*T)(nil)).Elem()) }
+
+// oneOf is a schema matching exactly one of alts, for commands whose flags
+// change the shape of the result.
+func oneOf(alts ...jsonSchema) jsonSchema { return jsonSchema{"oneOf": alts} }
+
+// outputSchemas maps each command path to the schema of its data object.
+// Interactive and generator commands (repl, completion, docs, man, schema)
+// have no structured output and are absent.
+func outputSchemas() map[string]jsonSchema {
+	str := schemaFor[string]()
+	// lists are bare strings, or IndexedValue objects under --indexed
+	list := jsonSchema{"type": []string{"array", "null"}, "items": oneOf(str, schemaFor[IndexedValue]())}
+	info := []jsonSchema{schemaFor[InfoResult](), schemaFor[AddressInfoResult]()} // --all emits a list of either
+	return map[string]jsonSchema{
+		"info":           oneOf(info[0], info[1], jsonSchema{"type": []string{"array", "null"}, "items": oneOf(info...)}),
+		"mask":           schemaFor[MaskResult](),
+		"table":          schemaFor[[]PrefixRow](),
+		"position":       schemaFor[PositionResult](),
+		"at":             str,
+		"count":          schemaFor[SubnetCountResult](),
+		"expand":         list,
+		"compress":       list,
+		"dedup":          list,
+		"histogram":      schemaFor[map[string]int](),
+		"group":          schemaFor[[]GroupResult](),
+		"tree":           schemaFor[TreeNode](),
+		"bits":           str,
+		"setbit":         str,
+		"bitwise and":    str,
+		"bitwise or":     str,
+		"bitwise xor":    str,
+		"hash":           schemaFor[HashResult](),
+		"explain":        schemaFor[ExplainResult](),
+		"multicast":      schemaFor[MulticastResult](),
+		"solicited-node": str,
+		"split":          oneOf(list, schemaFor[CountResult](), schemaFor[SplitValidateResult]()),
+		"fit":            schemaFor[FitResult](),
+		"plan":           schemaFor[[]PlanRow](),
+		"summarize":      oneOf(list, schemaFor[SummarizeStatsResult]()),
+		"reverse":        oneOf(str, list),
+		"to-int":         str,
+		"from-int":       str,
+		"to-hex":         str,
+		"from-hex":       str,
+		"next":           str,
+		"prev":           str,
+		"add":            str,
+		"sub":            str,
+		"range":          oneOf(list, schemaFor[RangeResult]()),
+		"walk":           list,
+		"supernet":       str,
+		"supernet-of":    str,
+		"common-prefix":  schemaFor[CommonPrefixResult](),
+		"sibling":        str,
+		"enumerate":      oneOf(list, schemaFor[CountResult]()),
+		"random address": list,
+		"random subnet":  list,
+		"diff":           schemaFor[DiffResult](),
+		"gaps":           schemaFor[[]GapRow](),
+		"filter":         list,
+		"classify-range": schemaFor[ClassifyRangeResult](),
+		"special":        schemaFor[SpecialResult](),
+		"covers":         list,
+		"subnets-of":     list,
+		"verify-cover":   str,
+		"ula generate":   str,
+		"ula info":       schemaFor[ULAInfoResult](),
+		"privacy":        str,
+		"anonymize":      list,
+		"rewrite":        list,
+		"renumber":       list,
+		"version":        schemaFor[VersionResult](),
+	}
+}
+
+// outputDocument describes the documents command emits under json or yaml
+// output: data matching the command's schema, or an error object on failure.
+func outputDocument(command string, data jsonSchema) jsonSchema {
+	envelope := func(key string, body jsonSchema) jsonSchema {
+		return jsonSchema{
+			"type":                 "object",
+			"properties":           jsonSchema{"schema": jsonSchema{"const": SchemaVersion}, key: body},
+			"required":             []string{"schema", key},
+			"additionalProperties": false,
+		}
+	}
+	return jsonSchema{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "ip6calc " + command + " output",
+		"oneOf":   []jsonSchema{envelope("data", data), envelope("error", schemaFor[ErrorResult]())},
+	}
+}
@@ -4,15 +4,26 @@
 package ipv6
 
 import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
 	"math/big"
 	"math/bits"
 	"math/rand"
 	"net"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Sentinel errors
@@ -23,6 +34,36 @@ var (
 	ErrInvalidSplitPrefix = errors.New("ipv6: invalid new prefix")
 	// ErrSplitExcessive indicates a requested split would produce an excessive number of subnets.
 	ErrSplitExcessive = errors.New("ipv6: split produces excessive subnet count")
+	// ErrNotContained indicates an address lies outside the network it was checked against.
+	ErrNotContained = errors.New("ipv6: address not in network")
+	// ErrUnsorted indicates streaming input that is not in ascending order.
+	ErrUnsorted = errors.New("ipv6: input not sorted")
+	// ErrHostBitsSet indicates a CIDR whose address has bits set past the prefix length.
+	ErrHostBitsSet = errors.New("ipv6: host bits set")
+	// ErrNotMulticast indicates an address outside ff00::/8 was given where a multicast address is required.
+	ErrNotMulticast = errors.New("ipv6: not a multicast address")
+	// ErrNotULA indicates an address outside the unique local range fc00::/7.
+	ErrNotULA = errors.New("ipv6: not a unique local address")
+	// ErrInvalidCount indicates a subnet count that is not a positive power of two.
+	ErrInvalidCount = errors.New("ipv6: subnet count must be a power of two")
+	// ErrHostsExceedNetwork indicates a host requirement that does not fit in the network.
+	ErrHostsExceedNetwork = errors.New("ipv6: required hosts exceed network")
+	// ErrNoSpace indicates an allocation plan that does not fit in its parent network.
+	ErrNoSpace = errors.New("ipv6: not enough space in parent")
+	// ErrInvalidBit indicates a bit position outside 0..127 or a bit value other than 0 or 1.
+	ErrInvalidBit = errors.New("ipv6: invalid bit position or value")
+	// ErrEmptyList is returned by operations that need at least one input.
+	ErrEmptyList = errors.New("ipv6: empty list")
+	// ErrOverflow indicates checked arithmetic that would run past :: or the all-ones address.
+	ErrOverflow = errors.New("ipv6: address arithmetic overflow")
+	// ErrInvalidPercentile indicates a percentile outside 0..100.
+	ErrInvalidPercentile = errors.New("ipv6: percentile must be between 0 and 100")
+	// ErrIndexOutOfRange indicates a subnet index past the end of an iterator's sequence.
+	ErrIndexOutOfRange = errors.New("ipv6: index out of range")
+	// ErrInvalidRange indicates a malformed address range or one whose start lies after its end.
+	ErrInvalidRange = errors.New("ipv6: invalid range")
+	// ErrInvalidLimit indicates a result size limit below 1.
+	ErrInvalidLimit = errors.New("ipv6: limit must be at least 1")
 )
 
 const (
@@ -68,7 +109,9 @@ func NewAddress(ip net.IP) (Address, error) {
 	return Address{ip: append(net.IP(nil), v...)}, nil
 }
 
-// Parse converts a textual IPv6 address into an Address.
+// Parse converts a textual IPv6 address into an Address. It rejects the
+// IPv4-mapped range ::ffff:0:0/96, although arithmetic can land there (see
+// Add); use ParseAllowV4Mapped to read such values back.
 func Parse(s string) (Address, error) {
 	ip := net.ParseIP(strings.TrimSpace(s))
 	if ip == nil {
@@ -77,21 +120,169 @@ func Parse(s string) (Address, error) {
 	return NewAddress(ip)
 }
 
+// ParseAllowV4Mapped is like Parse but also accepts IPv4-mapped IPv6 addresses
+// (::ffff:a.b.c.d), reporting whether the input was mapped. The Address keeps
+// the 16-byte form. Bare IPv4 literals are still rejected.
+func ParseAllowV4Mapped(s string) (Address, bool, error) {
+	s = strings.TrimSpace(s)
+	ip := net.ParseIP(s)
+	if ip == nil || !strings.Contains(s, ":") {
+		return Address{}, false, fmt.Errorf("%w: %s", ErrInvalidAddress, s)
+	}
+	if ip.To4() == nil {
+		addr, err := NewAddress(ip)
+		return addr, false, err
+	}
+	return Address{ip: append(net.IP(nil), ip.To16()...)}, true, nil
+}
+
+// IPv4Mapped returns the embedded IPv4 address when a is IPv4-mapped
+// (::ffff:0:0/96). Such addresses come from ParseAllowV4Mapped,
+// FromBytesAllowV4Mapped, or arithmetic that lands in that range.
+func (a Address) IPv4Mapped() (net.IP, bool) {
+	v4 := a.ip.To4()
+	return v4, v4 != nil
+}
+
+// EmbeddedIPv4 returns the low 32 bits of a as an IPv4 address, the value a
+// trailing dotted quad denotes (2001:db8::192.0.2.1, RFC 4291 section 2.2).
+// Parse accepts that notation; only the caller knows whether it was used.
+func (a Address) EmbeddedIPv4() net.IP {
+	b := a.As16()
+	return net.IPv4(b[12], b[13], b[14], b[15]).To4()
+}
+
+// FromBytes returns the Address held in b, in network (big-endian) byte
+// order: b[0] is the most significant byte. Like NewAddress it rejects the
+// IPv4-mapped form; use FromBytesAllowV4Mapped to keep it.
+func FromBytes(b [16]byte) (Address, error) { return NewAddress(b[:]) }
+
+// FromBytesAllowV4Mapped is like FromBytes but accepts any 16-byte value,
+// including ::ffff:0:0/96.
+func FromBytesAllowV4Mapped(b [16]byte) Address {
+	return Address{ip: append(net.IP(nil), b[:]...)}
+}
+
+// As16 returns the address as 16 bytes in network (big-endian) byte order.
+// It does not allocate.
+func (a Address) As16() [16]byte {
+	var b [16]byte
+	copy(b[:], a.ip)
+	return b
+}
+
+// AsUint32x4 returns the address as four 32-bit words, most significant word
+// first; each word is decoded big-endian (network order), so the result of
+// 2001:db8::1 is {0x20010db8, 0, 0, 1}. It does not allocate.
+func (a Address) AsUint32x4() [4]uint32 {
+	b := a.As16()
+	var w [4]uint32
+	for i := range w {
+		w[i] = uint32(b[4*i])<<24 | uint32(b[4*i+1])<<16 | uint32(b[4*i+2])<<8 | uint32(b[4*i+3])
+	}
+	return w
+}
+
 // String returns the compressed textual representation.
-func (a Address) String() string { return a.ip.String() }
+func (a Address) String() string {
+	if v4 := a.ip.To4(); v4 != nil { // net.IP would render a mapped address as bare IPv4
+		return "::ffff:" + v4.String()
+	}
+	return a.ip.String()
+}
 
 // Expanded returns the fully expanded 8 * 16-bit hex block representation.
-func (a Address) Expanded() string {
+func (a Address) Expanded() string { return a.ExpandedSep(":") }
+
+// ExpandedSep is Expanded with the blocks joined by sep instead of ":", e.g.
+// "-" or "" for use in identifiers. The result does not parse as an address
+// unless sep is ":".
+func (a Address) ExpandedSep(sep string) string {
 	parts := make([]string, 8)
 	for i := 0; i < 8; i++ {
 		parts[i] = fmt.Sprintf("%04x", int(a.ip[2*i])<<8|int(a.ip[2*i+1]))
 	}
-	return strings.Join(parts, ":")
+	return strings.Join(parts, sep)
 }
 
 // ExpandedUpper returns the fully expanded uppercase hexadecimal form.
 func (a Address) ExpandedUpper() string { return strings.ToUpper(a.Expanded()) }
 
+// Bits returns the 128-character binary representation, most significant bit
+// first.
+func (a Address) Bits() string {
+	var b strings.Builder
+	b.Grow(BitLen)
+	for _, v := range a.ip {
+		fmt.Fprintf(&b, "%08b", v)
+	}
+	return b.String()
+}
+
+// Nibbles returns the 32 hex nibble values (0-15), most significant first.
+func (a Address) Nibbles() [32]byte {
+	var n [32]byte
+	for i, v := range a.ip {
+		n[2*i] = v >> 4
+		n[2*i+1] = v & 0x0f
+	}
+	return n
+}
+
+// GetBit returns the bit at pos. Positions are numbered MSB-first (0 is the
+// leftmost bit, 127 the rightmost), matching prefix length semantics.
+func (a Address) GetBit(pos int) (uint, error) {
+	if pos < 0 || pos >= BitLen {
+		return 0, fmt.Errorf("%w: position %d", ErrInvalidBit, pos)
+	}
+	return uint(a.ip[pos/8]>>uint(7-pos%8)) & 1, nil
+}
+
+// SetBit returns a copy of a with the bit at pos (MSB-first, see GetBit) set
+// to val, which must be 0 or 1.
+func (a Address) SetBit(pos int, val uint) (Address, error) {
+	if pos < 0 || pos >= BitLen {
+		return Address{}, fmt.Errorf("%w: position %d", ErrInvalidBit, pos)
+	}
+	if val > 1 {
+		return Address{}, fmt.Errorf("%w: value %d", ErrInvalidBit, val)
+	}
+	b := append(net.IP(nil), a.ip...)
+	m := byte(0x80) >> uint(pos%8)
+	if val == 1 {
+		b[pos/8] |= m
+	} else {
+		b[pos/8] &^= m
+	}
+	return Address{ip: b}, nil
+}
+
+// And returns the bitwise AND of a and b.
+func (a Address) And(b Address) Address { return bitwise(a, b, func(x, y byte) byte { return x & y }) }
+
+// Or returns the bitwise OR of a and b.
+func (a Address) Or(b Address) Address { return bitwise(a, b, func(x, y byte) byte { return x | y }) }
+
+// Xor returns the bitwise XOR of a and b.
+func (a Address) Xor(b Address) Address { return bitwise(a, b, func(x, y byte) byte { return x ^ y }) }
+
+// bitwise combines the raw 16 bytes of a and b. The result is built directly
+// rather than via NewAddress so values landing in ::ffff:0:0/96 are kept.
+func bitwise(a, b Address, op func(x, y byte) byte) Address {
+	res := make(net.IP, ByteLen)
+	for i := range res {
+		res[i] = op(a.ip[i], b.ip[i])
+	}
+	return Address{ip: res}
+}
+
+// HammingDistance returns the number of bit positions in which a and b differ.
+func HammingDistance(a, b Address) int {
+	ahi, alo := a.hiLo()
+	bhi, blo := b.hiLo()
+	return bits.OnesCount64(ahi^bhi) + bits.OnesCount64(alo^blo)
+}
+
 // MarshalText implements encoding.TextMarshaler.
 func (a Address) MarshalText() ([]byte, error) { return []byte(a.String()), nil }
 
@@ -117,6 +308,30 @@ func AddressFromBigInt(v *big.Int) (Address, error) {
 	return NewAddress(net.IP(b))
 }
 
+// Hex returns the address as 32 lowercase hex digits without separators.
+func (a Address) Hex() string {
+	b := a.As16()
+	return hex.EncodeToString(b[:])
+}
+
+// AddressFromHex parses exactly 32 hex digits (128 bits), ignoring colons and
+// an optional 0x prefix. Like NewAddress it rejects the IPv4-mapped form.
+func AddressFromHex(s string) (Address, error) {
+	digits := strings.TrimSpace(s)
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits = digits[2:]
+	}
+	digits = strings.ReplaceAll(digits, ":", "")
+	if len(digits) != 2*ByteLen {
+		return Address{}, fmt.Errorf("%w: %q has %d hex digits, want 32", ErrInvalidAddress, s, len(digits))
+	}
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return Address{}, fmt.Errorf("%w: %q is not hexadecimal", ErrInvalidAddress, s)
+	}
+	return NewAddress(b)
+}
+
 // internal fast representation helpers
 func (a Address) hiLo() (hi, lo uint64) {
 	for i := 0; i < 8; i++ {
@@ -137,11 +352,75 @@ func fromHiLo(hi, lo uint64) Address {
 		b[i] = byte(lo)
 		lo >>= 8
 	}
-	addr, _ := NewAddress(b)
-	return addr
+	return Address{ip: b} // no NewAddress: see Add on the IPv4-mapped range
+}
+
+// uint128 is an address value as two 64-bit words. Hot paths (summarize,
+// overlap and containment tests) use it to avoid big.Int and slice allocation.
+type uint128 struct{ hi, lo uint64 }
+
+func (a Address) u128() uint128 {
+	if len(a.ip) != ByteLen { // zero Address
+		return uint128{}
+	}
+	hi, lo := a.hiLo()
+	return uint128{hi, lo}
+}
+
+// mask clears all bits after the first plen.
+func (u uint128) mask(plen int) uint128 {
+	switch {
+	case plen <= 0:
+		return uint128{}
+	case plen < 64:
+		return uint128{u.hi &^ (^uint64(0) >> uint(plen)), 0}
+	case plen < BitLen:
+		return uint128{u.hi, u.lo &^ (^uint64(0) >> uint(plen-64))}
+	}
+	return u
+}
+
+// cmp returns -1, 0 or 1 as u is less than, equal to or greater than v.
+func (u uint128) cmp(v uint128) int {
+	switch {
+	case u.hi < v.hi || (u.hi == v.hi && u.lo < v.lo):
+		return -1
+	case u == v:
+		return 0
+	}
+	return 1
+}
+
+// span returns the first and last addresses of the /plen network holding u.
+func (u uint128) span(plen int) (first, last uint128) {
+	first = u.mask(plen)
+	ones := uint128{^uint64(0), ^uint64(0)}
+	m := ones.mask(plen)
+	return first, first.or(uint128{^m.hi, ^m.lo})
+}
+
+func (u uint128) and(v uint128) uint128 { return uint128{u.hi & v.hi, u.lo & v.lo} }
+func (u uint128) or(v uint128) uint128  { return uint128{u.hi | v.hi, u.lo | v.lo} }
+func (u uint128) isZero() bool          { return u.hi == 0 && u.lo == 0 }
+
+// inc returns u+1, wrapping the all-ones value to zero.
+func (u uint128) inc() uint128 {
+	lo, carry := bits.Add64(u.lo, 1, 0)
+	return uint128{u.hi + carry, lo}
+}
+
+// bitAt returns a value with only bit pos (MSB-first) set.
+func bitAt(pos int) uint128 {
+	if pos < 64 {
+		return uint128{1 << uint(63-pos), 0}
+	}
+	return uint128{0, 1 << uint(127-pos)}
 }
 
 // Add returns a+delta (mod 2^128). Negative deltas are treated as subtraction.
+// Arithmetic covers all 2^128 values, so Add, Sub, Mask, LastHost and the
+// iterators may return addresses in ::ffff:0:0/96, which print as
+// ::ffff:a.b.c.d and which Parse rejects.
 func (a Address) Add(delta *big.Int) Address {
 	if delta.Sign() < 0 {
 		return a.Sub(new(big.Int).Abs(delta))
@@ -162,8 +441,7 @@ func (a Address) Add(delta *big.Int) Address {
 	v.Add(v, delta)
 	v.Mod(v, mod)
 	b := v.FillBytes(make([]byte, 16))
-	addr, _ := NewAddress(b)
-	return addr
+	return Address{ip: b}
 }
 
 // Sub returns a-delta (mod 2^128).
@@ -192,8 +470,19 @@ func (a Address) Sub(delta *big.Int) Address {
 		v.Add(v, mod)
 	}
 	b := v.FillBytes(make([]byte, 16))
-	addr, _ := NewAddress(b)
-	return addr
+	return Address{ip: b}
+}
+
+// AddChecked returns a+delta like Add, but fails with ErrOverflow instead of
+// wrapping when the result would be below :: or above the all-ones address.
+// Negative deltas subtract.
+func AddChecked(a Address, delta *big.Int) (Address, error) {
+	v := a.BigInt()
+	v.Add(v, delta)
+	if v.Sign() < 0 || v.BitLen() > 128 {
+		return Address{}, fmt.Errorf("%w: %s%+d", ErrOverflow, a, delta)
+	}
+	return Address{ip: v.FillBytes(make([]byte, 16))}, nil
 }
 
 // Compare performs lexicographic comparison: -1 if a<b, 0 if equal, 1 if a>b.
@@ -225,20 +514,83 @@ type CIDR struct {
 
 // ParseCIDR parses a CIDR (address/prefix) string.
 func ParseCIDR(s string) (CIDR, error) {
+	addr, plen, err := parseCIDR(s)
+	if err != nil {
+		return CIDR{}, err
+	}
+	return NewCIDR(addr, plen)
+}
+
+// ParseCIDRStrict is like ParseCIDR but rejects input with host bits set
+// (e.g. 2001:db8::1/64) instead of masking it. The error names the canonical
+// form.
+func ParseCIDRStrict(s string) (CIDR, error) {
+	addr, plen, err := parseCIDR(s)
+	if err != nil {
+		return CIDR{}, err
+	}
+	c, err := NewCIDR(addr, plen)
+	if err != nil {
+		return CIDR{}, err
+	}
+	if !c.IsHostBitsZero(addr) {
+		return CIDR{}, fmt.Errorf("%w: %s (canonical form is %s)", ErrHostBitsSet, strings.TrimSpace(s), c)
+	}
+	return c, nil
+}
+
+// HostCIDR is a network together with one of its addresses, as written in
+// interface configuration (2001:db8::1/64). The embedded CIDR is the masked
+// network, so arithmetic and containment behave as for the network; only
+// String shows the host.
+type HostCIDR struct {
+	CIDR
+	host Address
+}
+
+// WithHost returns c carrying host a for display. a must be in c.
+func (c CIDR) WithHost(a Address) (HostCIDR, error) {
+	if !c.ContainsAddress(a) {
+		return HostCIDR{}, fmt.Errorf("%w: %s not in %s", ErrNotContained, a, c)
+	}
+	return HostCIDR{CIDR: c, host: a}, nil
+}
+
+// ParseHostCIDR is like ParseCIDR but keeps the address as written as the
+// host, host bits included.
+func ParseHostCIDR(s string) (HostCIDR, error) {
+	addr, plen, err := parseCIDR(s)
+	if err != nil {
+		return HostCIDR{}, err
+	}
+	c, err := NewCIDR(addr, plen)
+	if err != nil {
+		return HostCIDR{}, err
+	}
+	return HostCIDR{CIDR: c, host: addr}, nil
+}
+
+// Host returns the retained host address.
+func (h HostCIDR) Host() Address { return h.host }
+
+// String returns the host address with the prefix length, e.g. 2001:db8::1/64.
+func (h HostCIDR) String() string { return fmt.Sprintf("%s/%d", h.host, h.plen) }
+
+func parseCIDR(s string) (Address, int, error) {
 	// Manual split to distinguish invalid address versus invalid prefix
 	parts := strings.Split(strings.TrimSpace(s), "/")
 	if len(parts) != 2 {
-		return CIDR{}, ErrInvalidCIDR
+		return Address{}, 0, ErrInvalidCIDR
 	}
 	addr, err := Parse(parts[0])
 	if err != nil {
-		return CIDR{}, err
+		return Address{}, 0, err
 	}
 	plen, perr := parsePrefix(parts[1])
 	if perr != nil {
-		return CIDR{}, perr
+		return Address{}, 0, perr
 	}
-	return NewCIDR(addr, plen)
+	return addr, plen, nil
 }
 
 func parsePrefix(p string) (int, error) {
@@ -289,8 +641,24 @@ func (a Address) Mask(plen int) Address {
 	for i := 0; i < ByteLen; i++ {
 		b[i] &= m[i]
 	}
-	addr, _ := NewAddress(b)
-	return addr
+	return Address{ip: b}
+}
+
+// Netmask returns the prefix mask as an address (all-zeros for /0, all-ones
+// for /128).
+func (c CIDR) Netmask() Address {
+	m := maskTable[c.plen]
+	return Address{ip: append(net.IP(nil), m[:]...)}
+}
+
+// HostMask returns the inverse of Netmask (the Cisco-style wildcard mask).
+func (c CIDR) HostMask() Address {
+	m := maskTable[c.plen]
+	b := make(net.IP, ByteLen)
+	for i := range b {
+		b[i] = ^m[i]
+	}
+	return Address{ip: b}
 }
 
 // Network returns the base (network) address.
@@ -302,6 +670,55 @@ func (c CIDR) HostCount() *big.Int {
 	return new(big.Int).Lsh(big.NewInt(1), uint(bits))
 }
 
+// CountSubnets returns how many /prefix subnets fit in c: 2^(prefix-plen).
+// prefix must be between c's prefix length and 128.
+func (c CIDR) CountSubnets(prefix int) (*big.Int, error) {
+	if prefix < c.plen || prefix > 128 {
+		return nil, fmt.Errorf("%w: /%d for %s", ErrInvalidSplitPrefix, prefix, c)
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(prefix-c.plen)), nil
+}
+
+// UsableCount returns the number of assignable addresses. When skipAnycast is
+// set and c is shorter than /127 the subnet-router anycast address is excluded.
+func (c CIDR) UsableCount(skipAnycast bool) *big.Int {
+	n := c.HostCount()
+	if skipAnycast && c.plen < 127 {
+		n.Sub(n, big.NewInt(1))
+	}
+	return n
+}
+
+// FormatCount renders n three ways: raw decimal, power-of-two notation
+// ("2^N", empty unless n is an exact power of two) and an approximate
+// scientific form such as "1.84e19". The CLI uses it for host counts in info
+// and table; it applies equally to range sizes or any other count.
+func FormatCount(n *big.Int) (raw, power, approx string) {
+	raw = n.String()
+	// power-of-two detection: n>0 and n&(n-1)==0
+	if n.Sign() > 0 {
+		m := new(big.Int).Sub(n, big.NewInt(1))
+		if new(big.Int).And(m, n).Sign() == 0 { // exact power of two
+			power = fmt.Sprintf("2^%d", n.BitLen()-1)
+		}
+	}
+	// approximate decimal (scientific)
+	if n.Sign() == 0 {
+		approx = "0"
+	} else {
+		ln10 := new(big.Float).SetFloat64(10)
+		bf := new(big.Float).SetInt(n)
+		exp := 0
+		for bf.Cmp(ln10) >= 0 {
+			bf.Quo(bf, ln10)
+			exp++
+		}
+		f, _ := bf.Float64()
+		approx = fmt.Sprintf("%.2fe%d", f, exp)
+	}
+	return
+}
+
 // FirstHost returns the first address (same as the network address in IPv6).
 func (c CIDR) FirstHost() Address { return c.base }
 
@@ -312,23 +729,84 @@ func (c CIDR) LastHost() Address {
 	last := new(big.Int).Add(bc, cnt)
 	last.Sub(last, big.NewInt(1))
 	b := last.FillBytes(make([]byte, 16))
-	addr, _ := NewAddress(b)
-	return addr
+	return Address{ip: b}
 }
 
 // ContainsAddress reports whether a is inside c.
-func (c CIDR) ContainsAddress(a Address) bool { return c.base.Compare(a.Mask(c.plen)) == 0 }
+// It compares masked 64-bit words directly and does not allocate.
+func (c CIDR) ContainsAddress(a Address) bool { return a.u128().mask(c.plen) == c.base.u128() }
+
+// IsHostBitsZero reports whether a has no bits set past c's prefix length,
+// i.e. whether a with c's prefix length is already canonical.
+func (c CIDR) IsHostBitsZero(a Address) bool {
+	u := a.u128()
+	return u.mask(c.plen) == u
+}
+
+// IsNetworkAddress reports whether a is the base address of c.
+func (c CIDR) IsNetworkAddress(a Address) bool { return c.base.Compare(a) == 0 }
+
+// IsLastAddress reports whether a is the last address of c.
+func (c CIDR) IsLastAddress(a Address) bool { return c.LastHost().Compare(a) == 0 }
+
+// Position returns the zero-based index of a within c.
+func (c CIDR) Position(a Address) (*big.Int, error) {
+	if !c.ContainsAddress(a) {
+		return nil, fmt.Errorf("%w: %s not in %s", ErrNotContained, a, c)
+	}
+	return Distance(c.base, a), nil
+}
+
+// AddressAtPercentile returns the address p percent of the way into c, i.e.
+// base + floor(p/100 * HostCount). p is converted exactly to a rational, so
+// the result is exact for any prefix length; 100 yields the last address.
+func (c CIDR) AddressAtPercentile(p float64) (Address, error) {
+	if math.IsNaN(p) || p < 0 || p > 100 {
+		return Address{}, fmt.Errorf("%w: %v", ErrInvalidPercentile, p)
+	}
+	if p == 100 {
+		return c.LastHost(), nil
+	}
+	off := new(big.Rat).SetFloat64(p)
+	off.Mul(off, new(big.Rat).SetInt(c.HostCount()))
+	off.Quo(off, big.NewRat(100, 1))
+	return c.base.Add(new(big.Int).Quo(off.Num(), off.Denom())), nil
+}
+
+// PercentileOf returns how far a lies into c as a percentage of HostCount,
+// the inverse of AddressAtPercentile up to float64 rounding. The ratio is
+// formed exactly with big.Rat and only the result is converted to float64.
+func (c CIDR) PercentileOf(a Address) (float64, error) {
+	pos, err := c.Position(a)
+	if err != nil {
+		return 0, err
+	}
+	f, _ := new(big.Rat).SetFrac(pos.Mul(pos, big.NewInt(100)), c.HostCount()).Float64()
+	return f, nil
+}
+
+// AddWrap returns a+delta wrapped modulo HostCount, so the result stays in c:
+// adding past the last address continues from the base, and a negative delta
+// wraps past the base to the end. a must be in c.
+func (c CIDR) AddWrap(a Address, delta *big.Int) (Address, error) {
+	pos, err := c.Position(a)
+	if err != nil {
+		return Address{}, err
+	}
+	pos.Add(pos, delta)
+	return c.base.Add(pos.Mod(pos, c.HostCount())), nil // Mod is Euclidean: never negative
+}
 
 // ContainsCIDR reports whether network o is fully contained within c.
 func (c CIDR) ContainsCIDR(o CIDR) bool { return c.plen <= o.plen && c.ContainsAddress(o.base) }
 
 // Overlaps reports whether two networks overlap in address space (interval test).
+// Every prefix fits in 128 bits, so the test is done on uint128 bounds and
+// never allocates.
 func (c CIDR) Overlaps(o CIDR) bool {
-	cStart := c.FirstHost().BigInt()
-	cEnd := c.LastHost().BigInt()
-	oStart := o.FirstHost().BigInt()
-	oEnd := o.LastHost().BigInt()
-	return cStart.Cmp(oEnd) <= 0 && oStart.Cmp(cEnd) <= 0
+	cStart, cEnd := c.base.u128().span(c.plen)
+	oStart, oEnd := o.base.u128().span(o.plen)
+	return cStart.cmp(oEnd) <= 0 && oStart.cmp(cEnd) <= 0
 }
 
 // Next returns the next adjacent network of the same prefix length.
@@ -347,6 +825,54 @@ func (c CIDR) Prev() CIDR {
 	return res
 }
 
+// SupernetAt returns the network containing c at the shorter (or equal) prefix
+// length prefix. Unlike Supernet, which spans several inputs, it walks up from
+// a single network.
+func (c CIDR) SupernetAt(prefix int) (CIDR, error) {
+	if prefix < 0 || prefix > c.plen {
+		return CIDR{}, ErrInvalidPrefix
+	}
+	return NewCIDR(c.base, prefix)
+}
+
+// Parent returns the network one prefix length above c. At /0 it returns c.
+func (c CIDR) Parent() CIDR {
+	if c.plen == 0 {
+		return c
+	}
+	p, _ := NewCIDR(c.base, c.plen-1)
+	return p
+}
+
+// Sibling returns the other half of c's parent, obtained by flipping the last
+// prefix bit of the base. It returns false at /0.
+func (c CIDR) Sibling() (CIDR, bool) {
+	if c.plen == 0 {
+		return c, false
+	}
+	b := append(net.IP(nil), c.base.ip...)
+	pos := c.plen - 1
+	b[pos/8] ^= 0x80 >> uint(pos%8)
+	return CIDR{base: Address{ip: b}, plen: c.plen}, true
+}
+
+// SharesParentWith reports whether c and o have the same prefix length and the
+// same immediate parent (e.g. the two /65 halves of a /64).
+func (c CIDR) SharesParentWith(o CIDR) bool {
+	if c.plen != o.plen || c.plen == 0 {
+		return false
+	}
+	return c.Parent().base.Compare(o.Parent().base) == 0
+}
+
+// Equal reports whether c and o have the same base address bytes and prefix
+// length.
+func (c CIDR) Equal(o CIDR) bool { return c.plen == o.plen && c.base.Compare(o.base) == 0 }
+
+// Canonical returns c with its host bits cleared. CIDRs from ParseCIDR and
+// NewCIDR are already canonical; this makes the guarantee explicit.
+func (c CIDR) Canonical() CIDR { return CIDR{base: c.base.Mask(c.plen), plen: c.plen} }
+
 // Split divides the network into subnets of newPrefix length. Allows newPrefix == c.plen (returns self).
 func (c CIDR) Split(newPrefix int) ([]CIDR, error) {
 	if newPrefix < c.plen || newPrefix > 128 {
@@ -355,16 +881,12 @@ func (c CIDR) Split(newPrefix int) ([]CIDR, error) {
 	if newPrefix == c.plen { // degenerate split: single subnet
 		return []CIDR{c}, nil
 	}
-	countBits := newPrefix - c.plen
-	if countBits >= 63 { // guard shift overflow / unrealistic allocation
-		return nil, ErrSplitExcessive
-	}
-	parts := uint64(1) << uint(countBits)
-	if parts > MaxSplitParts { // safety cap
-		return nil, ErrSplitExcessive
+	parts, err := c.splitParts(newPrefix)
+	if err != nil {
+		return nil, err
 	}
 	res := make([]CIDR, 0, parts)
-	step := new(big.Int).Rsh(c.HostCount(), uint(countBits))
+	step := new(big.Int).Rsh(c.HostCount(), uint(newPrefix-c.plen))
 	cur := c.base
 	for i := uint64(0); i < parts; i++ {
 		sub, _ := NewCIDR(cur, newPrefix)
@@ -374,12 +896,168 @@ func (c CIDR) Split(newPrefix int) ([]CIDR, error) {
 	return res, nil
 }
 
+// WalkSubnetTree visits c and its subnets depth levels down, splitting one
+// bit at a time, in depth-first pre-order: each network comes before its
+// lower and then upper half. level is 0 for c. Returning false from visit
+// skips that network's children. Nothing is materialised beyond the current
+// path, but a full walk visits 2^(depth+1)-1 networks, so callers choose
+// depth accordingly. c's prefix length plus depth must not exceed 128.
+func WalkSubnetTree(c CIDR, depth int, visit func(n CIDR, level int) bool) error {
+	if depth < 0 || c.plen+depth > 128 {
+		return fmt.Errorf("%w: depth %d below %s", ErrInvalidSplitPrefix, depth, c)
+	}
+	walkSubnetTree(c, 0, depth, visit)
+	return nil
+}
+
+func walkSubnetTree(c CIDR, level, depth int, visit func(CIDR, int) bool) {
+	if !visit(c, level) || level == depth {
+		return
+	}
+	halves, _ := c.Split(c.plen + 1)
+	for _, h := range halves {
+		walkSubnetTree(h, level+1, depth, visit)
+	}
+}
+
+// PrefixForCount returns the prefix length that divides c into exactly n equal
+// subnets. n must be a power of two; otherwise the error names the nearest
+// valid counts.
+func (c CIDR) PrefixForCount(n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidCount, n)
+	}
+	if n&(n-1) != 0 {
+		lower := 1 << (bits.Len(uint(n)) - 1)
+		if upper := lower << 1; upper > 0 {
+			return 0, fmt.Errorf("%w: %d (nearest valid counts: %d or %d)", ErrInvalidCount, n, lower, upper)
+		}
+		// the next power of two does not fit in an int
+		return 0, fmt.Errorf("%w: %d (nearest valid count: %d)", ErrInvalidCount, n, lower)
+	}
+	newPrefix := c.plen + bits.TrailingZeros(uint(n))
+	if newPrefix > BitLen {
+		return 0, fmt.Errorf("%w: %d subnets do not fit in %s", ErrInvalidSplitPrefix, n, c)
+	}
+	return newPrefix, nil
+}
+
+// SplitInto divides c into n equal subnets, n being a power of two. See
+// PrefixForCount and Split.
+func (c CIDR) SplitInto(n int) ([]CIDR, error) {
+	newPrefix, err := c.PrefixForCount(n)
+	if err != nil {
+		return nil, err
+	}
+	return c.Split(newPrefix)
+}
+
+// SubnetForHosts returns the longest prefix length whose networks hold at
+// least minHosts addresses. It returns ErrHostsExceedNetwork when that prefix
+// would be shorter than c itself.
+func (c CIDR) SubnetForHosts(minHosts *big.Int) (int, error) {
+	if minHosts.Sign() < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrHostsExceedNetwork, minHosts)
+	}
+	hostBits := 0
+	if minHosts.Sign() > 0 {
+		hostBits = new(big.Int).Sub(minHosts, big.NewInt(1)).BitLen() // ceil(log2(minHosts))
+	}
+	p := BitLen - hostBits
+	if p < c.plen {
+		return 0, fmt.Errorf("%w: %s addresses need a /%d, larger than %s", ErrHostsExceedNetwork, minHosts, p, c)
+	}
+	return p, nil
+}
+
+// splitParts returns the number of /newPrefix subnets in c, enforcing the
+// MaxSplitParts cap. newPrefix must be longer than c.plen.
+func (c CIDR) splitParts(newPrefix int) (uint64, error) {
+	countBits := newPrefix - c.plen
+	if countBits >= 63 { // guard shift overflow / unrealistic allocation
+		return 0, ErrSplitExcessive
+	}
+	parts := uint64(1) << uint(countBits)
+	if parts > MaxSplitParts { // safety cap
+		return 0, ErrSplitExcessive
+	}
+	return parts, nil
+}
+
+// SplitParallel returns the same subnets as Split, in the same order, but fills
+// the result with up to workers goroutines. Each subnet base is computed
+// directly as base + i*step, so chunks are independent. workers <= 0 uses
+// GOMAXPROCS.
+func (c CIDR) SplitParallel(newPrefix, workers int) ([]CIDR, error) {
+	if newPrefix < c.plen || newPrefix > 128 {
+		return nil, ErrInvalidSplitPrefix
+	}
+	if newPrefix == c.plen {
+		return []CIDR{c}, nil
+	}
+	parts, err := c.splitParts(newPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if uint64(workers) > parts {
+		workers = int(parts)
+	}
+	res := make([]CIDR, parts)
+	hostBits := uint(BitLen - newPrefix)
+	bhi, blo := c.base.hiLo()
+	chunk := (int(parts) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < int(parts); start += chunk {
+		end := min(start+chunk, int(parts))
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				// i<<hostBits as a 128-bit value; cannot overflow past c.
+				var ohi, olo uint64
+				if hostBits >= 64 {
+					ohi = uint64(i) << (hostBits - 64)
+				} else {
+					olo = uint64(i) << hostBits
+					ohi = uint64(i) >> (64 - hostBits)
+				}
+				lo, carry := bits.Add64(blo, olo, 0)
+				hi, _ := bits.Add64(bhi, ohi, carry)
+				res[i] = CIDR{base: fromHiLo(hi, lo), plen: newPrefix}
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return res, nil
+}
+
+// CIDRIterator is a stream of networks, such as a SubnetIterator or a
+// CoverIterator. Next returns the next network and true, or false once the
+// stream is exhausted.
+type CIDRIterator interface {
+	Next() (CIDR, bool)
+}
+
+// AddressStream is a stream of addresses, such as an AddressIterator, with
+// the same Next contract as CIDRIterator. The concrete iterator keeps the
+// AddressIterator name for compatibility.
+type AddressStream interface {
+	Next() (Address, bool)
+}
+
 // SubnetIterator allows streaming iteration over subnets without allocating all.
+// Besides streaming with Next it is a lazy indexable sequence: At computes any
+// subnet directly and Peek looks ahead, neither moving the cursor.
 type SubnetIterator struct {
 	remaining int
 	current   Address
 	step      *big.Int
 	plen      int
+	base      Address
+	total     uint64
 }
 
 // SubnetIterator returns an iterator for subnets at newPrefix. Allows equality (single subnet iteration).
@@ -388,7 +1066,7 @@ func (c CIDR) SubnetIterator(newPrefix int) (*SubnetIterator, error) {
 		return nil, ErrInvalidSplitPrefix
 	}
 	if newPrefix == c.plen {
-		return &SubnetIterator{remaining: 1, current: c.base, step: new(big.Int), plen: newPrefix}, nil
+		return &SubnetIterator{remaining: 1, current: c.base, step: new(big.Int), plen: newPrefix, base: c.base, total: 1}, nil
 	}
 	countBits := newPrefix - c.plen
 	if countBits >= 63 {
@@ -399,7 +1077,28 @@ func (c CIDR) SubnetIterator(newPrefix int) (*SubnetIterator, error) {
 		return nil, ErrSplitExcessive
 	}
 	step := new(big.Int).Rsh(c.HostCount(), uint(countBits))
-	return &SubnetIterator{remaining: int(parts), current: c.base, step: step, plen: newPrefix}, nil
+	return &SubnetIterator{remaining: int(parts), current: c.base, step: step, plen: newPrefix, base: c.base, total: parts}, nil
+}
+
+// Len returns the total number of subnets in the sequence, consumed or not.
+func (it *SubnetIterator) Len() uint64 { return it.total }
+
+// At returns the i-th subnet (0-based) as base + i*step, independent of how
+// far Next has advanced. It returns ErrIndexOutOfRange when i >= Len().
+func (it *SubnetIterator) At(i uint64) (CIDR, error) {
+	if i >= it.total {
+		return CIDR{}, fmt.Errorf("%w: %d of %d subnets", ErrIndexOutOfRange, i, it.total)
+	}
+	off := new(big.Int).Mul(it.step, new(big.Int).SetUint64(i))
+	return CIDR{base: it.base.Add(off), plen: it.plen}, nil
+}
+
+// Peek returns the subnet the next call to Next will return, without consuming it.
+func (it *SubnetIterator) Peek() (CIDR, bool) {
+	if it.remaining == 0 {
+		return CIDR{}, false
+	}
+	return CIDR{base: it.current, plen: it.plen}, true
 }
 
 // Next returns next subnet and true, or zero value and false when done.
@@ -413,58 +1112,480 @@ func (it *SubnetIterator) Next() (CIDR, bool) {
 	return c, true
 }
 
+// AddressIterator allows streaming iteration over an inclusive address range
+// one address at a time.
+type AddressIterator struct {
+	current Address
+	end     Address
+	done    bool
+}
+
+// HostIterator returns an iterator over the addresses of c. When skipAnycast is
+// set and c is shorter than /127, the subnet-router anycast address (the
+// all-zeros interface ID, i.e. the base) is skipped. For /127 and /128 the flag
+// has no effect.
+func (c CIDR) HostIterator(skipAnycast bool) *AddressIterator {
+	start := c.base
+	if skipAnycast && c.plen < 127 {
+		start = start.Offset(1)
+	}
+	return &AddressIterator{current: start, end: c.LastHost()}
+}
+
+// RangeIterator returns an iterator over the inclusive range [start,end]. The
+// iterator yields nothing when start > end.
+func RangeIterator(start, end Address) *AddressIterator {
+	return &AddressIterator{current: start, end: end, done: start.Compare(end) > 0}
+}
+
+// Next returns next address and true, or zero value and false when done.
+func (it *AddressIterator) Next() (Address, bool) {
+	if it.done {
+		return Address{}, false
+	}
+	a := it.current
+	if a.Compare(it.end) == 0 { // stop on the last address instead of wrapping past all-ones
+		it.done = true
+	} else {
+		it.current = a.Offset(1)
+	}
+	return a, true
+}
+
 // Summarize tries to merge CIDRs into the minimal covering list by combining
 // sibling networks where possible.
-func Summarize(cidrs []CIDR) []CIDR {
-	if len(cidrs) == 0 {
-		return nil
+func Summarize(cidrs []CIDR) []CIDR { return summarize(cidrs, 0) }
+
+// SummarizeMax performs the same greedy merge as Summarize but never creates a
+// parent network shorter than maxAggPrefix. Inputs already shorter than the
+// limit are kept as-is. A maxAggPrefix of 128 creates no parents at all, so the
+// result is the sorted input with exact duplicates (and covered networks)
+// collapsed.
+func SummarizeMax(cidrs []CIDR, maxAggPrefix int) []CIDR {
+	if maxAggPrefix < 0 {
+		maxAggPrefix = 0
 	}
-	// normalize & sort by base then prefix length (shorter first)
-	norm := make([]CIDR, len(cidrs))
-	copy(norm, cidrs)
-	for i := range norm {
-		norm[i].base = norm[i].base.Mask(norm[i].plen)
+	return summarize(cidrs, maxAggPrefix)
+}
+
+// SummarizeToGranularity is a lossy variant of Summarize: every input longer
+// than maxLen is first widened to its enclosing /maxLen, then the list is
+// summarized. The result never contains a prefix longer than maxLen and
+// covers every input, but it may also cover addresses no input did. Use
+// Summarize when the output must describe exactly the same address set.
+func SummarizeToGranularity(cidrs []CIDR, maxLen int) []CIDR {
+	if maxLen < 0 {
+		maxLen = 0
 	}
-	sort.Slice(norm, func(i, j int) bool {
-		cmp := norm[i].base.Compare(norm[j].base)
+	widened := make([]CIDR, len(cidrs))
+	for i, c := range cidrs {
+		if c.plen > maxLen {
+			c = CIDR{base: c.base.Mask(maxLen), plen: maxLen}
+		}
+		widened[i] = c
+	}
+	return Summarize(widened)
+}
+
+// SummaryStats describes the effect of a summarization.
+type SummaryStats struct {
+	InputCount  int
+	OutputCount int
+	// TotalAddresses is the size of the union of the inputs; overlapping
+	// inputs are counted once.
+	TotalAddresses *big.Int
+}
+
+// SummarizeStats returns Summarize(cidrs) together with statistics about the
+// merge.
+func SummarizeStats(cidrs []CIDR) ([]CIDR, SummaryStats) {
+	res := Summarize(cidrs)
+	total := new(big.Int)
+	for _, c := range res { // summarized output never overlaps
+		total.Add(total, c.HostCount())
+	}
+	return res, SummaryStats{InputCount: len(cidrs), OutputCount: len(res), TotalAddresses: total}
+}
+
+func summarize(cidrs []CIDR, minParent int) []CIDR {
+	if len(cidrs) == 0 {
+		return nil
+	}
+	// normalize & sort by base then prefix length (shorter first)
+	norm := make([]CIDR, len(cidrs))
+	copy(norm, cidrs)
+	for i := range norm {
+		if b := norm[i].base.u128(); b.mask(norm[i].plen) != b {
+			norm[i].base = norm[i].base.Mask(norm[i].plen)
+		}
+	}
+	sort.Slice(norm, func(i, j int) bool {
+		cmp := norm[i].base.Compare(norm[j].base)
 		if cmp == 0 {
 			return norm[i].plen < norm[j].plen
 		}
 		return cmp < 0
 	})
-	stack := make([]CIDR, 0, len(norm))
+	// drop exact duplicates, adjacent once sorted, so duplicate-heavy input
+	// costs neither merge work nor result capacity
+	uniq := norm[:1]
+	for _, c := range norm[1:] {
+		if !c.Equal(uniq[len(uniq)-1]) {
+			uniq = append(uniq, c)
+		}
+	}
+	norm = uniq
+	res := make([]CIDR, 0, len(norm))
+	s := &Summarizer{emit: func(c CIDR) { res = append(res, c) }, minParent: minParent}
 	for _, c := range norm {
-		// skip if contained in previous summarized CIDR
-		if l := len(stack); l > 0 && stack[l-1].ContainsCIDR(c) {
-			continue
+		_ = s.Push(c) // sorted above, cannot fail
+	}
+	s.Flush()
+	return res
+}
+
+// Summarizer incrementally merges CIDRs arriving sorted by base address (ties
+// broken by shorter prefix first), producing the same result as Summarize.
+// Each summarized CIDR is emitted as soon as no later input can merge with it,
+// so at most one pending network per prefix length is held in memory.
+type Summarizer struct {
+	emit      func(CIDR)
+	minParent int
+	stack     []CIDR
+	last      CIDR
+	started   bool
+}
+
+// NewSummarizer returns a Summarizer passing results to emit in ascending order.
+func NewSummarizer(emit func(CIDR)) *Summarizer { return &Summarizer{emit: emit} }
+
+// Push adds the next CIDR. It returns ErrUnsorted if c sorts before the
+// previously pushed CIDR.
+func (s *Summarizer) Push(c CIDR) error {
+	if s.started {
+		cmp := c.base.Compare(s.last.base)
+		if cmp < 0 || (cmp == 0 && c.plen < s.last.plen) {
+			return fmt.Errorf("%w: %s after %s", ErrUnsorted, c, s.last)
 		}
-		stack = append(stack, c)
-		// attempt upward merges greedily
-		for len(stack) >= 2 {
-			last := stack[len(stack)-1]
-			prev := stack[len(stack)-2]
-			if last.plen != prev.plen {
-				break
-			}
-			if last.plen == 0 { // cannot merge further
-				break
-			}
-			if prev.Next().base.Compare(last.base) != 0 { // not adjacent siblings
-				break
-			}
-			parentPrefix := last.plen - 1
-			parentBase := prev.base.Mask(parentPrefix)
-			// ensure alignment
-			if parentBase.Compare(last.base.Mask(parentPrefix)) != 0 {
-				break
-			}
-			// merge
-			stack = stack[:len(stack)-2]
-			parent, _ := NewCIDR(parentBase, parentPrefix)
-			stack = append(stack, parent)
+	}
+	s.last, s.started = c, true
+	// skip if contained in previous summarized CIDR
+	if l := len(s.stack); l > 0 && s.stack[l-1].ContainsCIDR(c) {
+		return nil
+	}
+	s.stack = append(s.stack, c)
+	// attempt upward merges greedily
+	for len(s.stack) >= 2 {
+		last := s.stack[len(s.stack)-1]
+		prev := s.stack[len(s.stack)-2]
+		if last.plen != prev.plen {
+			break
+		}
+		if last.plen == 0 || last.plen-1 < s.minParent { // cannot merge further
+			break
 		}
+		// prev and last are siblings iff prev is the left child and last is
+		// prev with the final prefix bit set.
+		bit := bitAt(last.plen - 1)
+		p := prev.base.u128()
+		if !p.and(bit).isZero() || p.or(bit) != last.base.u128() {
+			break
+		}
+		// merge; a left child's base is already canonical for the parent
+		s.stack = s.stack[:len(s.stack)-2]
+		s.stack = append(s.stack, CIDR{base: prev.base, plen: last.plen - 1})
+	}
+	// Emit settled networks. A pending network can only merge later if it is a
+	// left child whose sibling is being assembled above it and that chain can
+	// itself still merge, so everything below the highest break is final and
+	// what remains has strictly increasing prefix lengths.
+	n := 0
+	for i := len(s.stack) - 2; i >= 0; i-- {
+		if !s.mayMerge(s.stack[i], s.stack[i+1]) {
+			n = i + 1
+			break
+		}
+	}
+	for _, c := range s.stack[:n] {
+		s.emit(c)
+	}
+	if n > 0 {
+		s.stack = append(s.stack[:0], s.stack[n:]...)
+	}
+	return nil
+}
+
+// mayMerge reports whether c could still merge with its sibling given that
+// next is the following pending network.
+func (s *Summarizer) mayMerge(c, next CIDR) bool {
+	if c.plen == 0 || c.plen-1 < s.minParent {
+		return false
+	}
+	bit := bitAt(c.plen - 1)
+	cu := c.base.u128()
+	if !cu.and(bit).isZero() { // right child: its sibling came before
+		return false
+	}
+	return next.plen >= c.plen && next.base.u128().mask(c.plen) == cu.or(bit)
+}
+
+// Flush emits all pending networks. The Summarizer may be reused afterwards.
+func (s *Summarizer) Flush() {
+	for _, c := range s.stack {
+		s.emit(c)
+	}
+	s.stack = s.stack[:0]
+	s.started = false
+}
+
+// SummarizeStream summarizes in, which must already be sorted by base address
+// (ties broken by shorter prefix first), calling emit for each result as soon
+// as it is final. It returns ErrUnsorted on out-of-order input.
+func SummarizeStream(in []CIDR, emit func(CIDR)) error {
+	s := NewSummarizer(emit)
+	for _, c := range in {
+		if err := s.Push(c); err != nil {
+			return err
+		}
+	}
+	s.Flush()
+	return nil
+}
+
+// prefix128 is a precomputed network for the Is* classifiers.
+type prefix128 struct {
+	base uint128
+	plen int
+}
+
+// mustPrefix builds a prefix128 at package init. It masks via uint128 rather
+// than NewCIDR because maskTable is not populated yet.
+func mustPrefix(s string) prefix128 {
+	parts := strings.Split(s, "/")
+	a, _, err := ParseAllowV4Mapped(parts[0])
+	if err != nil {
+		panic(err)
 	}
-	return stack
+	plen, err := parsePrefix(parts[1])
+	if err != nil {
+		panic(err)
+	}
+	return prefix128{a.u128().mask(plen), plen}
+}
+
+func (p prefix128) contains(a Address) bool { return a.u128().mask(p.plen) == p.base }
+
+var (
+	pfxGlobalUnicast = mustPrefix("2000::/3")
+	pfxLinkLocal     = mustPrefix("fe80::/10")
+	pfxUniqueLocal   = mustPrefix("fc00::/7")
+	pfxMulticast     = mustPrefix("ff00::/8")
+	pfxDocumentation = mustPrefix("2001:db8::/32")
+	pfxLoopback      = mustPrefix("::1/128")
+	pfxUnspecified   = mustPrefix("::/128")
+)
+
+// IsGlobalUnicast reports whether a is in the IANA global unicast block
+// 2000::/3 (which includes the documentation prefix).
+func (a Address) IsGlobalUnicast() bool { return pfxGlobalUnicast.contains(a) }
+
+// IsLinkLocal reports whether a is a link-local unicast address (fe80::/10).
+func (a Address) IsLinkLocal() bool { return pfxLinkLocal.contains(a) }
+
+// IsUniqueLocal reports whether a is a unique local address (fc00::/7).
+func (a Address) IsUniqueLocal() bool { return pfxUniqueLocal.contains(a) }
+
+// IsMulticast reports whether a is a multicast address (ff00::/8).
+func (a Address) IsMulticast() bool { return pfxMulticast.contains(a) }
+
+// IsLoopback reports whether a is the loopback address ::1.
+func (a Address) IsLoopback() bool { return pfxLoopback.contains(a) }
+
+// IsUnspecified reports whether a is the unspecified address ::.
+func (a Address) IsUnspecified() bool { return pfxUnspecified.contains(a) }
+
+// IsDocumentation reports whether a is in the documentation prefix
+// 2001:db8::/32 (RFC 3849).
+func (a Address) IsDocumentation() bool { return pfxDocumentation.contains(a) }
+
+// AddressType names a well-known address category. The values match the
+// filter command's flags.
+type AddressType string
+
+const (
+	TypeGlobalUnicast AddressType = "global-unicast"
+	TypeLinkLocal     AddressType = "link-local"
+	TypeUniqueLocal   AddressType = "unique-local"
+	TypeMulticast     AddressType = "multicast"
+	TypeLoopback      AddressType = "loopback"
+	TypeUnspecified   AddressType = "unspecified"
+	TypeDocumentation AddressType = "documentation"
+	// TypeUnclassified covers addresses in none of the other categories.
+	TypeUnclassified AddressType = "unclassified"
+)
+
+// addressTypes is the classification table in reporting order.
+var addressTypes = []struct {
+	t AddressType
+	p prefix128
+}{
+	{TypeGlobalUnicast, pfxGlobalUnicast},
+	{TypeLinkLocal, pfxLinkLocal},
+	{TypeUniqueLocal, pfxUniqueLocal},
+	{TypeMulticast, pfxMulticast},
+	{TypeLoopback, pfxLoopback},
+	{TypeUnspecified, pfxUnspecified},
+	{TypeDocumentation, pfxDocumentation},
+}
+
+// Network returns the prefix defining t. ok is false for TypeUnclassified and
+// unknown values.
+func (t AddressType) Network() (c CIDR, ok bool) {
+	for _, at := range addressTypes {
+		if at.t == t {
+			return CIDR{base: fromHiLo(at.p.base.hi, at.p.base.lo), plen: at.p.plen}, true
+		}
+	}
+	return CIDR{}, false
+}
+
+// Type returns the most specific category containing a, so a documentation
+// address is TypeDocumentation rather than TypeGlobalUnicast. It returns
+// TypeUnclassified when no category matches.
+func (a Address) Type() AddressType {
+	t, plen := TypeUnclassified, -1
+	for _, at := range addressTypes {
+		if at.p.plen > plen && at.p.contains(a) {
+			t, plen = at.t, at.p.plen
+		}
+	}
+	return t
+}
+
+// ClassifyCIDR returns every category containing at least one address of c,
+// in classification-table order. Categories nest (documentation lies inside
+// global unicast), so one address may contribute several. TypeUnclassified is
+// appended if part of c falls in no category.
+func ClassifyCIDR(c CIDR) []AddressType {
+	var res []AddressType
+	var covered []CIDR
+	for _, at := range addressTypes {
+		n, _ := at.t.Network()
+		if n.Overlaps(c) {
+			res = append(res, at.t)
+			covered = append(covered, n)
+		}
+	}
+	if len(Gaps(c, covered)) > 0 {
+		res = append(res, TypeUnclassified)
+	}
+	return res
+}
+
+// SpecialPurposeEntry is a row of the IANA IPv6 Special-Purpose Address
+// Registry.
+type SpecialPurposeEntry struct {
+	Network CIDR
+	Name    string
+	RFC     string
+}
+
+// specialPurpose is the registry table; add rows in any order, lookups pick
+// the longest matching prefix.
+var specialPurpose = []struct {
+	p         prefix128
+	name, rfc string
+}{
+	{mustPrefix("::1/128"), "Loopback Address", "RFC 4291"},
+	{mustPrefix("::/128"), "Unspecified Address", "RFC 4291"},
+	{mustPrefix("::ffff:0:0/96"), "IPv4-mapped Address", "RFC 4291"},
+	{mustPrefix("64:ff9b::/96"), "IPv4-IPv6 Translation", "RFC 6052"},
+	{mustPrefix("64:ff9b:1::/48"), "Local-Use IPv4/IPv6 Translation", "RFC 8215"},
+	{mustPrefix("100::/64"), "Discard-Only Address Block", "RFC 6666"},
+	{mustPrefix("2001::/23"), "IETF Protocol Assignments", "RFC 2928"},
+	{mustPrefix("2001::/32"), "TEREDO", "RFC 4380"},
+	{mustPrefix("2001:1::1/128"), "Port Control Protocol Anycast", "RFC 7723"},
+	{mustPrefix("2001:1::2/128"), "Traversal Using Relays around NAT Anycast", "RFC 8155"},
+	{mustPrefix("2001:2::/48"), "Benchmarking", "RFC 5180"},
+	{mustPrefix("2001:3::/32"), "AMT", "RFC 7450"},
+	{mustPrefix("2001:4:112::/48"), "AS112-v6", "RFC 7535"},
+	{mustPrefix("2001:10::/28"), "Deprecated (previously ORCHID)", "RFC 4843"},
+	{mustPrefix("2001:20::/28"), "ORCHIDv2", "RFC 7343"},
+	{mustPrefix("2001:30::/28"), "Drone Remote ID Protocol Entity Tags (DETs) Prefix", "RFC 9374"},
+	{mustPrefix("2001:db8::/32"), "Documentation", "RFC 3849"},
+	{mustPrefix("2002::/16"), "6to4", "RFC 3056"},
+	{mustPrefix("2620:4f:8000::/48"), "Direct Delegation AS112 Service", "RFC 7534"},
+	{mustPrefix("3fff::/20"), "Documentation", "RFC 9637"},
+	{mustPrefix("5f00::/16"), "Segment Routing (SRv6) SIDs", "RFC 9602"},
+	{mustPrefix("fc00::/7"), "Unique-Local", "RFC 4193"},
+	{mustPrefix("fe80::/10"), "Link-Local Unicast", "RFC 4291"},
+}
+
+// LookupSpecialPurpose returns the most specific special-purpose registry
+// entry containing a, so ::1/128 wins over a covering block.
+func LookupSpecialPurpose(a Address) (SpecialPurposeEntry, bool) {
+	best := -1
+	for i, row := range specialPurpose {
+		if row.p.contains(a) && (best < 0 || row.p.plen > specialPurpose[best].p.plen) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return SpecialPurposeEntry{}, false
+	}
+	row := specialPurpose[best]
+	return SpecialPurposeEntry{CIDR{base: fromHiLo(row.p.base.hi, row.p.base.lo), plen: row.p.plen}, row.name, row.rfc}, true
+}
+
+// SpecialPurpose returns the name of the most specific special-purpose
+// registry entry containing a.
+func SpecialPurpose(a Address) (entry string, ok bool) {
+	e, ok := LookupSpecialPurpose(a)
+	return e.Name, ok
+}
+
+// MulticastInfo is the decoded flags and scope of a multicast address
+// (RFC 4291 section 2.7).
+type MulticastInfo struct {
+	Flags      uint8 // raw 4-bit flags field (0RPT)
+	Transient  bool  // T: not a permanently assigned (well-known) address
+	Prefix     bool  // P: unicast-prefix-based (RFC 3306)
+	Rendezvous bool  // R: embedded rendezvous point (RFC 3956)
+	Scope      uint8
+	ScopeName  string
+}
+
+// multicastScopes names the 4-bit scope values (RFC 4291, RFC 7346).
+var multicastScopes = [16]string{
+	0x0: "reserved", 0x1: "interface-local", 0x2: "link-local", 0x3: "realm-local",
+	0x4: "admin-local", 0x5: "site-local", 0x6: "unassigned", 0x7: "unassigned",
+	0x8: "organization-local", 0x9: "unassigned", 0xa: "unassigned", 0xb: "unassigned",
+	0xc: "unassigned", 0xd: "unassigned", 0xe: "global", 0xf: "reserved",
+}
+
+// MulticastInfo decodes the flags and scope of a. It returns ErrNotMulticast
+// if a is outside ff00::/8.
+func (a Address) MulticastInfo() (MulticastInfo, error) {
+	if !a.IsMulticast() {
+		return MulticastInfo{}, fmt.Errorf("%w: %s", ErrNotMulticast, a)
+	}
+	flags, scope := a.ip[1]>>4, a.ip[1]&0x0f
+	return MulticastInfo{
+		Flags:      flags,
+		Transient:  flags&0x1 != 0,
+		Prefix:     flags&0x2 != 0,
+		Rendezvous: flags&0x4 != 0,
+		Scope:      scope,
+		ScopeName:  multicastScopes[scope],
+	}, nil
+}
+
+// SolicitedNodeMulticast returns the solicited-node multicast address of a
+// (RFC 4291 section 2.7.1): the low 24 bits of a appended to ff02::1:ff00:0/104.
+func SolicitedNodeMulticast(a Address) Address {
+	b := net.IP{0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff, 0, 0, 0}
+	copy(b[13:], a.ip[13:])
+	return Address{ip: b}
 }
 
 // ReverseDNS returns the ip6.arpa reverse mapping domain name.
@@ -479,6 +1600,72 @@ func (a Address) ReverseDNS() string {
 	return b.String()
 }
 
+// ReverseDNSRelative returns the reverse name of a relative to the ip6.arpa
+// zone of origin: only the nibbles past the origin's prefix, with no trailing
+// dot, as written in that zone's file ("@" when origin is a /128). origin must
+// end on a nibble boundary and contain a.
+func ReverseDNSRelative(a Address, origin CIDR) (string, error) {
+	if origin.plen%4 != 0 {
+		return "", fmt.Errorf("%w: /%d is not on a nibble boundary", ErrInvalidPrefix, origin.plen)
+	}
+	if !origin.ContainsAddress(a) {
+		return "", fmt.Errorf("%w: %s not in %s", ErrNotContained, a, origin)
+	}
+	host := 2*ByteLen - origin.plen/4 // labels below the zone
+	if host == 0 {
+		return "@", nil
+	}
+	return a.ReverseDNS()[:2*host-1], nil
+}
+
+// AddressFromReverseDNS inverts ReverseDNS: name must be exactly 32
+// single-hex-digit labels followed by ip6.arpa, with or without the trailing
+// dot. Letters may be in either case.
+func AddressFromReverseDNS(name string) (Address, error) {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(name), ".")
+	const suffix = ".ip6.arpa"
+	if len(trimmed) < len(suffix) || !strings.EqualFold(trimmed[len(trimmed)-len(suffix):], suffix) {
+		return Address{}, fmt.Errorf("%w: %q does not end in ip6.arpa", ErrInvalidAddress, name)
+	}
+	labels := strings.Split(trimmed[:len(trimmed)-len(suffix)], ".")
+	if len(labels) != 2*ByteLen {
+		return Address{}, fmt.Errorf("%w: %q has %d nibbles, want 32", ErrInvalidAddress, name, len(labels))
+	}
+	hexstr := make([]byte, 2*ByteLen)
+	for i, l := range labels {
+		if len(l) != 1 || !strings.ContainsRune("0123456789abcdefABCDEF", rune(l[0])) {
+			return Address{}, fmt.Errorf("%w: %q: label %q is not a hex nibble", ErrInvalidAddress, name, l)
+		}
+		hexstr[len(hexstr)-1-i] = l[0]
+	}
+	b, _ := hex.DecodeString(string(hexstr))
+	return Address{ip: b}, nil
+}
+
+// DottedNibble returns the 32 hex nibbles in forward order separated by dots,
+// without the reversal or ip6.arpa suffix of ReverseDNS.
+func (a Address) DottedNibble() string {
+	hexstr := hex.EncodeToString(a.ip)
+	var b strings.Builder
+	b.Grow(2*len(hexstr) - 1)
+	for i := 0; i < len(hexstr); i++ {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteByte(hexstr[i])
+	}
+	return b.String()
+}
+
+// Hash64 returns the 64-bit FNV-1a hash of the 16 address bytes (network
+// order). The algorithm is part of the API contract and will not change between
+// releases, so it is safe for persistent sharding or bucketing.
+func (a Address) Hash64() uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(a.ip.To16())
+	return h.Sum64()
+}
+
 // Offset adds an unsigned 64-bit offset (mod 2^128).
 func (a Address) Offset(u uint64) Address {
 	delta := new(big.Int).SetUint64(u)
@@ -513,46 +1700,311 @@ func Distance(a, b Address) *big.Int {
 	return new(big.Int).SetBytes(buf)
 }
 
+// CountRange returns the number of addresses in the inclusive range between a
+// and b. Argument order does not matter; CountRange(a, a) is 1.
+func CountRange(a, b Address) *big.Int {
+	d := Distance(a, b)
+	return d.Add(d, big.NewInt(1))
+}
+
 // CoverRange returns the minimal set of CIDRs covering the inclusive address range [start,end].
 func CoverRange(start, end Address) ([]CIDR, error) {
 	if start.Compare(end) > 0 {
-		return nil, errors.New("ipv6: invalid range")
+		return nil, fmt.Errorf("%w: %s after %s", ErrInvalidRange, start, end)
 	}
+	// walk the range twice in uint128 arithmetic, first to size the result,
+	// so the only allocations are the CIDRs and one array for their bases
+	first, last := start.u128(), end.u128()
+	stop := last.inc() // wraps to zero when the range ends on the all-ones address
+	n := 1
+	for cur := first; ; n++ {
+		if _, cur = coverStep(cur, last); cur == stop {
+			break
+		}
+	}
+	res := make([]CIDR, n)
+	ips := make([]byte, n*ByteLen)
+	cur := first
+	for i := range res {
+		b := ips[i*ByteLen : (i+1)*ByteLen : (i+1)*ByteLen]
+		binary.BigEndian.PutUint64(b, cur.hi)
+		binary.BigEndian.PutUint64(b[8:], cur.lo)
+		res[i].base = Address{ip: b}
+		res[i].plen, cur = coverStep(cur, last)
+	}
+	return res, nil
+}
+
+// CoverIterator streams the CIDRs of CoverRange one at a time, in the same
+// order, without building the slice.
+type CoverIterator struct {
+	cur, last, stop uint128
+	done            bool
+}
+
+// CoverRangeIterator returns an iterator over the minimal cover of the
+// inclusive range [start,end]. It yields nothing when start > end.
+func CoverRangeIterator(start, end Address) *CoverIterator {
+	last := end.u128()
+	return &CoverIterator{cur: start.u128(), last: last, stop: last.inc(), done: start.Compare(end) > 0}
+}
+
+// Next returns the next covering CIDR and true, or zero value and false when done.
+func (it *CoverIterator) Next() (CIDR, bool) {
+	if it.done {
+		return CIDR{}, false
+	}
+	c := CIDR{base: fromHiLo(it.cur.hi, it.cur.lo)}
+	c.plen, it.cur = coverStep(it.cur, it.last)
+	it.done = it.cur == it.stop // compared, not ordered: stop wraps to :: after the all-ones address
+	return c, true
+}
+
+// coverStep returns the prefix length of the largest CIDR that starts at cur
+// and ends at or before end, together with the address following that CIDR.
+func coverStep(cur, end uint128) (plen int, next uint128) {
+	// the CIDR is limited by cur's alignment...
+	k := 128
+	if cur.lo != 0 {
+		k = bits.TrailingZeros64(cur.lo)
+	} else if cur.hi != 0 {
+		k = 64 + bits.TrailingZeros64(cur.hi)
+	}
+	// ...and by floor(log2(end-cur+1)), the addresses remaining
+	remLo, borrow := bits.Sub64(end.lo, cur.lo, 0)
+	remHi, _ := bits.Sub64(end.hi, cur.hi, borrow)
+	remLo, carry := bits.Add64(remLo, 1, 0)
+	remHi, carry = bits.Add64(remHi, 0, carry)
+	remBits := 128
+	if carry == 0 {
+		remBits = 64 + bits.Len64(remHi) - 1
+		if remHi == 0 {
+			remBits = bits.Len64(remLo) - 1
+		}
+	}
+	k = min(k, remBits)
+	var size uint128 // 2^k; zero for k == 128, where next wraps to cur
+	if k < 64 {
+		size.lo = 1 << uint(k)
+	} else if k < 128 {
+		size.hi = 1 << uint(k-64)
+	}
+	next.lo, carry = bits.Add64(cur.lo, size.lo, 0)
+	next.hi, _ = bits.Add64(cur.hi, size.hi, carry)
+	return 128 - k, next
+}
+
+// CoverRangeMax returns a cover of [start,end] with at most limit CIDRs. When
+// the minimal cover is longer, adjacent CIDRs are repeatedly replaced by their
+// smallest common supernet, each time picking the merge that adds the fewest
+// addresses. The result then also covers addresses outside the range; extra
+// is how many.
+func CoverRangeMax(start, end Address, limit int) (cover []CIDR, extra *big.Int, err error) {
+	if limit < 1 {
+		return nil, nil, fmt.Errorf("%w: %d", ErrInvalidLimit, limit)
+	}
+	if cover, err = CoverRange(start, end); err != nil {
+		return nil, nil, err
+	}
+	size := func(list []CIDR) *big.Int {
+		n := new(big.Int)
+		for _, c := range list {
+			n.Add(n, c.HostCount())
+		}
+		return n
+	}
+	for len(cover) > limit {
+		var best CIDR
+		var bestLo, bestHi int
+		var bestCost *big.Int
+		for i := 0; i+1 < len(cover); i++ {
+			plen := CommonPrefixLen(cover[i].base, cover[i+1].LastHost())
+			sup := CIDR{base: cover[i].base.Mask(plen), plen: plen}
+			// the cover is sorted and disjoint, so sup swallows a contiguous run
+			lo, hi := i, i+1
+			for lo > 0 && sup.ContainsCIDR(cover[lo-1]) {
+				lo--
+			}
+			for hi+1 < len(cover) && sup.ContainsCIDR(cover[hi+1]) {
+				hi++
+			}
+			cost := new(big.Int).Sub(sup.HostCount(), size(cover[lo:hi+1]))
+			if bestCost == nil || cost.Cmp(bestCost) < 0 {
+				best, bestLo, bestHi, bestCost = sup, lo, hi, cost
+			}
+		}
+		cover = append(append(append([]CIDR{}, cover[:bestLo]...), best), cover[bestHi+1:]...)
+	}
+	extra = size(cover)
+	return cover, extra.Sub(extra, CountRange(start, end)), nil
+}
+
+// CoveringCIDRs returns the routes that contain all of target, target itself
+// included, most specific first. Equal prefix lengths keep input order.
+func CoveringCIDRs(routes []CIDR, target CIDR) []CIDR {
+	var res []CIDR
+	for _, r := range routes {
+		if r.ContainsCIDR(target) {
+			res = append(res, r.Canonical())
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].plen > res[j].plen })
+	return res
+}
+
+// SubnetsOf returns the routes lying entirely inside parent, parent itself
+// included, ordered by base address and then by prefix length.
+func SubnetsOf(routes []CIDR, parent CIDR) []CIDR {
 	var res []CIDR
-	cur := start
+	for _, r := range routes {
+		if parent.ContainsCIDR(r) {
+			res = append(res, r.Canonical())
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if c := res[i].base.Compare(res[j].base); c != 0 {
+			return c < 0
+		}
+		return res[i].plen < res[j].plen
+	})
+	return res
+}
+
+// IsMinimalCover reports whether cidrs, in any order, partition [start,end]
+// exactly, without gaps or overlap, and no two of them are siblings that could
+// merge into their parent. Such a cover is unique and equals CoverRange's.
+func IsMinimalCover(start, end Address, cidrs []CIDR) bool {
+	sorted, ok := partition(start, end, cidrs)
+	if !ok {
+		return false
+	}
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1].SharesParentWith(sorted[i]) {
+			return false // redundant split
+		}
+	}
+	return true
+}
+
+// IsExactCover reports whether parts, in any order, tile parent exactly:
+// every address of parent lies in exactly one part and none lies outside.
+func IsExactCover(parent CIDR, parts []CIDR) bool {
+	_, ok := partition(parent.FirstHost(), parent.LastHost(), parts)
+	return ok
+}
+
+// partition returns cidrs sorted by base if they cover [start,end] with no gap
+// or overlap.
+func partition(start, end Address, cidrs []CIDR) ([]CIDR, bool) {
+	if len(cidrs) == 0 || start.Compare(end) > 0 {
+		return nil, false
+	}
+	sorted := append([]CIDR(nil), cidrs...)
+	for _, c := range sorted {
+		if c.base.ip == nil || !c.Equal(c.Canonical()) {
+			return nil, false
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].base.Compare(sorted[j].base) < 0 })
+	if sorted[0].FirstHost().Compare(start) != 0 || sorted[len(sorted)-1].LastHost().Compare(end) != 0 {
+		return nil, false
+	}
 	one := big.NewInt(1)
-	for cur.Compare(end) <= 0 {
-		rem := new(big.Int).Add(Distance(cur, end), one) // remaining count
-		// count trailing zero bits of current address
-		hi, lo := cur.hiLo()
-		var tz int
-		if lo != 0 {
-			tz = bits.TrailingZeros64(lo)
-		} else if hi != 0 {
-			tz = 64 + bits.TrailingZeros64(hi)
-		} else {
-			tz = 128
+	for i := 1; i < len(sorted); i++ {
+		last := sorted[i-1].LastHost()
+		if next := sorted[i].FirstHost(); last.Compare(next) >= 0 || last.Add(one).Compare(next) != 0 {
+			return nil, false // overlap or gap
 		}
-		// largest exponent allowed by remaining size
-		remBits := rem.BitLen() - 1 // floor(log2(rem))
-		if remBits < 0 {
-			remBits = 0
+	}
+	return sorted, true
+}
+
+// DedupCIDRs returns cidrs without repeats, keeping the first occurrence of
+// each canonical network in input order. Overlapping but unequal networks are
+// all kept; use Summarize to merge them.
+func DedupCIDRs(cidrs []CIDR) []CIDR {
+	type key struct {
+		b    [16]byte
+		plen int
+	}
+	seen := make(map[key]bool, len(cidrs))
+	res := make([]CIDR, 0, len(cidrs))
+	for _, c := range cidrs {
+		c = c.Canonical()
+		k := key{c.base.As16(), c.plen}
+		if seen[k] {
+			continue
 		}
-		if tz > remBits {
-			tz = remBits
+		seen[k] = true
+		res = append(res, c)
+	}
+	return res
+}
+
+// PrefixGroup is a network and the addresses of a list that fall in it.
+type PrefixGroup struct {
+	Network CIDR
+	Members []Address
+}
+
+// GroupByPrefix buckets addrs by their network at the given prefix length.
+// Groups are ordered by network and members by value; repeats are kept.
+func GroupByPrefix(addrs []Address, prefix int) ([]PrefixGroup, error) {
+	if prefix < 0 || prefix > 128 {
+		return nil, ErrInvalidPrefix
+	}
+	sorted := append([]Address(nil), addrs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Compare(sorted[j]) < 0 })
+	var groups []PrefixGroup
+	for _, a := range sorted {
+		// sorting keeps each network's members contiguous
+		if n := len(groups); n > 0 && groups[n-1].Network.ContainsAddress(a) {
+			groups[n-1].Members = append(groups[n-1].Members, a)
+			continue
 		}
-		prefix := 128 - tz
-		cid, _ := NewCIDR(cur, prefix)
-		res = append(res, cid)
-		cur = cid.LastHost().Add(one)
+		groups = append(groups, PrefixGroup{Network: CIDR{base: a.Mask(prefix), plen: prefix}, Members: []Address{a}})
 	}
-	return res, nil
+	return groups, nil
 }
 
-// Supernet returns the smallest CIDR containing all provided CIDRs.
+// PrefixHistogram counts cidrs by prefix length. Repeats are counted each
+// time; an empty list yields an empty map.
+func PrefixHistogram(cidrs []CIDR) map[int]int {
+	h := make(map[int]int)
+	for _, c := range cidrs {
+		h[c.plen]++
+	}
+	return h
+}
+
+// DedupAddresses returns addrs without repeats, keeping the first occurrence
+// of each address in input order.
+func DedupAddresses(addrs []Address) []Address {
+	seen := make(map[[16]byte]bool, len(addrs))
+	res := make([]Address, 0, len(addrs))
+	for _, a := range addrs {
+		k := a.As16()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		res = append(res, a)
+	}
+	return res
+}
+
+// Supernet returns the smallest CIDR containing all provided CIDRs: the input
+// itself for a single CIDR and ::/0 when the inputs reach both :: and the
+// all-ones address. It returns ErrEmptyList for no input and ErrInvalidCIDR
+// for a zero-value CIDR.
 func Supernet(list []CIDR) (CIDR, error) {
 	if len(list) == 0 {
-		return CIDR{}, errors.New("ipv6: empty list")
+		return CIDR{}, ErrEmptyList
+	}
+	for i, c := range list {
+		if c.base.ip == nil {
+			return CIDR{}, fmt.Errorf("%w: zero-value CIDR at index %d", ErrInvalidCIDR, i)
+		}
 	}
 	min := list[0].FirstHost()
 	max := list[0].LastHost()
@@ -564,9 +2016,15 @@ func Supernet(list []CIDR) (CIDR, error) {
 			max = c.LastHost()
 		}
 	}
-	// find common prefix bits of min & max
-	mb := min.ip
-	xb := max.ip
+	prefix := CommonPrefixLen(min, max)
+	return NewCIDR(min.Mask(prefix), prefix)
+}
+
+// CommonPrefixLen returns the number of leading bits a and b share: 128 for
+// identical addresses, 0 when the top bits differ.
+func CommonPrefixLen(a, b Address) int {
+	mb := a.ip
+	xb := b.ip
 	prefix := 0
 	for i := 0; i < 16; i++ {
 		if mb[i] == xb[i] {
@@ -584,7 +2042,85 @@ func Supernet(list []CIDR) (CIDR, error) {
 		}
 		break
 	}
-	return NewCIDR(min.Mask(prefix), prefix)
+	return prefix
+}
+
+// Gaps returns the minimal CIDRs covering the space inside parent that none of
+// used covers, in ascending order. Gaps before the first and after the last
+// allocation are included; allocations outside parent are ignored and ones
+// larger than parent are clipped to it.
+func Gaps(parent CIDR, used []CIDR) []CIDR {
+	type span struct{ start, end Address }
+	pStart, pEnd := parent.FirstHost(), parent.LastHost()
+	spans := make([]span, 0, len(used))
+	for _, u := range used {
+		if !parent.Overlaps(u) {
+			continue
+		}
+		s, e := u.FirstHost(), u.LastHost()
+		if s.Compare(pStart) < 0 {
+			s = pStart
+		}
+		if e.Compare(pEnd) > 0 {
+			e = pEnd
+		}
+		spans = append(spans, span{s, e})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Compare(spans[j].start) < 0 })
+	var res []CIDR
+	one := big.NewInt(1)
+	cur := pStart
+	for _, sp := range spans {
+		if sp.start.Compare(cur) > 0 {
+			cover, _ := CoverRange(cur, sp.start.Sub(one))
+			res = append(res, cover...)
+		}
+		if sp.end.Compare(cur) >= 0 {
+			if sp.end.Compare(pEnd) == 0 { // parent exhausted; avoid wrapping past its end
+				return res
+			}
+			cur = sp.end.Add(one)
+		}
+	}
+	cover, _ := CoverRange(cur, pEnd)
+	return append(res, cover...)
+}
+
+// PlanVLSM allocates one aligned, non-overlapping subnet inside parent for
+// each host-count requirement and returns them in request order. Requests are
+// placed largest first (ties in request order), which keeps every block
+// naturally aligned and packs them without fragmentation. It returns an error
+// wrapping ErrNoSpace if the requests do not fit.
+func PlanVLSM(parent CIDR, requests []*big.Int) ([]CIDR, error) {
+	prefixes := make([]int, len(requests))
+	for i, r := range requests {
+		if r == nil || r.Sign() < 0 {
+			return nil, fmt.Errorf("ipv6: invalid host requirement at index %d", i)
+		}
+		p, err := parent.SubnetForHosts(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: request %d (%s hosts) exceeds %s", ErrNoSpace, i, r, parent)
+		}
+		prefixes[i] = p
+	}
+	order := make([]int, len(requests))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return prefixes[order[a]] < prefixes[order[b]] })
+	res := make([]CIDR, len(requests))
+	offset := new(big.Int)
+	total := parent.HostCount()
+	for _, i := range order {
+		size := new(big.Int).Lsh(big.NewInt(1), uint(BitLen-prefixes[i]))
+		end := new(big.Int).Add(offset, size)
+		if end.Cmp(total) > 0 {
+			return nil, fmt.Errorf("%w: %d requests need more than %s", ErrNoSpace, len(requests), parent)
+		}
+		res[i] = CIDR{base: parent.base.Add(offset), plen: prefixes[i]}
+		offset = end
+	}
+	return res, nil
 }
 
 // Random utilities
@@ -603,6 +2139,30 @@ func RandomAddressInCIDR(c CIDR, r *rand.Rand) Address {
 	return addr
 }
 
+// RandomAddressExcluding returns a uniform random address of c outside every
+// reserved CIDR. It samples from the free space Gaps computes rather than
+// retrying, so it terminates however densely c is reserved, and returns
+// ErrNoSpace when nothing is free.
+func RandomAddressExcluding(c CIDR, reserved []CIDR, r *rand.Rand) (Address, error) {
+	free := Gaps(c, reserved)
+	total := new(big.Int)
+	for _, f := range free {
+		total.Add(total, f.HostCount())
+	}
+	if total.Sign() == 0 {
+		return Address{}, fmt.Errorf("%w: %s is fully reserved", ErrNoSpace, c)
+	}
+	x := new(big.Int).Rand(r, total)
+	for _, f := range free {
+		n := f.HostCount()
+		if x.Cmp(n) < 0 {
+			return f.base.Add(x), nil
+		}
+		x.Sub(x, n)
+	}
+	panic("unreachable: offset exceeds free space")
+}
+
 // RandomSubnetInCIDR returns a random subnet of newPrefix inside c.
 func RandomSubnetInCIDR(c CIDR, newPrefix int, r *rand.Rand) (CIDR, error) {
 	if newPrefix < c.plen || newPrefix > 128 {
@@ -619,6 +2179,250 @@ func RandomSubnetInCIDR(c CIDR, newPrefix int, r *rand.Rand) (CIDR, error) {
 	return NewCIDR(base, newPrefix)
 }
 
+// RandomSubnetWeighted returns a random subnet of newPrefix inside c, picking
+// subnet idx (counted from the start of c) with probability proportional to
+// weights(idx). Weights must be finite and non-negative with a positive sum;
+// the subnets are enumerated, so c must split within MaxSplitParts.
+func RandomSubnetWeighted(c CIDR, newPrefix int, weights func(idx uint64) float64, r *rand.Rand) (CIDR, error) {
+	list, err := RandomSubnetsWeighted(c, newPrefix, 1, weights, r)
+	if err != nil {
+		return CIDR{}, err
+	}
+	return list[0], nil
+}
+
+// RandomSubnetsWeighted is RandomSubnetWeighted drawing n subnets. The weights
+// are evaluated once, however large n is.
+func RandomSubnetsWeighted(c CIDR, newPrefix, n int, weights func(idx uint64) float64, r *rand.Rand) ([]CIDR, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidLimit, n)
+	}
+	if newPrefix < c.plen || newPrefix > 128 {
+		return nil, ErrInvalidSplitPrefix
+	}
+	res := make([]CIDR, n)
+	if newPrefix == c.plen {
+		for i := range res {
+			res[i] = c
+		}
+		return res, nil
+	}
+	parts, err := c.splitParts(newPrefix)
+	if err != nil {
+		return nil, err
+	}
+	cum := make([]float64, parts)
+	total := 0.0
+	for i := uint64(0); i < parts; i++ {
+		w := weights(i)
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("ipv6: invalid weight %v for subnet %d", w, i)
+		}
+		total += w
+		cum[i] = total
+	}
+	if total == 0 {
+		return nil, errors.New("ipv6: subnet weights sum to zero")
+	}
+	step := new(big.Int).Rsh(c.HostCount(), uint(newPrefix-c.plen))
+	for k := range res {
+		x := r.Float64() * total
+		idx := uint64(sort.Search(len(cum), func(i int) bool { return cum[i] > x }))
+		if idx == parts { // x rounded up to total: take the last weighted subnet
+			idx = uint64(sort.Search(len(cum), func(i int) bool { return cum[i] >= total }))
+		}
+		if res[k], err = NewCIDR(c.base.Add(new(big.Int).Mul(new(big.Int).SetUint64(idx), step)), newPrefix); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// GenerateULA returns a random RFC 4193 unique local /48 (fd00::/8, L bit
+// set) with a 40-bit global ID read from r. A nil r uses crypto/rand.
+func GenerateULA(r io.Reader) (CIDR, error) {
+	if r == nil {
+		r = crand.Reader
+	}
+	var gid [5]byte
+	if _, err := io.ReadFull(r, gid[:]); err != nil {
+		return CIDR{}, err
+	}
+	return ulaPrefix(gid), nil
+}
+
+// GenerateULAFromMAC derives the global ID with the RFC 4193 section 3.2.2
+// algorithm: the low 40 bits of SHA-1 over the 64-bit NTP form of t followed
+// by the modified EUI-64 interface identifier of mac (RFC 4291 appendix A: a
+// 48-bit MAC is expanded with ff:fe and its universal/local bit inverted). An
+// 8-byte mac is taken as an interface identifier already in that form.
+func GenerateULAFromMAC(mac net.HardwareAddr, t time.Time) (CIDR, error) {
+	var eui [8]byte
+	switch len(mac) {
+	case 6:
+		copy(eui[:3], mac[:3])
+		eui[3], eui[4] = 0xff, 0xfe
+		copy(eui[5:], mac[3:])
+		eui[0] ^= 0x02 // universal/local bit
+	case 8:
+		copy(eui[:], mac)
+	default:
+		return CIDR{}, fmt.Errorf("ipv6: unsupported hardware address length %d", len(mac))
+	}
+	// NTP timestamp: seconds since 1900 and a 32-bit binary fraction
+	const ntpEpochOffset = 2208988800
+	var buf [16]byte
+	binary.BigEndian.PutUint32(buf[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(buf[4:8], uint32((uint64(t.Nanosecond())<<32)/1e9))
+	copy(buf[8:], eui[:])
+	sum := sha1.Sum(buf[:])
+	var gid [5]byte
+	copy(gid[:], sum[len(sum)-5:])
+	return ulaPrefix(gid), nil
+}
+
+// ParseULA splits a unique local address (fc00::/7) into its 40-bit global
+// ID (bits 8-47) and 16-bit subnet ID (bits 48-63); the remaining 64 bits are
+// the interface ID. It returns ErrNotULA for other addresses.
+func ParseULA(a Address) (globalID uint64, subnetID uint16, err error) {
+	if !a.IsUniqueLocal() {
+		return 0, 0, fmt.Errorf("%w: %s", ErrNotULA, a)
+	}
+	hi, _ := a.hiLo()
+	return (hi >> 16) & (1<<40 - 1), uint16(hi), nil
+}
+
+func ulaPrefix(gid [5]byte) CIDR {
+	b := make(net.IP, ByteLen)
+	b[0] = 0xfd
+	copy(b[1:6], gid[:])
+	return CIDR{base: Address{ip: b}, plen: 48}
+}
+
+// StablePrivacyIID returns the RFC 7217 stable, semantically opaque address
+// for prefix on interface netIface: the host bits are taken from SHA-256 over
+// the prefix, the interface name, the DAD counter and secret. The same inputs
+// always give the same address. Interface IDs reserved by RFC 5453 are skipped
+// by moving on to the next counter, as the RFC prescribes for DAD conflicts.
+// The prefix must be at most /64 and the secret non-empty.
+func StablePrivacyIID(prefix CIDR, netIface string, secret []byte, counter int) (Address, error) {
+	if prefix.plen > 64 {
+		return Address{}, fmt.Errorf("%w: /%d leaves no 64-bit interface ID", ErrInvalidPrefix, prefix.plen)
+	}
+	if len(secret) == 0 {
+		return Address{}, errors.New("ipv6: empty secret key")
+	}
+	if counter < 0 {
+		return Address{}, fmt.Errorf("ipv6: negative DAD counter %d", counter)
+	}
+	net16 := prefix.base.As16()
+	for ; ; counter++ {
+		h := sha256.New()
+		h.Write(net16[:])
+		// length-prefix the name so (name, counter) pairs cannot collide
+		_ = binary.Write(h, binary.BigEndian, uint32(len(netIface)))
+		h.Write([]byte(netIface))
+		_ = binary.Write(h, binary.BigEndian, uint32(counter))
+		h.Write(secret)
+		sum := h.Sum(nil)
+		hi, lo := prefix.base.hiLo()
+		rhi, rlo := binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16])
+		if prefix.plen < 64 {
+			hi |= rhi & (1<<(64-prefix.plen) - 1)
+		}
+		lo = rlo
+		if !reservedIID(lo) {
+			return fromHiLo(hi, lo), nil
+		}
+	}
+}
+
+// AnonymizeAddress keeps the first keepPrefix bits of a and replaces the rest
+// for privacy-preserving logs. Mode "zero" clears them, which is the same as
+// a.Mask(keepPrefix); mode "hash" fills them from HMAC-SHA256 of the whole
+// address under key, so equal inputs map to equal outputs for a given key and
+// distinct hosts stay distinguishable without revealing their bits. key is
+// required by, and only used in, hash mode.
+func AnonymizeAddress(a Address, keepPrefix int, mode string, key []byte) (Address, error) {
+	if keepPrefix < 0 || keepPrefix > BitLen {
+		return Address{}, fmt.Errorf("%w: %d", ErrInvalidPrefix, keepPrefix)
+	}
+	a16 := a.As16()
+	tail := make([]byte, ByteLen)
+	switch mode {
+	case "zero":
+	case "hash":
+		if len(key) == 0 {
+			return Address{}, errors.New("ipv6: empty anonymization key")
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(a16[:])
+		tail = mac.Sum(nil)
+	default:
+		return Address{}, fmt.Errorf("ipv6: unknown anonymization mode %q (want zero|hash)", mode)
+	}
+	m := maskTable[keepPrefix]
+	b := make([]byte, ByteLen)
+	for i := range b {
+		b[i] = a16[i]&m[i] | tail[i]&^m[i]
+	}
+	return Address{ip: b}, nil
+}
+
+// Rewrite renumbers a from prefix from to prefix to: when from contains a,
+// the first to.PrefixLength() bits are replaced by to's and every bit past
+// them is kept, so host bits survive. to must be at least as long as from
+// (bits between the two lengths come from to). It reports false, returning
+// a unchanged, when a is outside from or to is shorter than from.
+func Rewrite(a Address, from, to CIDR) (Address, bool) {
+	if to.plen < from.plen || !from.ContainsAddress(a) {
+		return a, false
+	}
+	m := maskTable[to.plen]
+	a16, t16 := a.As16(), to.base.As16()
+	b := make([]byte, ByteLen)
+	for i := range b {
+		b[i] = t16[i]&m[i] | a16[i]&^m[i]
+	}
+	return Address{ip: b}, true
+}
+
+// RenumberTo maps c onto newBase, which must have the same prefix length. The
+// result is newBase in canonical form; use RenumberCIDRs to carry networks
+// inside c along with it.
+func (c CIDR) RenumberTo(newBase CIDR) (CIDR, error) {
+	if c.plen != newBase.plen {
+		return CIDR{}, fmt.Errorf("%w: cannot renumber /%d onto /%d", ErrInvalidPrefix, c.plen, newBase.plen)
+	}
+	return newBase.Canonical(), nil
+}
+
+// RenumberCIDRs moves every network in cidrs from block from to block to,
+// keeping its prefix length and its offset within the block, so relative
+// positions are preserved. from and to must have the same prefix length and
+// each network must lie inside from.
+func RenumberCIDRs(cidrs []CIDR, from, to CIDR) ([]CIDR, error) {
+	if _, err := from.RenumberTo(to); err != nil {
+		return nil, err
+	}
+	res := make([]CIDR, len(cidrs))
+	for i, c := range cidrs {
+		if !from.ContainsCIDR(c) {
+			return nil, fmt.Errorf("%w: %s not in %s", ErrNotContained, c, from)
+		}
+		base, _ := Rewrite(c.base, from, to)
+		res[i] = CIDR{base: base, plen: c.plen}
+	}
+	return res, nil
+}
+
+// reservedIID reports whether iid is reserved by RFC 5453: the subnet-router
+// anycast ID, the 0200:5eff:fe00:0/104 range, or the 128 subnet anycast IDs
+// fdff:ffff:ffff:ff80-fdff:ffff:ffff:ffff.
+func reservedIID(iid uint64) bool {
+	return iid == 0 || iid>>24 == 0x02005efffe || iid>>7 == 0xfdffffffffffff80>>7
+}
+
 // ExampleParse demonstrates parsing an IPv6 address.
 func ExampleParse() {
 	addr, _ := Parse("2001:db8::1")
@@ -662,6 +2466,18 @@ func ExampleSupernet() {
 	// Output: 2001:db8::/64
 }
 
+// ExampleGaps lists unallocated space inside a parent block.
+func ExampleGaps() {
+	parent, _ := ParseCIDR("2001:db8::/126")
+	used, _ := ParseCIDR("2001:db8::1/128")
+	for _, g := range Gaps(parent, []CIDR{used}) {
+		fmt.Println(g)
+	}
+	// Output:
+	// 2001:db8::/128
+	// 2001:db8::2/127
+}
+
 // ExampleAddress_Expanded demonstrates uppercase expansion.
 func ExampleAddress_Expanded() {
 	addr, _ := Parse("2001:db8::1")
@@ -720,6 +2536,40 @@ func ExampleCIDR_SubnetIterator() {
 	// 2001:db8::2/127
 }
 
+// ExampleCIDR_HostIterator demonstrates streaming usable hosts.
+func ExampleCIDR_HostIterator() {
+	c, _ := ParseCIDR("2001:db8::/127")
+	it := c.HostIterator(true) // no-op for /127
+	for {
+		a, ok := it.Next()
+		if !ok {
+			break
+		}
+		fmt.Println(a)
+	}
+	// Output:
+	// 2001:db8::
+	// 2001:db8::1
+}
+
+// ExampleRangeIterator demonstrates walking an arbitrary address range.
+func ExampleRangeIterator() {
+	a, _ := Parse("2001:db8::ff")
+	b, _ := Parse("2001:db8::101")
+	it := RangeIterator(a, b)
+	for {
+		addr, ok := it.Next()
+		if !ok {
+			break
+		}
+		fmt.Println(addr)
+	}
+	// Output:
+	// 2001:db8::ff
+	// 2001:db8::100
+	// 2001:db8::101
+}
+
 // ExampleCIDR_NextPrev shows adjacent network navigation.
 func ExampleCIDR_NextPrev() {
 	c, _ := ParseCIDR("2001:db8::/64")
@@ -1,10 +1,18 @@
 package ipv6
 
 import (
+	"bytes"
+	"errors"
+	"math"
 	"math/big"
+	"math/bits"
+	"math/rand"
 	"net"
+	"slices"
+	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 )
 
 func TestParseAndFormat(t *testing.T) {
@@ -18,6 +26,12 @@ func TestParseAndFormat(t *testing.T) {
 	if addr.Expanded() != "2001:0db8:0000:0000:0000:0000:0000:0001" {
 		t.Fatalf("expanded mismatch: %s", addr.Expanded())
 	}
+	if got := addr.ExpandedSep("-"); got != "2001-0db8-0000-0000-0000-0000-0000-0001" {
+		t.Fatalf("ExpandedSep(-) = %s", got)
+	}
+	if got := addr.ExpandedSep(""); got != "20010db8000000000000000000000001" {
+		t.Fatalf("ExpandedSep() = %s", got)
+	}
 }
 
 func TestCIDR(t *testing.T) {
@@ -62,6 +76,27 @@ func TestSummarize(t *testing.T) {
 	}
 }
 
+func TestSummarizeDuplicates(t *testing.T) {
+	var uniq []CIDR
+	for _, s := range []string{"2001:db8::/65", "2001:db8:0:0:8000::/65", "2001:db8:1::/48", "2001:db8:1:2::/64", "2001:db9::/32"} {
+		c, _ := ParseCIDR(s)
+		uniq = append(uniq, c)
+	}
+	var dup []CIDR
+	for i := 0; i < 200; i++ {
+		dup = append(dup, uniq[i%len(uniq)])
+	}
+	want := Summarize(uniq)
+	if got := Summarize(dup); !slices.EqualFunc(got, want, CIDR.Equal) {
+		t.Fatalf("Summarize with duplicates = %v, want %v", got, want)
+	}
+	// duplicates are dropped before merging, so they add no allocations
+	base := testing.AllocsPerRun(20, func() { Summarize(uniq) })
+	if n := testing.AllocsPerRun(20, func() { Summarize(dup) }); n > base {
+		t.Fatalf("Summarize allocated %v times for duplicate input, %v for unique", n, base)
+	}
+}
+
 func TestReverse(t *testing.T) {
 	addr, _ := Parse("2001:db8::1")
 	rev := addr.ReverseDNS()
@@ -70,6 +105,57 @@ func TestReverse(t *testing.T) {
 	}
 }
 
+func TestAddressFromReverseDNS(t *testing.T) {
+	addr, _ := Parse("2001:db8::abcd:1")
+	for _, name := range []string{addr.ReverseDNS(), strings.TrimSuffix(addr.ReverseDNS(), "."), strings.ToUpper(addr.ReverseDNS())} {
+		got, err := AddressFromReverseDNS(name)
+		if err != nil || got.Compare(addr) != 0 {
+			t.Fatalf("AddressFromReverseDNS(%q) = %v %v", name, got, err)
+		}
+	}
+	rev := addr.ReverseDNS()
+	for _, bad := range []string{
+		"",
+		"ip6.arpa.",
+		strings.TrimSuffix(rev, "ip6.arpa.") + "in-addr.arpa.", // wrong suffix
+		rev[2:],                            // 31 nibbles
+		"0." + rev,                         // 33 nibbles
+		"g" + rev[1:],                      // not hex
+		"10." + rev[4:],                    // two-digit label
+		strings.Replace(rev, "1.", ".", 1), // empty label
+	} {
+		if _, err := AddressFromReverseDNS(bad); !errors.Is(err, ErrInvalidAddress) {
+			t.Fatalf("AddressFromReverseDNS(%q) error = %v", bad, err)
+		}
+	}
+}
+
+func TestReverseDNSRelative(t *testing.T) {
+	addr, _ := Parse("2001:db8::abcd:1")
+	for _, tc := range []struct {
+		origin, want string
+	}{
+		{"2001:db8::/32", "1.0.0.0.d.c.b.a.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0"},
+		{"2001:db8::/64", "1.0.0.0.d.c.b.a.0.0.0.0.0.0.0.0"},
+		{"2001:db8::abcd:0/124", "1"},
+		{"2001:db8::abcd:1/128", "@"},
+		{"::/0", strings.TrimSuffix(addr.ReverseDNS(), ".ip6.arpa.")},
+	} {
+		origin, _ := ParseCIDR(tc.origin)
+		if got, err := ReverseDNSRelative(addr, origin); err != nil || got != tc.want {
+			t.Fatalf("ReverseDNSRelative(%s) = %q %v, want %q", tc.origin, got, err, tc.want)
+		}
+	}
+	odd, _ := ParseCIDR("2001:db8::/33")
+	if _, err := ReverseDNSRelative(addr, odd); !errors.Is(err, ErrInvalidPrefix) {
+		t.Fatalf("non-nibble origin error = %v", err)
+	}
+	other, _ := ParseCIDR("2001:db9::/32")
+	if _, err := ReverseDNSRelative(addr, other); !errors.Is(err, ErrNotContained) {
+		t.Fatalf("outside origin error = %v", err)
+	}
+}
+
 func TestQuickParseExpand(t *testing.T) {
 	f := func(high, low uint64) bool {
 		// construct address
@@ -177,6 +263,33 @@ func TestAddNegativeDelta(t *testing.T) {
 	}
 }
 
+func TestHostCIDR(t *testing.T) {
+	h, err := ParseHostCIDR("2001:db8::1/64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.String() != "2001:db8::1/64" || h.Host().String() != "2001:db8::1" {
+		t.Fatalf("ParseHostCIDR = %s host %s", h, h.Host())
+	}
+	// everything but String works on the masked network
+	if h.Network().String() != "2001:db8::" || h.CIDR.String() != "2001:db8::/64" || h.Next().String() != "2001:db8:0:1::/64" {
+		t.Fatalf("network %s, CIDR %s, next %s", h.Network(), h.CIDR, h.Next())
+	}
+	other, _ := Parse("2001:db8::ffff")
+	if !h.ContainsAddress(other) || h.HostCount().Cmp(new(big.Int).Lsh(big.NewInt(1), 64)) != 0 {
+		t.Fatal("containment or size not taken from the network")
+	}
+	c, _ := ParseCIDR("2001:db8::/64")
+	w, err := c.WithHost(other)
+	if err != nil || w.String() != "2001:db8::ffff/64" || !w.CIDR.Equal(c) {
+		t.Fatalf("WithHost = %s %v", w, err)
+	}
+	outside, _ := Parse("2001:db9::1")
+	if _, err := c.WithHost(outside); !errors.Is(err, ErrNotContained) {
+		t.Fatalf("WithHost outside error = %v", err)
+	}
+}
+
 func TestSplitEquality(t *testing.T) {
 	c, _ := ParseCIDR("2001:db8::/64")
 	subs, err := c.Split(64)
@@ -197,6 +310,45 @@ func TestSplitEquality(t *testing.T) {
 	}
 }
 
+func TestSubnetIteratorAtPeek(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/48")
+	it, err := c.SubnetIterator(52)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs, _ := c.Split(52)
+	if it.Len() != uint64(len(subs)) {
+		t.Fatalf("Len = %d, want %d", it.Len(), len(subs))
+	}
+	first, _ := it.Next()
+	for i, want := range subs {
+		if got, err := it.At(uint64(i)); err != nil || !got.Equal(want) {
+			t.Fatalf("At(%d) = %v %v, want %v", i, got, err, want)
+		}
+	}
+	if _, err := it.At(it.Len()); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Fatalf("At(Len) error = %v", err)
+	}
+	// At and Peek leave the cursor where Next put it
+	peek, ok := it.Peek()
+	if !ok || !peek.Equal(subs[1]) || !first.Equal(subs[0]) {
+		t.Fatalf("Peek = %v %v after %v", peek, ok, first)
+	}
+	n := 1
+	for sub, ok := it.Next(); ok; sub, ok = it.Next() {
+		if !sub.Equal(subs[n]) {
+			t.Fatalf("Next #%d = %v, want %v", n, sub, subs[n])
+		}
+		n++
+	}
+	if _, ok := it.Peek(); ok || n != len(subs) {
+		t.Fatalf("exhausted iterator: Peek ok=%v after %d subnets", ok, n)
+	}
+	if got, err := it.At(3); err != nil || !got.Equal(subs[3]) {
+		t.Fatalf("At(3) after exhausting = %v %v", got, err)
+	}
+}
+
 func TestMaskInvalidPanics(t *testing.T) {
 	addr, _ := Parse("::1")
 	defer func() {
@@ -217,92 +369,2027 @@ func TestSplitCap(t *testing.T) {
 	}
 }
 
-// Fuzz tests (merged from fuzz_test.go)
-func FuzzParse(f *testing.F) {
-	seeds := []string{"::1", "2001:db8::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}
-	for _, s := range seeds {
-		f.Add(s)
+func TestHostIterator(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/126")
+	var got []string
+	it := c.HostIterator(true)
+	for {
+		a, ok := it.Next()
+		if !ok {
+			break
+		}
+		got = append(got, a.String())
 	}
-	f.Fuzz(func(t *testing.T, in string) {
-		addr, err := Parse(in)
-		if err != nil {
-			return
+	if len(got) != 3 || got[0] != "2001:db8::1" || got[2] != "2001:db8::3" {
+		t.Fatalf("unexpected usable hosts: %v", got)
+	}
+	for _, s := range []string{"2001:db8::/127", "2001:db8::/128"} {
+		c, _ := ParseCIDR(s)
+		first, ok := c.HostIterator(true).Next()
+		if !ok || first.Compare(c.Base()) != 0 {
+			t.Fatalf("skipAnycast should be a no-op for %s, got %v", s, first)
 		}
-		p2, err := Parse(addr.String())
-		if err != nil {
-			t.Fatalf("re-parse failed: %v", err)
+	}
+	// iteration must stop at the all-ones address without wrapping
+	top, _ := ParseCIDR("ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/127")
+	it = top.HostIterator(false)
+	n := 0
+	for _, ok := it.Next(); ok; _, ok = it.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 addresses got %d", n)
+	}
+}
+
+func TestIteratorInterfaces(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/126")
+	sub, _ := c.SubnetIterator(127)
+	a, _ := Parse("2001:db8::1")
+	b, _ := Parse("2001:db8::4")
+	for name, it := range map[string]CIDRIterator{"subnets": sub, "cover": CoverRangeIterator(a, b)} {
+		n := 0
+		for _, ok := it.Next(); ok; _, ok = it.Next() {
+			n++
 		}
-		if p2.String() != addr.String() {
-			t.Fatalf("roundtrip mismatch %s != %s", p2, addr)
+		if want := map[string]int{"subnets": 2, "cover": 3}[name]; n != want {
+			t.Fatalf("%s: %d networks, want %d", name, n, want)
+		}
+	}
+	var addrs AddressStream = c.HostIterator(false)
+	n := 0
+	for _, ok := addrs.Next(); ok; _, ok = addrs.Next() {
+		n++
+	}
+	if n != 4 {
+		t.Fatalf("host stream yielded %d addresses", n)
+	}
+}
+
+func TestRangeIterator(t *testing.T) {
+	count := func(it *AddressIterator) int {
+		n := 0
+		for _, ok := it.Next(); ok; _, ok = it.Next() {
+			n++
 		}
+		return n
+	}
+	a, _ := Parse("2001:db8::1")
+	if n := count(RangeIterator(a, a)); n != 1 {
+		t.Fatalf("single address range yielded %d", n)
+	}
+	b, _ := Parse("2001:db8::10")
+	if n := count(RangeIterator(a, b)); n != 16 {
+		t.Fatalf("expected 16 got %d", n)
+	}
+	if n := count(RangeIterator(b, a)); n != 0 {
+		t.Fatalf("reversed range should be empty, got %d", n)
+	}
+	zero, _ := Parse("::")
+	if n := count(RangeIterator(zero, zero.Offset(2))); n != 3 {
+		t.Fatalf("range from :: yielded %d", n)
+	}
+	ones, _ := Parse("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	if n := count(RangeIterator(ones.Sub(big.NewInt(2)), ones)); n != 3 {
+		t.Fatalf("range to all-ones yielded %d (wrapped?)", n)
+	}
+}
+
+func TestWalkSubnetTree(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/48")
+	var got []string
+	if err := WalkSubnetTree(c, 2, func(n CIDR, level int) bool {
+		got = append(got, strings.Repeat(" ", level)+n.String())
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2001:db8::/48", " 2001:db8::/49", "  2001:db8::/50", "  2001:db8:0:4000::/50", " 2001:db8:0:8000::/49", "  2001:db8:0:8000::/50", "  2001:db8:0:c000::/50"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("WalkSubnetTree = %q, want %q", got, want)
+	}
+	n := 0
+	_ = WalkSubnetTree(c, 10, func(s CIDR, level int) bool { // prune after the first level
+		n++
+		return level == 0
 	})
+	if n != 3 {
+		t.Fatalf("pruned walk visited %d networks, want 3", n)
+	}
+	host, _ := ParseCIDR("2001:db8::1/127")
+	if err := WalkSubnetTree(host, 1, func(CIDR, int) bool { return true }); err != nil {
+		t.Fatalf("walk to /128: %v", err)
+	}
+	for _, d := range []int{-1, 2} {
+		if err := WalkSubnetTree(host, d, func(CIDR, int) bool { return true }); !errors.Is(err, ErrInvalidSplitPrefix) {
+			t.Fatalf("depth %d error = %v", d, err)
+		}
+	}
 }
 
-func FuzzSummarize(f *testing.F) {
-	f.Add("2001:db8::/64", "2001:db8:0:0:8000::/65")
-	f.Fuzz(func(t *testing.T, a, b string) {
-		c1, err1 := ParseCIDR(a)
-		c2, err2 := ParseCIDR(b)
-		if err1 != nil || err2 != nil {
-			return
+func TestSummarizeMax(t *testing.T) {
+	base, _ := ParseCIDR("2001:db8::/64")
+	quarters, _ := base.Split(66)
+	if res := SummarizeMax(quarters, 65); len(res) != 2 || res[0].String() != "2001:db8::/65" {
+		t.Fatalf("expected two /65s, got %v", res)
+	}
+	if res := SummarizeMax(quarters, 0); len(res) != 1 || res[0].String() != base.String() {
+		t.Fatalf("expected full merge, got %v", res)
+	}
+	// 128 is a no-op apart from collapsing exact duplicates
+	dup := append([]CIDR{quarters[1]}, quarters...)
+	res := SummarizeMax(dup, 128)
+	if len(res) != len(quarters) {
+		t.Fatalf("expected %d CIDRs, got %v", len(quarters), res)
+	}
+	for i := range res {
+		if res[i].String() != quarters[i].String() {
+			t.Fatalf("unexpected result %v", res)
 		}
-		list := []CIDR{c1, c2}
-		res := Summarize(list)
-		for _, orig := range list {
-			contained := false
-			for _, s := range res {
-				if s.ContainsCIDR(orig) {
-					contained = true
-					break
-				}
-			}
-			if !contained {
-				t.Fatalf("lost coverage for %v", orig)
-			}
+	}
+	// inputs shorter than the limit are preserved
+	wide, _ := ParseCIDR("2001:db8::/16")
+	if res := SummarizeMax([]CIDR{wide}, 32); len(res) != 1 || res[0].PrefixLength() != 16 {
+		t.Fatalf("wide input altered: %v", res)
+	}
+}
+
+func TestSummarizeToGranularity(t *testing.T) {
+	var in []CIDR
+	for _, s := range []string{"2001:db8:0:1::1/128", "2001:db8:0:2::/64", "2001:db8:0:100::/64", "2001:db8:1::/48"} {
+		c, _ := ParseCIDR(s)
+		in = append(in, c)
+	}
+	res := SummarizeToGranularity(in, 56)
+	want := []string{"2001:db8::/55", "2001:db8:1::/48"}
+	if len(res) != len(want) {
+		t.Fatalf("SummarizeToGranularity = %v, want %v", res, want)
+	}
+	for i := range want {
+		if res[i].String() != want[i] {
+			t.Fatalf("SummarizeToGranularity = %v, want %v", res, want)
 		}
-	})
+	}
+	for _, c := range in { // over-covers but never loses an input
+		covered := false
+		for _, r := range res {
+			covered = covered || r.ContainsCIDR(c)
+		}
+		if !covered {
+			t.Fatalf("%s not covered by %v", c, res)
+		}
+	}
+	if exact := Summarize(in); len(exact) != 4 {
+		t.Fatalf("exact Summarize should not widen: %v", exact)
+	}
+	if res := SummarizeToGranularity(in, 128); len(res) != 4 {
+		t.Fatalf("granularity 128 should equal Summarize: %v", res)
+	}
 }
 
-func FuzzSplit(f *testing.F) {
-	f.Add("2001:db8::/120", 124)
-	f.Fuzz(func(t *testing.T, cidrStr string, newPrefix int) {
-		c, err := ParseCIDR(cidrStr)
+func TestGaps(t *testing.T) {
+	parent, _ := ParseCIDR("2001:db8::/64")
+	mid, _ := ParseCIDR("2001:db8:0:0:4000::/66")
+	gaps := Gaps(parent, []CIDR{mid})
+	want := []string{"2001:db8::/66", "2001:db8:0:0:8000::/65"}
+	if len(gaps) != len(want) {
+		t.Fatalf("unexpected gaps: %v", gaps)
+	}
+	for i := range want {
+		if gaps[i].String() != want[i] {
+			t.Fatalf("gap %d: got %s want %s", i, gaps[i], want[i])
+		}
+	}
+	if g := Gaps(parent, nil); len(g) != 1 || g[0].String() != parent.String() {
+		t.Fatalf("empty allocation should leave whole parent: %v", g)
+	}
+	outer, _ := ParseCIDR("2001:db8::/32")
+	if g := Gaps(parent, []CIDR{outer}); len(g) != 0 {
+		t.Fatalf("covering allocation should leave no gaps: %v", g)
+	}
+	all, _ := ParseCIDR("::/0")
+	top, _ := ParseCIDR("ffff::/16")
+	if g := Gaps(all, []CIDR{top}); len(g) == 0 || g[len(g)-1].LastHost().Compare(top.FirstHost().Sub(big.NewInt(1))) != 0 {
+		t.Fatalf("unexpected gaps at top of address space: %v", g)
+	}
+	if g := Gaps(all, nil); len(g) != 1 || g[0].String() != "::/0" {
+		t.Fatalf("empty allocation of ::/0 should leave ::/0: %v", g)
+	}
+}
+
+func TestArithmeticIntoV4Mapped(t *testing.T) {
+	a, _ := Parse("::fffe:ffff:ffff")
+	got := a.Add(big.NewInt(1))
+	if got.Expanded() != "0000:0000:0000:0000:0000:ffff:0000:0000" || got.String() != "::ffff:0.0.0.0" {
+		t.Fatalf("add into ::ffff:0:0/96 = %q %s", got.Expanded(), got)
+	}
+	if v4, ok := got.IPv4Mapped(); !ok || v4.String() != "0.0.0.0" {
+		t.Fatalf("IPv4Mapped = %v %v", v4, ok)
+	}
+	// the printed form reads back only through ParseAllowV4Mapped
+	if _, err := Parse(got.String()); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("Parse(%s) error = %v", got, err)
+	}
+	if back, mapped, err := ParseAllowV4Mapped(got.String()); err != nil || !mapped || back.Compare(got) != 0 {
+		t.Fatalf("ParseAllowV4Mapped(%s) = %v %v %v", got, back, mapped, err)
+	}
+	last, _ := ParseCIDR("::fffe:0:0/95")
+	if got := last.LastHost(); got.Expanded() != "0000:0000:0000:0000:0000:ffff:ffff:ffff" {
+		t.Fatalf("last host in ::ffff:0:0/96 lost the value: %q", got.Expanded())
+	}
+}
+
+func TestCoverRangeToAllOnes(t *testing.T) {
+	start, _ := Parse("::1")
+	end, _ := Parse("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	cover, err := CoverRange(start, end)
+	if err != nil || len(cover) != 128 || cover[len(cover)-1].String() != "8000::/1" || !IsMinimalCover(start, end, cover) {
+		t.Fatalf("cover to all-ones: %v %v", cover, err)
+	}
+	if _, err := CoverRange(end, start); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("reversed range error = %v", err)
+	}
+}
+
+func TestAddChecked(t *testing.T) {
+	top, _ := Parse("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	for _, tc := range []struct {
+		a     string
+		delta *big.Int
+		want  string
+	}{
+		{"2001:db8::1", big.NewInt(255), "2001:db8::100"},
+		{"2001:db8::100", big.NewInt(-255), "2001:db8::1"},
+		{"::1", big.NewInt(-1), "::"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe", big.NewInt(1), top.String()},
+		{"::", top.BigInt(), top.String()},
+		{"::fffe:ffff:ffff", big.NewInt(1), "::ffff:0.0.0.0"},
+	} {
+		a, _ := Parse(tc.a)
+		got, err := AddChecked(a, tc.delta)
+		if err != nil || got.String() != tc.want {
+			t.Fatalf("AddChecked(%s, %s) = %v %v want %s", tc.a, tc.delta, got, err, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		a     string
+		delta *big.Int
+	}{
+		{"::", big.NewInt(-1)},
+		{"::1", big.NewInt(-2)},
+		{top.String(), big.NewInt(1)},
+		{"::1", top.BigInt()},
+		{"8000::", new(big.Int).Lsh(big.NewInt(1), 200)},
+		{top.String(), new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 128))},
+	} {
+		a, _ := Parse(tc.a)
+		if got, err := AddChecked(a, tc.delta); !errors.Is(err, ErrOverflow) {
+			t.Fatalf("AddChecked(%s, %s) = %v %v, want ErrOverflow", tc.a, tc.delta, got, err)
+		}
+	}
+}
+
+func TestCoveringCIDRs(t *testing.T) {
+	var routes []CIDR
+	for _, s := range []string{"2001:db8::/32", "::/0", "2001:db8:1::/48", "2001:db8:1:2::/64", "2001:db8:2::/48", "2001:db8:1:2::/56"} {
+		c, _ := ParseCIDR(s)
+		routes = append(routes, c)
+	}
+	for _, tc := range []struct {
+		target string
+		want   []string
+	}{
+		{"2001:db8:1:2::/64", []string{"2001:db8:1:2::/64", "2001:db8:1::/56", "2001:db8:1::/48", "2001:db8::/32", "::/0"}},
+		{"2001:db8:1:2::1/128", []string{"2001:db8:1:2::/64", "2001:db8:1::/56", "2001:db8:1::/48", "2001:db8::/32", "::/0"}},
+		{"2001:db8::/31", []string{"::/0"}},
+		{"::/0", []string{"::/0"}},
+	} {
+		target, _ := ParseCIDR(tc.target)
+		var got []string
+		for _, c := range CoveringCIDRs(routes, target) {
+			got = append(got, c.String())
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("CoveringCIDRs(%s) = %v want %v", tc.target, got, tc.want)
+		}
+	}
+	target, _ := ParseCIDR("2001:db8::/64")
+	if got := CoveringCIDRs(nil, target); len(got) != 0 {
+		t.Fatalf("CoveringCIDRs(nil) = %v", got)
+	}
+}
+
+func TestSubnetsOf(t *testing.T) {
+	var routes []CIDR
+	for _, s := range []string{"2001:db8:1:2::/64", "::/0", "2001:db8:1::/48", "2001:db8::/32", "2001:db8:1::/56", "2001:db8:2::/48", "2001:db9::/32", "2001:db8:1::/64"} {
+		c, _ := ParseCIDR(s)
+		routes = append(routes, c)
+	}
+	for _, tc := range []struct {
+		parent string
+		want   []string
+	}{
+		{"2001:db8:1::/48", []string{"2001:db8:1::/48", "2001:db8:1::/56", "2001:db8:1::/64", "2001:db8:1:2::/64"}},
+		{"2001:db8::/32", []string{"2001:db8::/32", "2001:db8:1::/48", "2001:db8:1::/56", "2001:db8:1::/64", "2001:db8:1:2::/64", "2001:db8:2::/48"}},
+		{"2001:db8:1:2::/63", []string{"2001:db8:1:2::/64"}},
+		{"2001:db8:1:4::/64", nil},
+	} {
+		parent, _ := ParseCIDR(tc.parent)
+		var got []string
+		for _, c := range SubnetsOf(routes, parent) {
+			got = append(got, c.String())
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("SubnetsOf(%s) = %v want %v", tc.parent, got, tc.want)
+		}
+	}
+	all, _ := ParseCIDR("::/0")
+	if got := SubnetsOf(routes, all); len(got) != len(routes) || got[0].String() != "::/0" {
+		t.Fatalf("SubnetsOf(::/0) = %v", got)
+	}
+}
+
+func TestIsExactCover(t *testing.T) {
+	parent, _ := ParseCIDR("2001:db8::/62")
+	subs, _ := parent.Split(64)
+	if !IsExactCover(parent, subs) {
+		t.Fatalf("split %v does not cover %s", subs, parent)
+	}
+	slices.Reverse(subs)
+	if !IsExactCover(parent, subs) || !IsExactCover(parent, []CIDR{parent}) {
+		t.Fatal("IsExactCover depends on order or rejects the parent itself")
+	}
+	half, _ := ParseCIDR("2001:db8::/63")
+	if !IsExactCover(parent, []CIDR{subs[1], half, subs[0]}) {
+		t.Fatal("mixed sizes rejected")
+	}
+	for _, tc := range []struct {
+		name  string
+		parts []string
+	}{
+		{"gap", []string{"2001:db8::/64", "2001:db8:0:1::/64", "2001:db8:0:3::/64"}},
+		{"overlap, same count", []string{"2001:db8::/64", "2001:db8::/64", "2001:db8:0:2::/64", "2001:db8:0:3::/64"}},
+		{"overlap", []string{"2001:db8::/63", "2001:db8:0:1::/64", "2001:db8:0:2::/63"}},
+		{"outside parent", []string{"2001:db8::/63", "2001:db8:0:2::/63", "2001:db8:0:4::/64"}},
+		{"larger than parent", []string{"2001:db8::/61"}},
+		{"empty", nil},
+	} {
+		var list []CIDR
+		for _, s := range tc.parts {
+			c, _ := ParseCIDR(s)
+			list = append(list, c)
+		}
+		if IsExactCover(parent, list) {
+			t.Fatalf("%s: %v accepted", tc.name, tc.parts)
+		}
+	}
+}
+
+func TestCoverRangeMax(t *testing.T) {
+	start, _ := Parse("::1")
+	end, _ := Parse("::ff")
+	for _, tc := range []struct {
+		max   int
+		want  string
+		extra int64
+	}{
+		{8, "::1/128 ::2/127 ::4/126 ::8/125 ::10/124 ::20/123 ::40/122 ::80/121", 0},
+		{7, "::/126 ::4/126 ::8/125 ::10/124 ::20/123 ::40/122 ::80/121", 1},
+		{1, "::/120", 1},
+	} {
+		cover, extra, err := CoverRangeMax(start, end, tc.max)
 		if err != nil {
-			return
+			t.Fatal(err)
 		}
-		if newPrefix <= c.plen || newPrefix > 128 || newPrefix-c.plen > 8 {
-			return
+		var got []string
+		for _, c := range cover {
+			got = append(got, c.String())
 		}
-		subs, err := c.Split(newPrefix)
+		if strings.Join(got, " ") != tc.want || extra.Int64() != tc.extra {
+			t.Fatalf("max %d: %v extra %s, want %s extra %d", tc.max, got, extra, tc.want, tc.extra)
+		}
+	}
+	// ::ff-::100 straddles a /120 boundary: one CIDR means a /119
+	start, _ = Parse("::ff")
+	end, _ = Parse("::100")
+	if cover, extra, err := CoverRangeMax(start, end, 1); err != nil || len(cover) != 1 || cover[0].String() != "::/119" || extra.Int64() != 510 {
+		t.Fatalf("straddling range: %v %s %v", cover, extra, err)
+	}
+	if _, _, err := CoverRangeMax(start, end, 0); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("expected ErrInvalidLimit for limit 0, got %v", err)
+	}
+}
+
+func TestIsMinimalCover(t *testing.T) {
+	for _, r := range [][2]string{
+		{"2001:db8::1", "2001:db8::ff"},
+		{"2001:db8::", "2001:db8::"},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"2001:db8::3", "2001:db8:0:1::7"},
+		{"::fffe:ffff:ffff", "::1:0:0:0"},
+	} {
+		start, _ := Parse(r[0])
+		end, _ := Parse(r[1])
+		cover, err := CoverRange(start, end)
+		if err != nil || !IsMinimalCover(start, end, cover) {
+			t.Fatalf("CoverRange(%s, %s) = %v %v is not a minimal cover", r[0], r[1], cover, err)
+		}
+		slices.Reverse(cover)
+		if !IsMinimalCover(start, end, cover) {
+			t.Fatalf("IsMinimalCover depends on order for %v", cover)
+		}
+	}
+	start, _ := Parse("2001:db8::")
+	end, _ := Parse("2001:db8::ff")
+	for _, tc := range []struct {
+		name  string
+		cidrs []string
+	}{
+		{"gap", []string{"2001:db8::/121", "2001:db8::c0/122"}},
+		{"overlap", []string{"2001:db8::/120", "2001:db8::80/121"}},
+		{"redundant split", []string{"2001:db8::/121", "2001:db8::80/121"}},
+		{"nested redundant split", []string{"2001:db8::/121", "2001:db8::80/122", "2001:db8::c0/122"}},
+		{"short of end", []string{"2001:db8::/121"}},
+		{"past end", []string{"2001:db8::/119"}},
+		{"empty", nil},
+	} {
+		var list []CIDR
+		for _, s := range tc.cidrs {
+			c, _ := ParseCIDR(s)
+			list = append(list, c)
+		}
+		if IsMinimalCover(start, end, list) {
+			t.Fatalf("%s: %v accepted as a minimal cover", tc.name, tc.cidrs)
+		}
+	}
+	if IsMinimalCover(start, end, []CIDR{{}}) {
+		t.Fatal("zero-value CIDR accepted")
+	}
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	var addrs []Address
+	for _, s := range []string{"2001:db8:2::1", "2001:db8:1::9", "2001:db8:2::", "2001:db8:1::1", "2001:db8:1:ffff::1", "2001:db8:1::1"} {
+		a, _ := Parse(s)
+		addrs = append(addrs, a)
+	}
+	groups, err := GroupByPrefix(addrs, 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, g := range groups {
+		line := g.Network.String() + ":"
+		for _, m := range g.Members {
+			line += " " + m.String()
+		}
+		got = append(got, line)
+	}
+	want := []string{"2001:db8:1::/48: 2001:db8:1::1 2001:db8:1::1 2001:db8:1::9 2001:db8:1:ffff::1", "2001:db8:2::/48: 2001:db8:2:: 2001:db8:2::1"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("GroupByPrefix = %q want %q", got, want)
+	}
+	if addrs[0].String() != "2001:db8:2::1" {
+		t.Fatal("GroupByPrefix reordered its input")
+	}
+	if groups, err := GroupByPrefix(addrs, 0); err != nil || len(groups) != 1 || groups[0].Network.String() != "::/0" || len(groups[0].Members) != len(addrs) {
+		t.Fatalf("GroupByPrefix(/0) = %v %v", groups, err)
+	}
+	if groups, err := GroupByPrefix(nil, 64); err != nil || len(groups) != 0 {
+		t.Fatalf("GroupByPrefix(nil) = %v %v", groups, err)
+	}
+	if _, err := GroupByPrefix(addrs, 129); !errors.Is(err, ErrInvalidPrefix) {
+		t.Fatalf("GroupByPrefix(/129) error = %v", err)
+	}
+}
+
+func TestPrefixHistogram(t *testing.T) {
+	var list []CIDR
+	for _, s := range []string{"2001:db8::/64", "2001:db8:1::/48", "2001:db8:0:1::/64", "2001:db8::/64"} {
+		c, _ := ParseCIDR(s)
+		list = append(list, c)
+	}
+	if got := PrefixHistogram(list); len(got) != 2 || got[64] != 3 || got[48] != 1 {
+		t.Fatalf("PrefixHistogram = %v", got)
+	}
+	if got := PrefixHistogram(nil); got == nil || len(got) != 0 {
+		t.Fatalf("PrefixHistogram(nil) = %#v", got)
+	}
+}
+
+func TestEqualCanonicalDedup(t *testing.T) {
+	a, _ := ParseCIDR("2001:db8::/64")
+	b, _ := ParseCIDR("2001:db8::1/64") // masked on parse
+	c, _ := ParseCIDR("2001:db8::/65")
+	if !a.Equal(b) || a.Equal(c) {
+		t.Fatal("Equal must compare base bytes and prefix length")
+	}
+	raw := CIDR{base: b.base.Add(big.NewInt(1)), plen: 64}
+	if raw.Equal(a) || !raw.Canonical().Equal(a) {
+		t.Fatalf("Canonical did not clear host bits: %s", raw.Canonical())
+	}
+	d, _ := ParseCIDR("2001:db8:1::/48")
+	got := DedupCIDRs([]CIDR{c, a, d, b, c, raw})
+	want := []string{"2001:db8::/65", "2001:db8::/64", "2001:db8:1::/48"}
+	if len(got) != len(want) {
+		t.Fatalf("dedup = %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Fatalf("dedup = %v want %v", got, want)
+		}
+	}
+}
+
+func TestDedupAddresses(t *testing.T) {
+	var in []Address
+	for _, s := range []string{"2001:DB8::1", "2001:db8::2", "2001:0db8:0000:0000:0000:0000:0000:0001", "::1", "2001:db8::2"} {
+		a, err := Parse(s)
 		if err != nil {
-			return
+			t.Fatal(err)
 		}
-		for i := 0; i < len(subs); i++ {
-			if !c.ContainsCIDR(subs[i]) {
-				t.Fatalf("sub not contained %v", subs[i])
-			}
-			for j := i + 1; j < len(subs); j++ {
-				if subs[i].Overlaps(subs[j]) {
-					t.Fatalf("overlap %v %v", subs[i], subs[j])
-				}
-			}
+		in = append(in, a)
+	}
+	got := DedupAddresses(in)
+	want := []string{"2001:db8::1", "2001:db8::2", "::1"}
+	if len(got) != len(want) {
+		t.Fatalf("dedup = %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Fatalf("dedup = %v want %v", got, want)
 		}
-	})
+	}
 }
 
-// Benchmarks (merged from bench_test.go)
-func BenchmarkSplit(b *testing.B) {
-	c, _ := ParseCIDR("2001:db8::/64")
-	for i := 0; i < b.N; i++ {
-		_, _ = c.Split(68)
+func TestHex(t *testing.T) {
+	a, _ := Parse("2001:db8::1")
+	if h := a.Hex(); h != "20010db8000000000000000000000001" {
+		t.Fatalf("Hex = %q", h)
+	}
+	for _, s := range []string{"20010db8000000000000000000000001", "0x20010DB8000000000000000000000001", "2001:0db8:0000:0000:0000:0000:0000:0001"} {
+		b, err := AddressFromHex(s)
+		if err != nil || b.Compare(a) != 0 {
+			t.Fatalf("AddressFromHex(%q) = %v, %v", s, b, err)
+		}
+	}
+	for _, s := range []string{"20010db8", "20010db80000000000000000000000010", "z0010db8000000000000000000000001", "00000000000000000000ffffc0000201"} {
+		if _, err := AddressFromHex(s); !errors.Is(err, ErrInvalidAddress) {
+			t.Fatalf("AddressFromHex(%q): expected ErrInvalidAddress, got %v", s, err)
+		}
 	}
 }
-func BenchmarkSummarize(b *testing.B) {
-	base, _ := ParseCIDR("2001:db8::/64")
-	subs, _ := base.Split(68)
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_ = Summarize(subs)
+
+func TestDottedSuffix(t *testing.T) {
+	a, err := Parse("2001:db8::192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Expanded() != "2001:0db8:0000:0000:0000:0000:c000:0201" || a.String() != "2001:db8::c000:201" {
+		t.Fatalf("unexpected forms %s %s", a.Expanded(), a)
+	}
+	if v4 := a.EmbeddedIPv4(); v4.String() != "192.0.2.1" {
+		t.Fatalf("EmbeddedIPv4 = %s", v4)
+	}
+}
+
+func TestCommonPrefixLen(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"2001:db8::1", "2001:db8::1", 128},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", 0},
+		{"2001:db8::", "2001:db8::1", 127},
+		{"2001:db8::", "2001:db8:8000::", 32},
+		{"2001:db8:1::", "2001:db8:3::", 46},
+	} {
+		a, _ := Parse(tc.a)
+		b, _ := Parse(tc.b)
+		if got := CommonPrefixLen(a, b); got != tc.want {
+			t.Fatalf("CommonPrefixLen(%s, %s) = %d want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := CommonPrefixLen(b, a); got != tc.want {
+			t.Fatalf("CommonPrefixLen not symmetric for %s %s", tc.a, tc.b)
+		}
+	}
+}
+
+func TestSupernetEdges(t *testing.T) {
+	for _, tc := range []struct {
+		in   []string
+		want string
+	}{
+		{[]string{"2001:db8::/48"}, "2001:db8::/48"},
+		{[]string{"::/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128"}, "::/0"},
+		{[]string{"::/1", "8000::/1"}, "::/0"},
+		{[]string{"2001:db8::/64", "2001:db8:0:1::1/128", "2001:db8:0:2::/63"}, "2001:db8::/62"},
+		{[]string{"2001:db8::/32", "2001:db8:1::/48"}, "2001:db8::/32"},
+	} {
+		var list []CIDR
+		for _, s := range tc.in {
+			c, err := ParseCIDR(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			list = append(list, c)
+		}
+		got, err := Supernet(list)
+		if err != nil || got.String() != tc.want {
+			t.Fatalf("Supernet(%v) = %v %v want %s", tc.in, got, err, tc.want)
+		}
+	}
+	if _, err := Supernet(nil); !errors.Is(err, ErrEmptyList) {
+		t.Fatalf("Supernet(nil) error = %v", err)
+	}
+	if _, err := Supernet([]CIDR{{}}); !errors.Is(err, ErrInvalidCIDR) {
+		t.Fatalf("Supernet(zero CIDR) error = %v", err)
+	}
+}
+
+func TestSpecialPurpose(t *testing.T) {
+	for _, tc := range []struct {
+		addr, network, name, rfc string
+	}{
+		{"::1", "::1/128", "Loopback Address", "RFC 4291"},
+		{"::", "::/128", "Unspecified Address", "RFC 4291"},
+		{"::ffff:192.0.2.1", "::ffff:0.0.0.0/96", "IPv4-mapped Address", "RFC 4291"},
+		{"64:ff9b::192.0.2.1", "64:ff9b::/96", "IPv4-IPv6 Translation", "RFC 6052"},
+		{"2001:db8::1", "2001:db8::/32", "Documentation", "RFC 3849"},
+		{"2001::1", "2001::/32", "TEREDO", "RFC 4380"},
+		{"2001:1::1", "2001:1::1/128", "Port Control Protocol Anycast", "RFC 7723"},
+		{"2001:1::9", "2001::/23", "IETF Protocol Assignments", "RFC 2928"},
+		{"fe80::1", "fe80::/10", "Link-Local Unicast", "RFC 4291"},
+	} {
+		a, _, err := ParseAllowV4Mapped(tc.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, ok := LookupSpecialPurpose(a)
+		if !ok || e.Network.String() != tc.network || e.Name != tc.name || e.RFC != tc.rfc {
+			t.Fatalf("LookupSpecialPurpose(%s) = %+v %v", tc.addr, e, ok)
+		}
+		if name, ok := SpecialPurpose(a); !ok || name != tc.name {
+			t.Fatalf("SpecialPurpose(%s) = %q %v", tc.addr, name, ok)
+		}
+	}
+	for _, s := range []string{"::2", "2606:4700::1", "ff02::1"} {
+		a, _ := Parse(s)
+		if name, ok := SpecialPurpose(a); ok {
+			t.Fatalf("SpecialPurpose(%s) = %q, want no match", s, name)
+		}
+	}
+}
+
+func TestAddressType(t *testing.T) {
+	for in, want := range map[string]AddressType{
+		"2600::1":     TypeGlobalUnicast,
+		"2001:db8::1": TypeDocumentation,
+		"fe80::1":     TypeLinkLocal,
+		"fd00::1":     TypeUniqueLocal,
+		"ff02::1":     TypeMulticast,
+		"::1":         TypeLoopback,
+		"::":          TypeUnspecified,
+		"4000::1":     TypeUnclassified,
+		"100::1":      TypeUnclassified,
+	} {
+		a, _ := Parse(in)
+		if got := a.Type(); got != want {
+			t.Fatalf("%s.Type() = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestClassifyCIDR(t *testing.T) {
+	for _, tc := range []struct {
+		cidr string
+		want []AddressType
+	}{
+		{"fe80::/64", []AddressType{TypeLinkLocal}},
+		{"2001:db8::/48", []AddressType{TypeGlobalUnicast, TypeDocumentation}},
+		{"4000::/2", []AddressType{TypeUnclassified}},
+		{"::/127", []AddressType{TypeLoopback, TypeUnspecified}},
+		// straddles the unclassified fe00::/9 and link-local boundary
+		{"fe00::/8", []AddressType{TypeLinkLocal, TypeUnclassified}},
+		{"::/0", []AddressType{TypeGlobalUnicast, TypeLinkLocal, TypeUniqueLocal, TypeMulticast, TypeLoopback, TypeUnspecified, TypeDocumentation, TypeUnclassified}},
+	} {
+		c, _ := ParseCIDR(tc.cidr)
+		if got := ClassifyCIDR(c); !slices.Equal(got, tc.want) {
+			t.Fatalf("ClassifyCIDR(%s) = %v want %v", tc.cidr, got, tc.want)
+		}
+	}
+	if _, ok := TypeUnclassified.Network(); ok {
+		t.Fatal("unclassified has no network")
+	}
+	if n, ok := TypeUniqueLocal.Network(); !ok || n.String() != "fc00::/7" {
+		t.Fatalf("unexpected unique-local network %v", n)
+	}
+}
+
+func TestCountRangeAndUsable(t *testing.T) {
+	a, _ := Parse("2001:db8::1")
+	b, _ := Parse("2001:db8::ff")
+	if CountRange(a, a).Cmp(big.NewInt(1)) != 0 {
+		t.Fatal("CountRange(a,a) should be 1")
+	}
+	if CountRange(a, b).Cmp(big.NewInt(255)) != 0 || CountRange(b, a).Cmp(big.NewInt(255)) != 0 {
+		t.Fatalf("CountRange not symmetric: %s %s", CountRange(a, b), CountRange(b, a))
+	}
+	all, _ := ParseCIDR("::/0")
+	if CountRange(all.FirstHost(), all.LastHost()).Cmp(all.HostCount()) != 0 {
+		t.Fatal("full range count mismatch")
+	}
+	c, _ := ParseCIDR("2001:db8::/64")
+	want := new(big.Int).Sub(c.HostCount(), big.NewInt(1))
+	if c.UsableCount(true).Cmp(want) != 0 || c.UsableCount(false).Cmp(c.HostCount()) != 0 {
+		t.Fatal("usable count mismatch")
+	}
+	p2p, _ := ParseCIDR("2001:db8::/127")
+	if p2p.UsableCount(true).Cmp(big.NewInt(2)) != 0 {
+		t.Fatal("/127 should keep both addresses")
+	}
+}
+
+func TestParseAllowV4Mapped(t *testing.T) {
+	if _, err := Parse("::ffff:192.0.2.1"); err == nil {
+		t.Fatal("strict Parse must reject IPv4-mapped")
+	}
+	a, mapped, err := ParseAllowV4Mapped("::ffff:192.0.2.1")
+	if err != nil || !mapped {
+		t.Fatalf("expected mapped address: %v %v", mapped, err)
+	}
+	if a.String() != "::ffff:192.0.2.1" || a.Expanded() != "0000:0000:0000:0000:0000:ffff:c000:0201" {
+		t.Fatalf("unexpected mapped form: %s %s", a, a.Expanded())
+	}
+	if v4, ok := a.IPv4Mapped(); !ok || v4.String() != "192.0.2.1" {
+		t.Fatalf("unexpected IPv4: %v %v", v4, ok)
+	}
+	b, mapped, err := ParseAllowV4Mapped("2001:db8::1")
+	if err != nil || mapped || b.String() != "2001:db8::1" {
+		t.Fatalf("plain IPv6 mishandled: %v %v %v", b, mapped, err)
+	}
+	if _, _, err := ParseAllowV4Mapped("192.0.2.1"); err == nil {
+		t.Fatal("bare IPv4 should be rejected")
+	}
+}
+
+func TestRandomAddressExcluding(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/120")
+	var reserved []CIDR
+	for _, s := range []string{"2001:db8::/121", "2001:db8::80/122", "2001:db8::c0/123", "2001:db8::e0/124", "2001:db8::f0/125", "2001:db8::f8/126", "2001:db8::fc/127", "2001:db8::fe/128"} {
+		rc, _ := ParseCIDR(s)
+		reserved = append(reserved, rc)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ { // a single free address among 256
+		a, err := RandomAddressExcluding(c, reserved, r)
+		if err != nil || a.String() != "2001:db8::ff" {
+			t.Fatalf("RandomAddressExcluding = %v %v", a, err)
+		}
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		a, err := RandomAddressExcluding(c, reserved[:1], r)
+		if err != nil || !c.ContainsAddress(a) || reserved[0].ContainsAddress(a) {
+			t.Fatalf("RandomAddressExcluding = %v %v", a, err)
+		}
+		seen[a.String()] = true
+	}
+	if len(seen) < 50 {
+		t.Fatalf("only %d distinct addresses in 200 draws from 128", len(seen))
+	}
+	all, _ := ParseCIDR("2001:db8::/64")
+	if _, err := RandomAddressExcluding(c, []CIDR{all}, r); !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("fully reserved error = %v", err)
+	}
+	if a, err := RandomAddressExcluding(all, nil, r); err != nil || !all.ContainsAddress(a) {
+		t.Fatalf("no reservations: %v %v", a, err)
+	}
+}
+
+func TestRandomSubnetWeighted(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/62")
+	r := rand.New(rand.NewSource(1))
+	only := func(want uint64) func(uint64) float64 {
+		return func(idx uint64) float64 {
+			if idx == want {
+				return 1
+			}
+			return 0
+		}
+	}
+	for want, s := range []string{"2001:db8::/64", "2001:db8:0:1::/64", "2001:db8:0:2::/64", "2001:db8:0:3::/64"} {
+		for i := 0; i < 10; i++ {
+			got, err := RandomSubnetWeighted(c, 64, only(uint64(want)), r)
+			if err != nil || got.String() != s {
+				t.Fatalf("weight only on %d: %v %v", want, got, err)
+			}
+		}
+	}
+	counts := make([]int, 4)
+	for i := 0; i < 4000; i++ {
+		got, err := RandomSubnetWeighted(c, 64, func(idx uint64) float64 { return float64(4 - idx) }, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hi, _ := got.Base().hiLo()
+		counts[hi&3]++
+	}
+	for i := 1; i < 4; i++ {
+		if counts[i] >= counts[i-1] {
+			t.Fatalf("decreasing weights gave counts %v", counts)
+		}
+	}
+	if got, err := RandomSubnetWeighted(c, 62, only(5), r); err != nil || got.String() != c.String() {
+		t.Fatalf("same prefix: %v %v", got, err)
+	}
+	if _, err := RandomSubnetWeighted(c, 61, only(0), r); !errors.Is(err, ErrInvalidSplitPrefix) {
+		t.Fatalf("shorter prefix error = %v", err)
+	}
+	if _, err := RandomSubnetWeighted(c, 64, func(uint64) float64 { return 0 }, r); err == nil {
+		t.Fatal("zero weights accepted")
+	}
+	if _, err := RandomSubnetWeighted(c, 64, func(uint64) float64 { return -1 }, r); err == nil {
+		t.Fatal("negative weight accepted")
+	}
+	if _, err := RandomSubnetWeighted(c, 128, only(0), r); !errors.Is(err, ErrSplitExcessive) {
+		t.Fatalf("excessive split error = %v", err)
+	}
+}
+
+func TestRandomSubnetsWeighted(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/56")
+	r := rand.New(rand.NewSource(1))
+	calls := 0
+	weights := func(idx uint64) float64 {
+		calls++
+		return 1 / float64(idx+1)
+	}
+	list, err := RandomSubnetsWeighted(c, 64, 100, weights, r)
+	if err != nil || len(list) != 100 {
+		t.Fatalf("RandomSubnetsWeighted = %d subnets, %v", len(list), err)
+	}
+	if calls != 256 {
+		t.Fatalf("weights evaluated %d times, want once per subnet (256)", calls)
+	}
+	for _, s := range list {
+		if s.PrefixLength() != 64 || !c.ContainsCIDR(s) {
+			t.Fatalf("subnet %s outside %s", s, c)
+		}
+	}
+	if _, err := RandomSubnetsWeighted(c, 64, 0, weights, r); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("n=0 error = %v", err)
+	}
+}
+
+func TestSupernetAtAndParent(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8:1:2::/64")
+	s, err := c.SupernetAt(48)
+	if err != nil || s.String() != "2001:db8:1::/48" {
+		t.Fatalf("SupernetAt(48) = %v %v", s, err)
+	}
+	if s, err := c.SupernetAt(64); err != nil || s.String() != c.String() {
+		t.Fatalf("SupernetAt(own prefix) = %v %v", s, err)
+	}
+	if _, err := c.SupernetAt(65); err == nil {
+		t.Fatal("expected error for longer prefix")
+	}
+	if p := c.Parent(); p.String() != "2001:db8:1:2::/63" {
+		t.Fatalf("unexpected parent %s", p)
+	}
+	all, _ := ParseCIDR("::/0")
+	if all.Parent().String() != "::/0" {
+		t.Fatal("parent of /0 should be itself")
+	}
+}
+
+func TestSibling(t *testing.T) {
+	a, _ := ParseCIDR("2001:db8::/65")
+	b, ok := a.Sibling()
+	if !ok || b.String() != "2001:db8:0:0:8000::/65" {
+		t.Fatalf("unexpected sibling %v %v", b, ok)
+	}
+	if back, _ := b.Sibling(); back.String() != a.String() {
+		t.Fatalf("sibling not symmetric: %s", back)
+	}
+	if !a.SharesParentWith(b) || !b.SharesParentWith(a) {
+		t.Fatal("two /65 halves of a /64 should share a parent")
+	}
+	other, _ := ParseCIDR("2001:db8:0:1::/65")
+	if a.SharesParentWith(other) {
+		t.Fatal("unrelated /65s reported as siblings")
+	}
+	wider, _ := ParseCIDR("2001:db8::/64")
+	if a.SharesParentWith(wider) {
+		t.Fatal("different prefix lengths cannot be siblings")
+	}
+	host, _ := ParseCIDR("::/128")
+	if s, _ := host.Sibling(); s.String() != "::1/128" {
+		t.Fatalf("unexpected /128 sibling %s", s)
+	}
+	all, _ := ParseCIDR("::/0")
+	if _, ok := all.Sibling(); ok {
+		t.Fatal("/0 has no sibling")
+	}
+}
+
+func TestBitsAndNibbles(t *testing.T) {
+	a, _ := Parse("2001:db8::1")
+	b := a.Bits()
+	if len(b) != BitLen || b[:16] != "0010000000000001" || b[len(b)-1] != '1' {
+		t.Fatalf("unexpected bits %s", b)
+	}
+	n := a.Nibbles()
+	if n[0] != 2 || n[3] != 1 || n[5] != 0xd || n[31] != 1 {
+		t.Fatalf("unexpected nibbles %v", n)
+	}
+}
+
+func TestGetSetBit(t *testing.T) {
+	a, _ := Parse("::")
+	b, err := a.SetBit(0, 1)
+	if err != nil || b.String() != "8000::" {
+		t.Fatalf("bit 0 should be the MSB: %v %v", b, err)
+	}
+	b, _ = b.SetBit(127, 1)
+	if v, _ := b.GetBit(127); v != 1 || b.String() != "8000::1" {
+		t.Fatalf("bit 127 should be the LSB: %s", b)
+	}
+	b, _ = b.SetBit(0, 0)
+	if v, _ := b.GetBit(0); v != 0 || b.String() != "::1" {
+		t.Fatalf("clear failed: %s", b)
+	}
+	if a.String() != "::" {
+		t.Fatal("SetBit must not modify the receiver")
+	}
+	for _, pos := range []int{-1, 128} {
+		if _, err := a.GetBit(pos); !errors.Is(err, ErrInvalidBit) {
+			t.Fatalf("expected ErrInvalidBit for %d: %v", pos, err)
+		}
+		if _, err := a.SetBit(pos, 1); !errors.Is(err, ErrInvalidBit) {
+			t.Fatalf("expected ErrInvalidBit for %d: %v", pos, err)
+		}
+	}
+	if _, err := a.SetBit(5, 2); !errors.Is(err, ErrInvalidBit) {
+		t.Fatalf("expected ErrInvalidBit for value 2: %v", err)
+	}
+}
+
+func TestBitwise(t *testing.T) {
+	a, _ := Parse("2001:db8::ff")
+	b, _ := Parse("::1:ffff:ffff")
+	if r := a.And(b); r.String() != "::ff" {
+		t.Fatalf("and: %s", r)
+	}
+	if r := a.Or(b); r.String() != "2001:db8::1:ffff:ffff" {
+		t.Fatalf("or: %s", r)
+	}
+	// results inside ::ffff:0:0/96 must survive as plain 16-byte values
+	hi, _ := Parse("::ff00:0:0")
+	lo, _ := Parse("::ff:0:0")
+	if r := hi.Or(lo); r.Expanded() != "0000:0000:0000:0000:0000:ffff:0000:0000" {
+		t.Fatalf("or into mapped range: %s", r.Expanded())
+	}
+	if r := a.Xor(a); r.String() != "::" {
+		t.Fatalf("xor self: %s", r)
+	}
+	if d := HammingDistance(a, a); d != 0 {
+		t.Fatalf("hamming self: %d", d)
+	}
+	zero, _ := Parse("::")
+	ones, _ := Parse("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	if d := HammingDistance(zero, ones); d != 128 {
+		t.Fatalf("hamming extremes: %d", d)
+	}
+	if d := HammingDistance(hi, lo); d != HammingDistance(lo, hi) || d != 16 {
+		t.Fatalf("hamming: %d", d)
+	}
+}
+
+func TestNetmaskHostMask(t *testing.T) {
+	cases := []struct{ cidr, net, host string }{
+		{"::/0", "::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"::/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "::"},
+		{"2001:db8::/64", "ffff:ffff:ffff:ffff::", "::ffff:ffff:ffff:ffff"},
+		{"2001:db8::/66", "ffff:ffff:ffff:ffff:c000::", "::3fff:ffff:ffff:ffff"},
+	}
+	for _, tc := range cases {
+		c, _ := ParseCIDR(tc.cidr)
+		if got := c.Netmask().String(); got != tc.net {
+			t.Fatalf("%s netmask: got %s want %s", tc.cidr, got, tc.net)
+		}
+		if got := c.HostMask().String(); got != tc.host {
+			t.Fatalf("%s hostmask: got %s want %s", tc.cidr, got, tc.host)
+		}
+		if c.Netmask().Or(c.HostMask()).BigInt().BitLen() != 128 || c.Netmask().And(c.HostMask()).BigInt().Sign() != 0 {
+			t.Fatalf("%s masks are not complementary", tc.cidr)
+		}
+	}
+}
+
+func TestCountSubnets(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/48")
+	for _, tc := range []struct {
+		prefix int
+		want   string
+	}{{48, "1"}, {56, "256"}, {64, "65536"}, {128, "1208925819614629174706176"}} {
+		if n, err := c.CountSubnets(tc.prefix); err != nil || n.String() != tc.want {
+			t.Fatalf("CountSubnets(%d) = %v %v, want %s", tc.prefix, n, err, tc.want)
+		}
+	}
+	all, _ := ParseCIDR("::/0")
+	if n, err := all.CountSubnets(128); err != nil || n.Cmp(all.HostCount()) != 0 {
+		t.Fatalf("/128s in ::/0 = %v %v", n, err)
+	}
+	for _, p := range []int{47, 129, -1} {
+		if _, err := c.CountSubnets(p); !errors.Is(err, ErrInvalidSplitPrefix) {
+			t.Fatalf("CountSubnets(%d) error = %v", p, err)
+		}
+	}
+}
+
+func TestAddWrap(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/126")
+	for _, tc := range []struct {
+		a     string
+		delta *big.Int
+		want  string
+	}{
+		{"2001:db8::1", big.NewInt(2), "2001:db8::3"},
+		{"2001:db8::3", big.NewInt(1), "2001:db8::"},
+		{"2001:db8::1", big.NewInt(-2), "2001:db8::3"},
+		{"2001:db8::2", big.NewInt(401), "2001:db8::3"},
+		{"2001:db8::2", new(big.Int).Lsh(big.NewInt(1), 200), "2001:db8::2"},
+	} {
+		a, _ := Parse(tc.a)
+		got, err := c.AddWrap(a, tc.delta)
+		if err != nil || got.String() != tc.want {
+			t.Fatalf("AddWrap(%s, %s) = %v %v want %s", tc.a, tc.delta, got, err, tc.want)
+		}
+	}
+	all, _ := ParseCIDR("::/0")
+	top, _ := Parse("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	if got, err := all.AddWrap(top, big.NewInt(1)); err != nil || got.String() != "::" {
+		t.Fatalf("AddWrap over ::/0 = %v %v", got, err)
+	}
+	out, _ := Parse("2001:db8::4")
+	if _, err := c.AddWrap(out, big.NewInt(1)); !errors.Is(err, ErrNotContained) {
+		t.Fatalf("expected ErrNotContained, got %v", err)
+	}
+}
+
+func TestAddressAtPercentile(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/120")
+	for _, tc := range []struct {
+		p    float64
+		want string
+	}{{0, "2001:db8::"}, {50, "2001:db8::80"}, {25, "2001:db8::40"}, {0.5, "2001:db8::1"}, {0.3, "2001:db8::"}, {99.9, "2001:db8::ff"}, {100, "2001:db8::ff"}} {
+		if a, err := c.AddressAtPercentile(tc.p); err != nil || a.String() != tc.want {
+			t.Fatalf("AddressAtPercentile(%v) = %v %v, want %s", tc.p, a, err, tc.want)
+		}
+	}
+	all, _ := ParseCIDR("::/0")
+	if a, err := all.AddressAtPercentile(50); err != nil || a.String() != "8000::" {
+		t.Fatalf("50th percentile of ::/0 = %v %v", a, err)
+	}
+	if a, err := all.AddressAtPercentile(12.5); err != nil || a.String() != "2000::" {
+		t.Fatalf("12.5th percentile of ::/0 = %v %v", a, err)
+	}
+	for _, p := range []float64{-1, 100.01, math.NaN()} {
+		if _, err := c.AddressAtPercentile(p); !errors.Is(err, ErrInvalidPercentile) {
+			t.Fatalf("AddressAtPercentile(%v) error = %v", p, err)
+		}
+	}
+}
+
+func TestPercentileOf(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/120")
+	for _, tc := range []struct {
+		addr string
+		want float64
+	}{{"2001:db8::", 0}, {"2001:db8::80", 50}, {"2001:db8::40", 25}, {"2001:db8::ff", 99.609375}} {
+		a, _ := Parse(tc.addr)
+		if p, err := c.PercentileOf(a); err != nil || p != tc.want {
+			t.Fatalf("PercentileOf(%s) = %v %v, want %v", tc.addr, p, err, tc.want)
+		}
+	}
+	out, _ := Parse("2001:db9::")
+	if _, err := c.PercentileOf(out); !errors.Is(err, ErrNotContained) {
+		t.Fatalf("outside address error = %v", err)
+	}
+	all, _ := ParseCIDR("::/0")
+	if p, err := all.PercentileOf(all.LastHost()); err != nil || p != 100 {
+		t.Fatalf("last address of ::/0 = %v %v", p, err) // 100 - 100/2^128 rounds to 100
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ { // round trip within one address of float64 precision
+		p := r.Float64() * 100
+		a, err := all.AddressAtPercentile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := all.PercentileOf(a)
+		if err != nil || math.Abs(got-p) > 1e-12 {
+			t.Fatalf("round trip %v -> %s -> %v (%v)", p, a, got, err)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/64")
+	if !c.IsNetworkAddress(c.Base()) || c.IsNetworkAddress(c.LastHost()) {
+		t.Fatal("IsNetworkAddress mismatch")
+	}
+	if !c.IsLastAddress(c.LastHost()) || c.IsLastAddress(c.Base()) {
+		t.Fatal("IsLastAddress mismatch")
+	}
+	pos, err := c.Position(c.LastHost())
+	want := new(big.Int).Sub(c.HostCount(), big.NewInt(1))
+	if err != nil || pos.Cmp(want) != 0 {
+		t.Fatalf("position of last host: %v %v", pos, err)
+	}
+	a, _ := Parse("2001:db8::ff")
+	if pos, _ := c.Position(a); pos.Int64() != 255 {
+		t.Fatalf("unexpected position %s", pos)
+	}
+	all, _ := ParseCIDR("::/0")
+	if pos, _ := all.Position(all.LastHost()); pos.BitLen() != 128 {
+		t.Fatalf("position in /0 should need 128 bits, got %s", pos)
+	}
+	out, _ := Parse("2001:db9::1")
+	if _, err := c.Position(out); !errors.Is(err, ErrNotContained) {
+		t.Fatalf("expected ErrNotContained: %v", err)
+	}
+}
+
+func TestDottedNibble(t *testing.T) {
+	a, _ := Parse("2001:db8::1")
+	d := a.DottedNibble()
+	if d != "2.0.0.1.0.d.b.8.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.1" {
+		t.Fatalf("unexpected dotted nibble: %s", d)
+	}
+	if n := len(strings.Split(d, ".")); n != 32 {
+		t.Fatalf("expected 32 nibbles, got %d", n)
+	}
+}
+
+func TestHash64(t *testing.T) {
+	a, _ := Parse("2001:db8::1")
+	// pinned value: the hash is documented as stable across releases
+	if h := a.Hash64(); h != 0xf97161b7a3be1c14 {
+		t.Fatalf("hash changed: %#x", h)
+	}
+	b, _ := Parse("2001:db8::2")
+	if a.Hash64() == b.Hash64() {
+		t.Fatal("neighbouring addresses collide")
+	}
+	// crude distribution check: consecutive addresses spread over 16 buckets
+	var buckets [16]int
+	for i := 0; i < 1600; i++ {
+		buckets[a.Offset(uint64(i)).Hash64()%16]++
+	}
+	for i, n := range buckets {
+		if n < 50 || n > 150 {
+			t.Fatalf("bucket %d has %d entries: %v", i, n, buckets)
+		}
+	}
+}
+
+func TestSummarizeStream(t *testing.T) {
+	base, _ := ParseCIDR("2001:db8::/120")
+	subs, _ := base.Split(124)
+	extra, _ := ParseCIDR("2001:db8::1:0/112")
+	in := append(append([]CIDR{}, subs[:13]...), subs[14], extra) // hole at index 13
+	var got []CIDR
+	if err := SummarizeStream(in, func(c CIDR) { got = append(got, c) }); err != nil {
+		t.Fatal(err)
+	}
+	want := Summarize(in)
+	if len(got) != len(want) {
+		t.Fatalf("stream %v != batch %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i].String() {
+			t.Fatalf("stream %v != batch %v", got, want)
+		}
+	}
+	if err := SummarizeStream([]CIDR{subs[1], subs[0]}, func(CIDR) {}); !errors.Is(err, ErrUnsorted) {
+		t.Fatalf("expected ErrUnsorted, got %v", err)
+	}
+	// memory bound: non-mergeable input must be emitted as it arrives
+	emitted := 0
+	s := NewSummarizer(func(CIDR) { emitted++ })
+	a, _ := Parse("2001:db8::")
+	for i := uint64(0); i < 10000; i++ {
+		c, _ := NewCIDR(a.Offset(2*i), 128) // every other /128: nothing merges
+		if err := s.Push(c); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.stack) > BitLen+1 {
+			t.Fatalf("pending stack grew to %d", len(s.stack))
+		}
+	}
+	s.Flush()
+	if emitted != 10000 {
+		t.Fatalf("emitted %d", emitted)
+	}
+}
+
+func TestSplitParallel(t *testing.T) {
+	for _, tc := range []struct {
+		cidr      string
+		newPrefix int
+	}{{"2001:db8::/48", 52}, {"2001:db8::/64", 74}, {"2001:db8::/120", 128}, {"2001:db8::/56", 56}} {
+		c, _ := ParseCIDR(tc.cidr)
+		want, err := c.Split(tc.newPrefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{0, 1, 3, 64} {
+			got, err := c.SplitParallel(tc.newPrefix, workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("%s /%d workers=%d: len %d != %d", tc.cidr, tc.newPrefix, workers, len(got), len(want))
+			}
+			for i := range want {
+				if got[i].String() != want[i].String() {
+					t.Fatalf("%s /%d workers=%d: [%d] %s != %s", tc.cidr, tc.newPrefix, workers, i, got[i], want[i])
+				}
+			}
+		}
+	}
+	c, _ := ParseCIDR("2001:db8::/32")
+	if _, err := c.SplitParallel(16, 4); !errors.Is(err, ErrInvalidSplitPrefix) {
+		t.Fatalf("expected ErrInvalidSplitPrefix, got %v", err)
+	}
+	if _, err := c.SplitParallel(64, 4); !errors.Is(err, ErrSplitExcessive) {
+		t.Fatalf("expected ErrSplitExcessive, got %v", err)
+	}
+}
+
+func TestUint128Mask(t *testing.T) {
+	a, _ := Parse("fedc:ba98:7654:3210:0123:4567:89ab:cdef")
+	for plen := 0; plen <= BitLen; plen++ {
+		if got, want := a.u128().mask(plen), a.Mask(plen).u128(); got != want {
+			t.Fatalf("/%d: %x != %x", plen, got, want)
+		}
+	}
+}
+
+func TestOverlapsMatchesBigInt(t *testing.T) {
+	slow := func(c, o CIDR) bool {
+		return c.FirstHost().BigInt().Cmp(o.LastHost().BigInt()) <= 0 && o.FirstHost().BigInt().Cmp(c.LastHost().BigInt()) <= 0
+	}
+	var cidrs []CIDR
+	for _, s := range []string{"::/0", "::/128", "2001:db8::/32", "2001:db8::/64", "2001:db8:0:0:8000::/65", "2001:db8:1::/48",
+		"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff/128", "8000::/1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128", "2001:db8::1/127"} {
+		c, err := ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cidrs = append(cidrs, c)
+	}
+	for _, c := range cidrs {
+		for _, o := range cidrs {
+			if c.Overlaps(o) != slow(c, o) {
+				t.Fatalf("Overlaps(%s, %s) = %v", c, o, c.Overlaps(o))
+			}
+		}
+	}
+	if !(CIDR{}).Overlaps(cidrs[2]) { // zero value behaves as ::/0
+		t.Fatal("zero CIDR should overlap everything")
+	}
+}
+
+func TestContainsAddressAllPrefixes(t *testing.T) {
+	var addrs []Address
+	for _, s := range []string{"::", "::1", "2001:db8::1", "2001:db8:0:0:8000::", "8000::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"} {
+		a, _ := Parse(s)
+		addrs = append(addrs, a)
+	}
+	for _, base := range addrs {
+		for plen := 0; plen <= BitLen; plen++ {
+			c, _ := NewCIDR(base, plen)
+			for _, a := range addrs {
+				want := c.base.Compare(a.Mask(plen)) == 0
+				if got := c.ContainsAddress(a); got != want {
+					t.Fatalf("%s contains %s: got %v want %v", c, a, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSummarizeStats(t *testing.T) {
+	var in []CIDR
+	for _, s := range []string{"2001:db8::/65", "2001:db8:0:0:8000::/65", "2001:db8::/64", "2001:db8::1/128", "2001:db8:1::/127"} {
+		c, _ := ParseCIDR(s)
+		in = append(in, c)
+	}
+	res, st := SummarizeStats(in)
+	if st.InputCount != 5 || st.OutputCount != len(res) || st.OutputCount != 2 {
+		t.Fatalf("unexpected counts %+v (%v)", st, res)
+	}
+	want := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(2))
+	if st.TotalAddresses.Cmp(want) != 0 { // duplicates and nested inputs counted once
+		t.Fatalf("total %s want %s", st.TotalAddresses, want)
+	}
+	if _, st := SummarizeStats(nil); st.OutputCount != 0 || st.TotalAddresses.Sign() != 0 {
+		t.Fatalf("empty stats %+v", st)
+	}
+}
+
+func TestParseCIDRStrict(t *testing.T) {
+	if c, err := ParseCIDRStrict("2001:db8::/64"); err != nil || c.String() != "2001:db8::/64" {
+		t.Fatalf("canonical input rejected: %v %v", c, err)
+	}
+	_, err := ParseCIDRStrict("2001:db8::1/64")
+	if !errors.Is(err, ErrHostBitsSet) || !strings.Contains(err.Error(), "2001:db8::/64") {
+		t.Fatalf("expected ErrHostBitsSet naming canonical form, got %v", err)
+	}
+	if _, err := ParseCIDRStrict("2001:db8::/129"); !errors.Is(err, ErrInvalidPrefix) {
+		t.Fatalf("expected ErrInvalidPrefix, got %v", err)
+	}
+	c, _ := ParseCIDR("2001:db8::/64")
+	a, _ := Parse("2001:db8::1")
+	if c.IsHostBitsZero(a) || !c.IsHostBitsZero(c.Base()) {
+		t.Fatal("IsHostBitsZero mismatch")
+	}
+	for _, s := range []string{"::/0", "::1/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128"} {
+		if _, err := ParseCIDRStrict(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+}
+
+func TestByteArrayRoundTrip(t *testing.T) {
+	for _, s := range []string{"::", "::1", "2001:db8::1", "fe80::1:2:3:4", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"} {
+		a, _ := Parse(s)
+		b, err := FromBytes(a.As16())
+		if err != nil || b.Compare(a) != 0 {
+			t.Fatalf("%s: round trip gave %v %v", s, b, err)
+		}
+	}
+	a, _ := Parse("2001:db8::1")
+	if w := a.AsUint32x4(); w != [4]uint32{0x20010db8, 0, 0, 1} {
+		t.Fatalf("AsUint32x4 = %x", w)
+	}
+	if b := a.As16(); b[0] != 0x20 || b[1] != 0x01 || b[15] != 0x01 {
+		t.Fatalf("As16 not big-endian: %x", b)
+	}
+	mapped, _, _ := ParseAllowV4Mapped("::ffff:192.0.2.1")
+	if _, err := FromBytes(mapped.As16()); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("expected v4-mapped rejection, got %v", err)
+	}
+	if m := FromBytesAllowV4Mapped(mapped.As16()); m.String() != "::ffff:192.0.2.1" {
+		t.Fatalf("FromBytesAllowV4Mapped = %s", m)
+	}
+}
+
+func TestAddressClassifiers(t *testing.T) {
+	type flags struct{ global, link, ula, mcast, loop, unspec, doc bool }
+	cases := map[string]flags{
+		"2001:db8::1":     {global: true, doc: true},
+		"2606:4700::1111": {global: true},
+		"fe80::1":         {link: true},
+		"febf:ffff::1":    {link: true},
+		"fec0::1":         {},
+		"fd12:3456::1":    {ula: true},
+		"fc00::":          {ula: true},
+		"ff02::1":         {mcast: true},
+		"::1":             {loop: true},
+		"::":              {unspec: true},
+	}
+	for s, want := range cases {
+		a, _ := Parse(s)
+		got := flags{a.IsGlobalUnicast(), a.IsLinkLocal(), a.IsUniqueLocal(), a.IsMulticast(), a.IsLoopback(), a.IsUnspecified(), a.IsDocumentation()}
+		if got != want {
+			t.Errorf("%s: got %+v want %+v", s, got, want)
+		}
+	}
+}
+
+func TestMulticastInfo(t *testing.T) {
+	cases := []struct {
+		addr    string
+		flags   uint8
+		t, p, r bool
+		scope   string
+	}{
+		{"ff02::1", 0, false, false, false, "link-local"},
+		{"ff05::1:3", 0, false, false, false, "site-local"},
+		{"ff3e:30:2001:db8::1", 3, true, true, false, "global"},
+		{"ff7e:140:2001:db8::1", 7, true, true, true, "global"},
+		{"ff10::1", 1, true, false, false, "reserved"},
+		{"ff16::1", 1, true, false, false, "unassigned"},
+		{"ff1f::1", 1, true, false, false, "reserved"},
+	}
+	for _, tc := range cases {
+		a, _ := Parse(tc.addr)
+		mi, err := a.MulticastInfo()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.addr, err)
+		}
+		if mi.Flags != tc.flags || mi.Transient != tc.t || mi.Prefix != tc.p || mi.Rendezvous != tc.r || mi.ScopeName != tc.scope {
+			t.Errorf("%s: got %+v", tc.addr, mi)
+		}
+	}
+	a, _ := Parse("2001:db8::1")
+	if _, err := a.MulticastInfo(); !errors.Is(err, ErrNotMulticast) {
+		t.Fatalf("expected ErrNotMulticast, got %v", err)
+	}
+}
+
+func TestSolicitedNodeMulticast(t *testing.T) {
+	cases := map[string]string{
+		"2001:db8::1":                  "ff02::1:ff00:1",
+		"fe80::21b:21ff:fe3a:4b5c":     "ff02::1:ff3a:4b5c",
+		"2001:db8::ab:cdef":            "ff02::1:ffab:cdef",
+		"::":                           "ff02::1:ff00:0",
+		"2001:db8:1:2:3:4:5:6":         "ff02::1:ff05:6",
+		"fd00::ffff:ffff:ffff:ffff:ff": "ff02::1:ffff:ff",
+	}
+	for in, want := range cases {
+		a, err := Parse(in)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got := SolicitedNodeMulticast(a).String(); got != want {
+			t.Errorf("%s: got %s want %s", in, got, want)
+		}
+	}
+}
+
+func TestGenerateULA(t *testing.T) {
+	c, err := GenerateULA(bytes.NewReader([]byte{0x12, 0x34, 0x56, 0x78, 0x9a}))
+	if err != nil || c.String() != "fd12:3456:789a::/48" {
+		t.Fatalf("GenerateULA = %v %v", c, err)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 16; i++ {
+		c, err := GenerateULA(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.PrefixLength() != 48 || c.Base().As16()[0] != 0xfd {
+			t.Fatalf("not an fd00::/8 /48: %s", c)
+		}
+		seen[c.String()] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("successive calls returned the same global ID")
+	}
+	if _, err := GenerateULA(bytes.NewReader([]byte{1, 2})); err == nil {
+		t.Fatal("expected short read error")
+	}
+	mac, _ := net.ParseMAC("00:1b:21:3a:4b:5c")
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a, err := GenerateULAFromMAC(mac, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateULAFromMAC(mac, ts.Add(time.Second))
+	if a.Base().As16()[0] != 0xfd || a.String() == b.String() {
+		t.Fatalf("unexpected MAC-derived prefixes %s %s", a, b)
+	}
+	if again, _ := GenerateULAFromMAC(mac, ts); again.String() != a.String() {
+		t.Fatalf("MAC derivation not deterministic: %s %s", a, again)
+	}
+}
+
+func TestParseULA(t *testing.T) {
+	a, _ := Parse("fd12:3456:789a:bcde:1:2:3:4")
+	gid, sid, err := ParseULA(a)
+	if err != nil || gid != 0x123456789a || sid != 0xbcde {
+		t.Fatalf("ParseULA = %x %x %v", gid, sid, err)
+	}
+	a, _ = Parse("fcff:ffff:ffff:0:ffff:ffff:ffff:ffff")
+	if gid, sid, _ := ParseULA(a); gid != 0xffffffffff || sid != 0 {
+		t.Fatalf("bit boundaries wrong: %x %x", gid, sid)
+	}
+	c, _ := GenerateULA(bytes.NewReader([]byte{1, 2, 3, 4, 5}))
+	if gid, _, _ := ParseULA(c.Base()); gid != 0x0102030405 {
+		t.Fatalf("round trip with GenerateULA gave %x", gid)
+	}
+	a, _ = Parse("2001:db8::1")
+	if _, _, err := ParseULA(a); !errors.Is(err, ErrNotULA) {
+		t.Fatalf("expected ErrNotULA, got %v", err)
+	}
+}
+
+func TestStablePrivacyIID(t *testing.T) {
+	prefix, _ := ParseCIDR("2001:db8:1:2::/64")
+	gen := func(p CIDR, iface, secret string, counter int) Address {
+		t.Helper()
+		a, err := StablePrivacyIID(p, iface, []byte(secret), counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	a := gen(prefix, "eth0", "secret", 0)
+	if !prefix.ContainsAddress(a) {
+		t.Fatalf("%s outside %s", a, prefix)
+	}
+	if b := gen(prefix, "eth0", "secret", 0); a.Compare(b) != 0 {
+		t.Fatalf("not deterministic: %s vs %s", a, b)
+	}
+	for _, b := range []Address{
+		gen(prefix, "eth0", "other secret", 0),
+		gen(prefix, "eth1", "secret", 0),
+		gen(prefix, "eth0", "secret", 1),
+	} {
+		if a.Compare(b) == 0 {
+			t.Fatalf("different inputs gave the same address %s", a)
+		}
+		// unrelated IIDs share about half their 64 bits, never nearly all
+		_, alo := a.hiLo()
+		_, blo := b.hiLo()
+		if same := 64 - bits.OnesCount64(alo^blo); same > 56 {
+			t.Fatalf("IIDs %s and %s share %d of 64 bits", a, b, same)
+		}
+	}
+	other, _ := ParseCIDR("2001:db8:1:3::/64")
+	b := gen(other, "eth0", "secret", 0)
+	_, alo := a.hiLo()
+	if _, blo := b.hiLo(); !other.ContainsAddress(b) || alo == blo {
+		t.Fatalf("IID does not depend on the prefix: %s %s", a, b)
+	}
+	wide, _ := ParseCIDR("2001:db8::/48")
+	if b := gen(wide, "eth0", "secret", 0); !wide.ContainsAddress(b) {
+		t.Fatalf("%s outside %s", b, wide)
+	}
+	long, _ := ParseCIDR("2001:db8::/80")
+	if _, err := StablePrivacyIID(long, "eth0", []byte("secret"), 0); !errors.Is(err, ErrInvalidPrefix) {
+		t.Fatalf("/80 error = %v", err)
+	}
+	if _, err := StablePrivacyIID(prefix, "eth0", nil, 0); err == nil {
+		t.Fatal("expected error for empty secret")
+	}
+	for _, iid := range []uint64{0, 0x02005efffe000000, 0x02005efffe005213, 0x02005efffeffffff, 0xfdffffffffffff80, 0xfdffffffffffffff} {
+		if !reservedIID(iid) {
+			t.Fatalf("%#x should be reserved", iid)
+		}
+	}
+	for _, iid := range []uint64{1, 0x02005efffdffffff, 0x02005eff00000000, 0xfdffffffffffff7f, 0xfe00000000000000, 0xffffffffffffffff} {
+		if reservedIID(iid) {
+			t.Fatalf("%#x should not be reserved", iid)
+		}
+	}
+}
+
+func TestAnonymizeAddress(t *testing.T) {
+	a, _ := Parse("2001:db8:1:2:3:4:5:6")
+	if z, err := AnonymizeAddress(a, 48, "zero", nil); err != nil || z.String() != "2001:db8:1::" {
+		t.Fatalf("zero mode = %v %v", z, err)
+	}
+	key := []byte("log-key")
+	h1, err := AnonymizeAddress(a, 48, "hash", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, _ := AnonymizeAddress(a, 48, "hash", key)
+	if h1.Compare(h2) != 0 {
+		t.Fatalf("hash mode not deterministic: %s vs %s", h1, h2)
+	}
+	keep, _ := ParseCIDR("2001:db8:1::/48")
+	if !keep.ContainsAddress(h1) || h1.Compare(a) == 0 {
+		t.Fatalf("hash mode %s should keep the /48 and change the tail", h1)
+	}
+	if other, _ := AnonymizeAddress(a, 48, "hash", []byte("other-key")); other.Compare(h1) == 0 {
+		t.Fatal("different keys gave the same output")
+	}
+	b, _ := Parse("2001:db8:1:2:3:4:5:7")
+	if hb, _ := AnonymizeAddress(b, 48, "hash", key); hb.Compare(h1) == 0 {
+		t.Fatal("different hosts gave the same output")
+	}
+	if same, _ := AnonymizeAddress(a, 128, "hash", key); same.Compare(a) != 0 {
+		t.Fatalf("keeping all 128 bits changed the address: %s", same)
+	}
+	for _, tc := range []struct {
+		keep int
+		mode string
+		key  []byte
+	}{{-1, "zero", nil}, {129, "zero", nil}, {48, "hash", nil}, {48, "scramble", key}} {
+		if _, err := AnonymizeAddress(a, tc.keep, tc.mode, tc.key); err == nil {
+			t.Fatalf("AnonymizeAddress(%d, %q, %q) accepted", tc.keep, tc.mode, tc.key)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	from, _ := ParseCIDR("2001:db8::/32")
+	to, _ := ParseCIDR("2001:470::/32")
+	deeper, _ := ParseCIDR("2001:470:ab00::/40")
+	for _, tc := range []struct {
+		in   string
+		to   CIDR
+		want string
+		ok   bool
+	}{
+		{"2001:db8:1:2:3:4:5:6", to, "2001:470:1:2:3:4:5:6", true},
+		{"2001:db8::", to, "2001:470::", true},
+		{"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", to, "2001:470:ffff:ffff:ffff:ffff:ffff:ffff", true},
+		{"2001:db8:12:3::1", deeper, "2001:470:ab12:3::1", true}, // bits 32..39 come from to
+		{"2001:db9::1", to, "2001:db9::1", false},
+	} {
+		a, _ := Parse(tc.in)
+		got, ok := Rewrite(a, from, tc.to)
+		if ok != tc.ok || got.String() != tc.want {
+			t.Fatalf("Rewrite(%s) = %s %v, want %s %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+	short, _ := ParseCIDR("2001::/16")
+	a, _ := Parse("2001:db8::1")
+	if got, ok := Rewrite(a, from, short); ok || got.Compare(a) != 0 {
+		t.Fatalf("shorter to accepted: %s %v", got, ok)
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	from, _ := ParseCIDR("2001:db8:aa::/48")
+	to, _ := ParseCIDR("2001:db8:bb::/48")
+	if got, err := from.RenumberTo(to); err != nil || got.String() != "2001:db8:bb::/48" {
+		t.Fatalf("RenumberTo = %v %v", got, err)
+	}
+	wide, _ := ParseCIDR("2001:db8::/32")
+	if _, err := from.RenumberTo(wide); !errors.Is(err, ErrInvalidPrefix) {
+		t.Fatalf("RenumberTo mismatched length error = %v", err)
+	}
+	var in []CIDR
+	for _, s := range []string{"2001:db8:aa::/48", "2001:db8:aa:1::/64", "2001:db8:aa:ff00::/56", "2001:db8:aa:1::1/128"} {
+		c, _ := ParseCIDR(s)
+		in = append(in, c)
+	}
+	got, err := RenumberCIDRs(in, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2001:db8:bb::/48", "2001:db8:bb:1::/64", "2001:db8:bb:ff00::/56", "2001:db8:bb:1::1/128"}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Fatalf("RenumberCIDRs = %v, want %v", got, want)
+		}
+		// offsets within the block are unchanged
+		po, _ := from.Position(in[i].Base())
+		pn, _ := to.Position(got[i].Base())
+		if po.Cmp(pn) != 0 {
+			t.Fatalf("offset of %s moved from %s to %s", in[i], po, pn)
+		}
+	}
+	outside, _ := ParseCIDR("2001:db8:cc::/64")
+	if _, err := RenumberCIDRs([]CIDR{outside}, from, to); !errors.Is(err, ErrNotContained) {
+		t.Fatalf("outside network error = %v", err)
+	}
+	if _, err := RenumberCIDRs(in, from, wide); !errors.Is(err, ErrInvalidPrefix) {
+		t.Fatalf("mismatched blocks error = %v", err)
+	}
+}
+
+func TestSplitInto(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/48")
+	subs, err := c.SplitInto(16)
+	if err != nil || len(subs) != 16 || subs[0].PrefixLength() != 52 || subs[15].String() != "2001:db8:0:f000::/52" {
+		t.Fatalf("SplitInto(16) = %v %v", subs, err)
+	}
+	if subs, _ := c.SplitInto(1); len(subs) != 1 || subs[0].String() != c.String() {
+		t.Fatalf("SplitInto(1) = %v", subs)
+	}
+	_, err = c.SplitInto(6)
+	if !errors.Is(err, ErrInvalidCount) || !strings.Contains(err.Error(), "4 or 8") {
+		t.Fatalf("expected nearest counts in error, got %v", err)
+	}
+	if _, err := c.SplitInto(0); !errors.Is(err, ErrInvalidCount) {
+		t.Fatalf("expected ErrInvalidCount, got %v", err)
+	}
+	if _, err := c.PrefixForCount(math.MaxInt); !errors.Is(err, ErrInvalidCount) || !strings.Contains(err.Error(), "nearest valid count: "+big.NewInt(math.MaxInt/2+1).String()+")") {
+		t.Fatalf("expected only the lower count for MaxInt, got %v", err)
+	}
+	host, _ := ParseCIDR("2001:db8::/127")
+	if _, err := host.SplitInto(4); !errors.Is(err, ErrInvalidSplitPrefix) {
+		t.Fatalf("expected ErrInvalidSplitPrefix, got %v", err)
+	}
+}
+
+func TestSubnetForHosts(t *testing.T) {
+	c, _ := ParseCIDR("2001:db8::/32")
+	huge := new(big.Int).Lsh(big.NewInt(1), 70)
+	cases := []struct {
+		hosts *big.Int
+		want  int
+	}{
+		{big.NewInt(0), 128}, {big.NewInt(1), 128}, {big.NewInt(2), 127}, {big.NewInt(3), 126},
+		{big.NewInt(256), 120}, {big.NewInt(257), 119}, {huge, 58}, {new(big.Int).Add(huge, big.NewInt(1)), 57},
+	}
+	for _, tc := range cases {
+		got, err := c.SubnetForHosts(tc.hosts)
+		if err != nil || got != tc.want {
+			t.Errorf("%s hosts: got /%d %v want /%d", tc.hosts, got, err, tc.want)
+		}
+	}
+	if p, err := c.SubnetForHosts(new(big.Int).Lsh(big.NewInt(1), 96)); err != nil || p != 32 {
+		t.Fatalf("whole network: /%d %v", p, err)
+	}
+	if _, err := c.SubnetForHosts(new(big.Int).Lsh(big.NewInt(1), 97)); !errors.Is(err, ErrHostsExceedNetwork) {
+		t.Fatalf("expected ErrHostsExceedNetwork, got %v", err)
+	}
+}
+
+func TestPlanVLSM(t *testing.T) {
+	parent, _ := ParseCIDR("2001:db8::/116") // 4096 addresses
+	req := []*big.Int{big.NewInt(50), big.NewInt(1000), big.NewInt(500), big.NewInt(60)}
+	got, err := PlanVLSM(parent, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2001:db8::600/122", "2001:db8::/118", "2001:db8::400/119", "2001:db8::640/122"}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Fatalf("plan = %v want %v", got, want)
+		}
+		if got[i].Base().Compare(got[i].Base().Mask(got[i].PrefixLength())) != 0 || !parent.ContainsCIDR(got[i]) {
+			t.Fatalf("%s misaligned or outside parent", got[i])
+		}
+		for j := 0; j < i; j++ {
+			if got[i].Overlaps(got[j]) {
+				t.Fatalf("%s overlaps %s", got[i], got[j])
+			}
+		}
+	}
+	if _, err := PlanVLSM(parent, []*big.Int{big.NewInt(4096), big.NewInt(1)}); !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("expected ErrNoSpace, got %v", err)
+	}
+	if _, err := PlanVLSM(parent, []*big.Int{big.NewInt(5000)}); !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("expected ErrNoSpace for oversized request, got %v", err)
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	max := new(big.Int).Lsh(big.NewInt(1), 128)
+	cases := []struct {
+		n                  *big.Int
+		raw, power, approx string
+	}{
+		{big.NewInt(0), "0", "", "0"},
+		{big.NewInt(1), "1", "2^0", "1.00e0"},
+		{big.NewInt(255), "255", "", "2.55e2"},
+		{big.NewInt(256), "256", "2^8", "2.56e2"},
+		{new(big.Int).Lsh(big.NewInt(1), 64), "18446744073709551616", "2^64", "1.84e19"},
+		{max, max.String(), "2^128", "3.40e38"},
+	}
+	for _, tc := range cases {
+		raw, power, approx := FormatCount(tc.n)
+		if raw != tc.raw || power != tc.power || approx != tc.approx {
+			t.Fatalf("FormatCount(%s) = %q %q %q want %q %q %q", tc.n, raw, power, approx, tc.raw, tc.power, tc.approx)
+		}
+	}
+}
+
+// Fuzz tests (merged from fuzz_test.go)
+func FuzzParse(f *testing.F) {
+	seeds := []string{"::1", "2001:db8::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		addr, err := Parse(in)
+		if err != nil {
+			return
+		}
+		p2, err := Parse(addr.String())
+		if err != nil {
+			t.Fatalf("re-parse failed: %v", err)
+		}
+		if p2.String() != addr.String() {
+			t.Fatalf("roundtrip mismatch %s != %s", p2, addr)
+		}
+	})
+}
+
+func FuzzSummarize(f *testing.F) {
+	f.Add("2001:db8::/64", "2001:db8:0:0:8000::/65")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		c1, err1 := ParseCIDR(a)
+		c2, err2 := ParseCIDR(b)
+		if err1 != nil || err2 != nil {
+			return
+		}
+		list := []CIDR{c1, c2}
+		res := Summarize(list)
+		for _, orig := range list {
+			contained := false
+			for _, s := range res {
+				if s.ContainsCIDR(orig) {
+					contained = true
+					break
+				}
+			}
+			if !contained {
+				t.Fatalf("lost coverage for %v", orig)
+			}
+		}
+	})
+}
+
+// coverRangeReference is the big.Int implementation CoverRange replaced, kept
+// to check that the uint128 version returns the same CIDRs.
+func coverRangeReference(start, end Address) []CIDR {
+	var res []CIDR
+	cur := start
+	one := big.NewInt(1)
+	for cur.Compare(end) <= 0 {
+		rem := new(big.Int).Add(Distance(cur, end), one)
+		hi, lo := cur.hiLo()
+		var tz int
+		if lo != 0 {
+			tz = bits.TrailingZeros64(lo)
+		} else if hi != 0 {
+			tz = 64 + bits.TrailingZeros64(hi)
+		} else {
+			tz = 128
+		}
+		if remBits := rem.BitLen() - 1; tz > remBits {
+			tz = remBits
+		}
+		cid, _ := NewCIDR(cur, 128-tz)
+		res = append(res, cid)
+		last := cid.LastHost()
+		if last.Compare(end) == 0 {
+			break
+		}
+		cur = last.Add(one)
+	}
+	return res
+}
+
+func TestCoverRangeIterator(t *testing.T) {
+	collect := func(it *CoverIterator) []CIDR {
+		var res []CIDR
+		for c, ok := it.Next(); ok; c, ok = it.Next() {
+			res = append(res, c)
+		}
+		if _, ok := it.Next(); ok {
+			t.Fatal("iterator yielded after reporting done")
+		}
+		return res
+	}
+	for _, r := range [][2]string{
+		{"2001:db8::1", "2001:db8::ff"},
+		{"2001:db8::", "2001:db8::"},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"::fffe:ffff:ffff", "::1:0:0:0"},
+	} {
+		start, _ := Parse(r[0])
+		end, _ := Parse(r[1])
+		want, _ := CoverRange(start, end)
+		if got := collect(CoverRangeIterator(start, end)); !slices.EqualFunc(got, want, CIDR.Equal) {
+			t.Fatalf("CoverRangeIterator(%s, %s) = %v, want %v", r[0], r[1], got, want)
+		}
+	}
+	a, _ := Parse("2001:db8::2")
+	b, _ := Parse("2001:db8::1")
+	if got := collect(CoverRangeIterator(a, b)); len(got) != 0 {
+		t.Fatalf("reversed range yielded %v", got)
+	}
+}
+
+func FuzzCoverRange(f *testing.F) {
+	f.Add(uint64(0x20010db800000000), uint64(1), uint64(0x20010db800000000), uint64(0xff))
+	f.Add(uint64(0), uint64(0), ^uint64(0), ^uint64(0))
+	f.Add(uint64(0), uint64(1), ^uint64(0), ^uint64(1))
+	f.Add(uint64(0), ^uint64(0), uint64(1), uint64(0))
+	f.Fuzz(func(t *testing.T, ahi, alo, bhi, blo uint64) {
+		a, b := fromHiLo(ahi, alo), fromHiLo(bhi, blo)
+		if a.Compare(b) > 0 {
+			a, b = b, a
+		}
+		got, err := CoverRange(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := coverRangeReference(a, b); !slices.EqualFunc(got, want, CIDR.Equal) {
+			t.Fatalf("CoverRange(%s, %s) = %v, want %v", a, b, got, want)
+		}
+		it := CoverRangeIterator(a, b)
+		for i, want := range got {
+			if c, ok := it.Next(); !ok || !c.Equal(want) {
+				t.Fatalf("CoverRangeIterator(%s, %s) #%d = %v %v, want %v", a, b, i, c, ok, want)
+			}
+		}
+		if c, ok := it.Next(); ok {
+			t.Fatalf("CoverRangeIterator(%s, %s) yielded extra %v", a, b, c)
+		}
+	})
+}
+
+func FuzzSplit(f *testing.F) {
+	f.Add("2001:db8::/120", 124)
+	f.Fuzz(func(t *testing.T, cidrStr string, newPrefix int) {
+		c, err := ParseCIDR(cidrStr)
+		if err != nil {
+			return
+		}
+		if newPrefix <= c.plen || newPrefix > 128 || newPrefix-c.plen > 8 {
+			return
+		}
+		subs, err := c.Split(newPrefix)
+		if err != nil {
+			return
+		}
+		for i := 0; i < len(subs); i++ {
+			if !c.ContainsCIDR(subs[i]) {
+				t.Fatalf("sub not contained %v", subs[i])
+			}
+			for j := i + 1; j < len(subs); j++ {
+				if subs[i].Overlaps(subs[j]) {
+					t.Fatalf("overlap %v %v", subs[i], subs[j])
+				}
+			}
+		}
+	})
+}
+
+// Benchmarks (merged from bench_test.go)
+func BenchmarkSplit(b *testing.B) {
+	c, _ := ParseCIDR("2001:db8::/64")
+	for i := 0; i < b.N; i++ {
+		_, _ = c.Split(68)
+	}
+}
+func BenchmarkSplitLarge(b *testing.B) {
+	c, _ := ParseCIDR("2001:db8::/44")
+	for i := 0; i < b.N; i++ {
+		_, _ = c.Split(64)
+	}
+}
+func BenchmarkSplitParallel(b *testing.B) {
+	c, _ := ParseCIDR("2001:db8::/44")
+	for i := 0; i < b.N; i++ {
+		_, _ = c.SplitParallel(64, 0)
+	}
+}
+func BenchmarkSummarize(b *testing.B) {
+	base, _ := ParseCIDR("2001:db8::/64")
+	subs, _ := base.Split(68)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = Summarize(subs)
+	}
+}
+
+// BenchmarkCoverRange covers four ranges that each just miss a /2 at both
+// ends, 1000 CIDRs in total, against the big.Int reference.
+func BenchmarkCoverRange(b *testing.B) {
+	var ranges [][2]Address
+	for q := uint64(0); q < 4; q++ {
+		ranges = append(ranges, [2]Address{fromHiLo(q<<62, 1), fromHiLo(q<<62|(1<<62-1), ^uint64(1))})
+	}
+	for _, bc := range []struct {
+		name  string
+		cover func(a, b Address) []CIDR
+	}{
+		{"uint128", func(a, b Address) []CIDR { c, _ := CoverRange(a, b); return c }},
+		{"reference", coverRangeReference},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, r := range ranges {
+					_ = bc.cover(r[0], r[1])
+				}
+			}
+		})
+	}
+}
+func BenchmarkOverlaps(b *testing.B) {
+	base, _ := ParseCIDR("2001:db8::/36")
+	subs, _ := base.Split(50) // 16384 networks
+	subs = subs[:10000]
+	probe, _ := ParseCIDR("2001:db8:800::/40")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, s := range subs {
+			_ = probe.Overlaps(s)
+		}
+	}
+}
+func BenchmarkContainsAddress(b *testing.B) {
+	c, _ := ParseCIDR("2001:db8::/64")
+	samples := make([]Address, 4096)
+	for i := range samples {
+		samples[i] = c.base.Offset(uint64(i) << 52)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, a := range samples {
+			_ = c.ContainsAddress(a)
+		}
 	}
 }
 func BenchmarkDistance(b *testing.B) {